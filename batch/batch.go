@@ -0,0 +1,412 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-queue/chain"
+	"go-queue/dispatcher"
+	"go-queue/logger"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+//Field is the envelope field with the batch of the job
+const Field = "batch"
+
+//Outcomes of the jobs counted in the batch
+const (
+	Processed = "processed"
+	Failed    = "failed"
+	Cancelled = "cancelled"
+)
+
+//Callbacks of the batch
+const (
+	Then    = "then"
+	Catch   = "catch"
+	Finally = "finally"
+)
+
+//DefaultTTL is the time the batch is kept when no TTL is configured
+const DefaultTTL = 7 * 24 * time.Hour
+
+const keyPrefix = "batch:"
+
+//counterFields are the hash fields counting the outcomes, the cancelled jobs are not counted
+//in the cancelled field, it is the flag of the batch cancelled
+var counterFields = map[string]string{
+	Processed: "processed",
+	Failed:    "failed",
+	Cancelled: "cancelled_jobs",
+}
+
+//recordScript counts the outcome of a job in its counter field and return the pending and failed jobs,
+//if it was the first failure, if the batch is cancelled or has jobs skipped and the callbacks. The first
+//failure cancels the batch unless the failures are allowed
+const recordScript = `
+if redis.call("EXISTS", KEYS[1]) == 0 then return {} end
+redis.call("HINCRBY", KEYS[1], ARGV[1], 1)
+local pending = redis.call("HINCRBY", KEYS[1], "pending", -1)
+local state = redis.call("HMGET", KEYS[1], "failed", "allow_failures", "cancelled", "then", "catch", "finally", "cancelled_jobs")
+local failed = tonumber(state[1]) or 0
+local firstFailure = 0
+if ARGV[1] == "failed" and failed == 1 then
+	firstFailure = 1
+	if state[2] ~= "1" then
+		redis.call("HSET", KEYS[1], "cancelled", "1")
+		state[3] = "1"
+	end
+end
+if (tonumber(state[7]) or 0) > 0 then state[3] = "1" end
+if pending == 0 then
+	redis.call("HSET", KEYS[1], "finished_at", ARGV[2])
+end
+return {pending, failed, firstFailure, state[3] or "0", state[4] or "", state[5] or "", state[6] or ""}`
+
+//discountScript discounts the jobs not pushed from the batch and return the same state as recordScript,
+//so the batch whose pushed jobs already finished dispatches its callbacks
+const discountScript = `
+if redis.call("EXISTS", KEYS[1]) == 0 then return {} end
+redis.call("HINCRBY", KEYS[1], "total", -tonumber(ARGV[1]))
+local pending = redis.call("HINCRBY", KEYS[1], "pending", -tonumber(ARGV[1]))
+local state = redis.call("HMGET", KEYS[1], "failed", "cancelled", "then", "catch", "finally", "cancelled_jobs")
+if (tonumber(state[6]) or 0) > 0 then state[2] = "1" end
+if pending == 0 then
+	redis.call("HSET", KEYS[1], "finished_at", ARGV[2])
+end
+return {pending, tonumber(state[1]) or 0, 0, state[2] or "0", state[3] or "", state[4] or "", state[5] or ""}`
+
+const cancelledScript = `return redis.call("HGET", KEYS[1], "cancelled")`
+
+//ErrNotFound is returned for a batch that does not exist or expired
+var ErrNotFound = errors.New("batch not found")
+
+//RedisInterface is the redis client used to create and query the batches
+type RedisInterface interface {
+	EvalInterface
+	HMSet(key string, fields map[string]interface{}) *redis.StatusCmd
+	HGetAll(key string) *redis.StringStringMapCmd
+	HSet(key, field string, value interface{}) *redis.BoolCmd
+	HIncrBy(key, field string, incr int64) *redis.IntCmd
+	Expire(key string, expiration time.Duration) *redis.BoolCmd
+}
+
+//EvalInterface is the redis client used by the workers to count the jobs of the batches
+type EvalInterface interface {
+	Eval(script string, keys []string, args ...interface{}) *redis.Cmd
+}
+
+//Batch is a group of jobs tracked as one unit. Then is dispatched when all jobs were processed,
+//Catch on the first failure and Finally when all jobs finished. The first failure cancels the
+//jobs not started unless the failures are allowed
+type Batch struct {
+	Name          string
+	Jobs          []chain.Step
+	Then          *chain.Step
+	Catch         *chain.Step
+	Finally       *chain.Step
+	AllowFailures bool
+	TTL           time.Duration
+}
+
+//Progress is the state of a batch
+type Progress struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Total      int       `json:"total"`
+	Pending    int       `json:"pending"`
+	Processed  int       `json:"processed"`
+	Failed     int       `json:"failed"`
+	Skipped    int       `json:"skipped"`
+	Cancelled  bool      `json:"cancelled"`
+	CreatedAt  time.Time `json:"created_at"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+}
+
+//Finished return if all jobs of the batch finished
+func (p Progress) Finished() bool {
+	return p.Pending == 0
+}
+
+//Percent return the percentage of the jobs finished
+func (p Progress) Percent() float64 {
+	if p.Total == 0 {
+		return 100
+	}
+
+	return float64(p.Total-p.Pending) * 100 / float64(p.Total)
+}
+
+//Client dispatches, queries and cancels the batches
+type Client struct {
+	Dispatcher *dispatcher.Dispatcher
+	Redis      RedisInterface
+	Logger     logger.Logger
+}
+
+//Key return the key of the batch
+func Key(id string) string {
+	return keyPrefix + id
+}
+
+//Dispatch stores the batch and push its jobs, return the batch ID. When a push fails
+//the jobs not pushed are discounted from the batch and the error is returned with the ID
+func (c *Client) Dispatch(ctx context.Context, batch Batch) (string, error) {
+	if len(batch.Jobs) == 0 {
+		return "", errors.New("batch without jobs")
+	}
+
+	id := dispatcher.NewJobID()
+	fields := map[string]interface{}{
+		"name":           batch.Name,
+		"total":          len(batch.Jobs),
+		"pending":        len(batch.Jobs),
+		"processed":      0,
+		"failed":         0,
+		"cancelled_jobs": 0,
+		"cancelled":      "0",
+		"allow_failures": boolField(batch.AllowFailures),
+		"created_at":     time.Now().Unix(),
+	}
+
+	callbacks := map[string]*chain.Step{Then: batch.Then, Catch: batch.Catch, Finally: batch.Finally}
+	for name, step := range callbacks {
+		if step == nil {
+			continue
+		}
+
+		encoded, err := json.Marshal(step)
+		if err != nil {
+			return "", err
+		}
+		fields[name] = string(encoded)
+	}
+
+	key := Key(id)
+	if err := c.Redis.HMSet(key, fields).Err(); err != nil {
+		return "", err
+	}
+
+	if err := c.Redis.Expire(key, ttl(batch)).Err(); err != nil {
+		return "", err
+	}
+
+	for i, job := range batch.Jobs {
+		_, err := c.Dispatcher.Dispatch(ctx, job.QueueName, withBatch(job.Payload, id, ""))
+		if err != nil {
+			logger.OrDefault(c.Logger).WithFields(logger.Fields{"batch_id": id}).Errorf("Error to dispatch jobs of the batch: %v", err)
+			c.discount(ctx, id, len(batch.Jobs)-i, i > 0)
+			return id, err
+		}
+	}
+
+	return id, nil
+}
+
+//discount discounts the jobs not pushed from the batch and dispatches the callbacks of the batch
+//when the jobs pushed already finished, no callbacks are dispatched without jobs pushed
+func (c *Client) discount(ctx context.Context, id string, notPushed int, pushed bool) {
+	log := logger.OrDefault(c.Logger).WithFields(logger.Fields{"batch_id": id})
+
+	result, err := c.Redis.Eval(discountScript, []string{Key(id)}, notPushed, time.Now().Unix()).Result()
+	if err != nil {
+		log.Errorf("Error to discount jobs not pushed of the batch: %v", err)
+		return
+	}
+
+	callbacks, err := callbacks(id, result)
+	if err != nil || !pushed {
+		return
+	}
+
+	for _, callback := range callbacks {
+		if _, err := c.Dispatcher.Dispatch(ctx, callback.QueueName, callback.Envelope); err != nil {
+			log.Errorf("Error to dispatch callback of the batch: %v", err)
+		}
+	}
+}
+
+//Progress return the state of the batch
+func (c *Client) Progress(id string) (Progress, error) {
+	fields, err := c.Redis.HGetAll(Key(id)).Result()
+	if err != nil {
+		return Progress{}, err
+	}
+
+	if len(fields) == 0 {
+		return Progress{}, ErrNotFound
+	}
+
+	progress := Progress{
+		ID:        id,
+		Name:      fields["name"],
+		Total:     intField(fields["total"]),
+		Pending:   intField(fields["pending"]),
+		Processed: intField(fields["processed"]),
+		Failed:    intField(fields["failed"]),
+		Skipped:   intField(fields[counterFields[Cancelled]]),
+		Cancelled: fields["cancelled"] == "1",
+		CreatedAt: time.Unix(int64(intField(fields["created_at"])), 0),
+	}
+
+	if finishedAt := intField(fields["finished_at"]); finishedAt > 0 {
+		progress.FinishedAt = time.Unix(int64(finishedAt), 0)
+	}
+
+	return progress, nil
+}
+
+//Cancel cancels the batch, the workers skip its jobs not started
+func (c *Client) Cancel(id string) error {
+	if _, err := c.Progress(id); err != nil {
+		return err
+	}
+
+	return c.Redis.HSet(Key(id), "cancelled", "1").Err()
+}
+
+//FromPayload return the batch ID of the job and the callback name when the job is a callback
+//of the batch, false when the job is not in a batch
+func FromPayload(payload map[string]interface{}) (string, string, bool) {
+	value, ok := payload[Field].(map[string]interface{})
+	if !ok {
+		return "", "", false
+	}
+
+	id, _ := value["id"].(string)
+	callback, _ := value["callback"].(string)
+
+	return id, callback, id != ""
+}
+
+//IsCancelled return if the batch is cancelled
+func IsCancelled(client EvalInterface, id string) (bool, error) {
+	cancelled, err := client.Eval(cancelledScript, []string{Key(id)}).Result()
+	if err == redis.Nil {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return cancelled == "1", nil
+}
+
+//Callback is a callback job to dispatch
+type Callback struct {
+	QueueName string
+	Envelope  map[string]interface{}
+}
+
+//Record counts the outcome of a job of the batch and return the callbacks to dispatch
+func Record(client EvalInterface, id string, outcome string) ([]Callback, error) {
+	field, ok := counterFields[outcome]
+	if !ok {
+		return nil, fmt.Errorf("batch outcome %v is unknown", outcome)
+	}
+
+	result, err := client.Eval(recordScript, []string{Key(id)}, field, time.Now().Unix()).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return callbacks(id, result)
+}
+
+//callbacks return the callbacks to dispatch of the state of the batch returned by the scripts
+func callbacks(id string, result interface{}) ([]Callback, error) {
+	values, ok := result.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected batch result %v", result)
+	}
+
+	if len(values) == 0 {
+		return nil, ErrNotFound
+	}
+
+	if len(values) != 7 {
+		return nil, fmt.Errorf("unexpected batch result %v", result)
+	}
+
+	pending, _ := values[0].(int64)
+	failed, _ := values[1].(int64)
+	firstFailure, _ := values[2].(int64)
+	cancelled, _ := values[3].(string)
+
+	var names []string
+	if firstFailure == 1 {
+		names = append(names, Catch)
+	}
+
+	if pending == 0 && failed == 0 && cancelled != "1" {
+		names = append(names, Then)
+	}
+
+	if pending == 0 {
+		names = append(names, Finally)
+	}
+
+	callbacks := make(map[string]interface{})
+	callbacks[Then], callbacks[Catch], callbacks[Finally] = values[4], values[5], values[6]
+
+	var toDispatch []Callback
+	for _, name := range names {
+		encoded, _ := callbacks[name].(string)
+		if encoded == "" {
+			continue
+		}
+
+		var step chain.Step
+		if err := json.Unmarshal([]byte(encoded), &step); err != nil {
+			return toDispatch, err
+		}
+
+		toDispatch = append(toDispatch, Callback{
+			QueueName: step.QueueName,
+			Envelope:  dispatcher.NewEnvelope(withBatch(step.Payload, id, name)),
+		})
+	}
+
+	return toDispatch, nil
+}
+
+func withBatch(payload map[string]interface{}, id string, callback string) map[string]interface{} {
+	withBatch := make(map[string]interface{})
+	for key, value := range payload {
+		withBatch[key] = value
+	}
+
+	batch := map[string]interface{}{"id": id}
+	if callback != "" {
+		batch["callback"] = callback
+	}
+
+	withBatch[Field] = batch
+	return withBatch
+}
+
+func ttl(batch Batch) time.Duration {
+	if batch.TTL <= 0 {
+		return DefaultTTL
+	}
+
+	return batch.TTL
+}
+
+func boolField(value bool) string {
+	if value {
+		return "1"
+	}
+
+	return "0"
+}
+
+func intField(value string) int {
+	converted, _ := strconv.Atoi(value)
+	return converted
+}
@@ -0,0 +1,331 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-queue/chain"
+	"go-queue/dispatcher"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis"
+)
+
+//------------------------- REDIS MOCK ------------------------
+type redisClientMock struct {
+	hashes map[string]map[string]interface{}
+	pushed map[string][]string
+	result interface{}
+	ttl    time.Duration
+}
+
+func newRedisClientMock() *redisClientMock {
+	return &redisClientMock{hashes: make(map[string]map[string]interface{}), pushed: make(map[string][]string)}
+}
+
+func (r *redisClientMock) HMSet(key string, fields map[string]interface{}) *redis.StatusCmd {
+	r.hashes[key] = fields
+	return redis.NewStatusResult("OK", nil)
+}
+
+func (r *redisClientMock) HGetAll(key string) *redis.StringStringMapCmd {
+	fields := make(map[string]string)
+	for field, value := range r.hashes[key] {
+		encoded, _ := json.Marshal(value)
+		if text, ok := value.(string); ok {
+			encoded = []byte(text)
+		}
+		fields[field] = string(encoded)
+	}
+
+	return redis.NewStringStringMapResult(fields, nil)
+}
+
+func (r *redisClientMock) HSet(key, field string, value interface{}) *redis.BoolCmd {
+	r.hashes[key][field] = value
+	return redis.NewBoolResult(true, nil)
+}
+
+func (r *redisClientMock) HIncrBy(key, field string, incr int64) *redis.IntCmd {
+	value := r.hashes[key][field].(int) + int(incr)
+	r.hashes[key][field] = value
+	return redis.NewIntResult(int64(value), nil)
+}
+
+func (r *redisClientMock) Expire(key string, expiration time.Duration) *redis.BoolCmd {
+	r.ttl = expiration
+	return redis.NewBoolResult(true, nil)
+}
+
+func (r *redisClientMock) RPush(key string, values ...interface{}) *redis.IntCmd {
+	if key == "error" {
+		return redis.NewIntResult(0, errors.New("RPush"))
+	}
+
+	r.pushed[key] = append(r.pushed[key], values[0].(string))
+	return redis.NewIntResult(1, nil)
+}
+
+func (r *redisClientMock) Eval(script string, keys []string, args ...interface{}) *redis.Cmd {
+	if err, ok := r.result.(error); ok {
+		return redis.NewCmdResult(nil, err)
+	}
+
+	return redis.NewCmdResult(r.result, nil)
+}
+
+//failingPushClient fails the pushes to the error queue
+type failingPushClient struct {
+	*redis.Client
+	afterPush func()
+}
+
+func (r *failingPushClient) RPush(key string, values ...interface{}) *redis.IntCmd {
+	if key == "error" {
+		if r.afterPush != nil {
+			r.afterPush()
+		}
+		return redis.NewIntResult(0, errors.New("RPush"))
+	}
+
+	return r.Client.RPush(key, values...)
+}
+
+func newMiniredis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return server, redis.NewClient(&redis.Options{Addr: server.Addr()})
+}
+
+//batchOfQueue return the batch ID of the job pushed to the queue
+func batchOfQueue(t *testing.T, client *redis.Client, queueName string) string {
+	values, err := client.LRange(queueName, 0, 0).Result()
+	if err != nil || len(values) == 0 {
+		t.Fatalf("Expected job in %v but got %v %v", queueName, values, err)
+	}
+
+	payload := make(map[string]interface{})
+	json.Unmarshal([]byte(values[0]), &payload)
+	id, _, _ := FromPayload(payload)
+	return id
+}
+
+func newClient(redisMock *redisClientMock) *Client {
+	return &Client{Dispatcher: &dispatcher.Dispatcher{Client: redisMock}, Redis: redisMock}
+}
+
+//------------------------------ TESTS ---------------------------------
+func TestDispatchStoreBatchAndPushJobs(t *testing.T) {
+	redisMock := newRedisClientMock()
+	client := newClient(redisMock)
+
+	id, err := client.Dispatch(context.Background(), Batch{
+		Name:    "import",
+		Jobs:    []chain.Step{chain.Step{QueueName: "queues:import", Payload: map[string]interface{}{"row": 1}}, chain.Step{QueueName: "queues:import"}},
+		Then:    &chain.Step{QueueName: "queues:notify"},
+		Finally: &chain.Step{QueueName: "queues:cleanup"},
+	})
+	if err != nil {
+		t.Fatalf("Expected batch dispatched but got %v", err)
+	}
+
+	if len(redisMock.pushed["queues:import"]) != 2 || redisMock.ttl != DefaultTTL {
+		t.Fatalf("Expected jobs pushed and batch expiring but got %v %v", redisMock.pushed, redisMock.ttl)
+	}
+
+	envelope := make(map[string]interface{})
+	json.Unmarshal([]byte(redisMock.pushed["queues:import"][0]), &envelope)
+	if batchID, callback, ok := FromPayload(envelope); !ok || batchID != id || callback != "" {
+		t.Errorf("Expected job in the batch but got %v", envelope)
+	}
+
+	progress, err := client.Progress(id)
+	if err != nil || progress.Name != "import" || progress.Total != 2 || progress.Pending != 2 || progress.Percent() != 0 || progress.Finished() {
+		t.Errorf("Expected progress of the batch but got %v %v", progress, err)
+	}
+
+	if _, ok := redisMock.hashes[Key(id)][Catch]; ok {
+		t.Errorf("Expected no catch callback stored")
+	}
+}
+
+func TestDispatchDiscountJobsNotPushed(t *testing.T) {
+	server, redisClient := newMiniredis(t)
+	defer server.Close()
+
+	client := &Client{Dispatcher: &dispatcher.Dispatcher{Client: &failingPushClient{Client: redisClient}}, Redis: redisClient}
+	id, err := client.Dispatch(context.Background(), Batch{Jobs: []chain.Step{
+		chain.Step{QueueName: "queues:import"},
+		chain.Step{QueueName: "error"},
+		chain.Step{QueueName: "queues:import"},
+	}})
+	if err == nil || id == "" {
+		t.Fatalf("Expected error with the batch ID but got %v %v", id, err)
+	}
+
+	if progress, _ := client.Progress(id); progress.Total != 1 || progress.Pending != 1 {
+		t.Errorf("Expected only the job pushed in the batch but got %v", progress)
+	}
+}
+
+func TestDispatchFinishBatchWhenJobsPushedFinished(t *testing.T) {
+	server, redisClient := newMiniredis(t)
+	defer server.Close()
+
+	pushClient := &failingPushClient{Client: redisClient}
+	client := &Client{Dispatcher: &dispatcher.Dispatcher{Client: pushClient}, Redis: redisClient}
+
+	pushClient.afterPush = func() {
+		id := batchOfQueue(t, redisClient, "queues:import")
+		if _, err := Record(redisClient, id, Processed); err != nil {
+			t.Fatalf("Expected job recorded but got %v", err)
+		}
+	}
+
+	id, err := client.Dispatch(context.Background(), Batch{
+		Jobs:    []chain.Step{chain.Step{QueueName: "queues:import"}, chain.Step{QueueName: "error"}},
+		Then:    &chain.Step{QueueName: "queues:notify"},
+		Finally: &chain.Step{QueueName: "queues:cleanup"},
+	})
+	if err == nil {
+		t.Fatalf("Expected error of the job not pushed")
+	}
+
+	if progress, _ := client.Progress(id); !progress.Finished() || progress.Total != 1 || progress.Processed != 1 {
+		t.Errorf("Expected batch finished with the job pushed but got %v", progress)
+	}
+
+	for _, queue := range []string{"queues:notify", "queues:cleanup"} {
+		if length := redisClient.LLen(queue).Val(); length != 1 {
+			t.Errorf("Expected callback pushed to %v but got %v", queue, length)
+		}
+	}
+}
+
+func TestCancelAndProgressNotFound(t *testing.T) {
+	redisMock := newRedisClientMock()
+	client := newClient(redisMock)
+
+	if err := client.Cancel("unknown"); err != ErrNotFound {
+		t.Errorf("Expected batch not found but got %v", err)
+	}
+
+	id, _ := client.Dispatch(context.Background(), Batch{Jobs: []chain.Step{chain.Step{QueueName: "queues:import"}}})
+	if err := client.Cancel(id); err != nil {
+		t.Fatalf("Expected batch cancelled but got %v", err)
+	}
+
+	if progress, _ := client.Progress(id); !progress.Cancelled {
+		t.Errorf("Expected cancelled batch but got %v", progress)
+	}
+}
+
+func TestRecordReturnCallbacks(t *testing.T) {
+	redisMock := newRedisClientMock()
+	then := `{"queue": "queues:notify", "payload": {"user": 1}}`
+	finally := `{"queue": "queues:cleanup"}`
+
+	redisMock.result = []interface{}{int64(0), int64(0), int64(0), "0", then, "", finally}
+	callbacks, err := Record(redisMock, "batch", Processed)
+	if err != nil || len(callbacks) != 2 || callbacks[0].QueueName != "queues:notify" || callbacks[1].QueueName != "queues:cleanup" {
+		t.Fatalf("Expected then and finally callbacks but got %v %v", callbacks, err)
+	}
+
+	if id, callback, _ := FromPayload(callbacks[0].Envelope); id != "batch" || callback != Then || callbacks[0].Envelope["user"] != float64(1) {
+		t.Errorf("Expected then callback of the batch but got %v", callbacks[0].Envelope)
+	}
+
+	redisMock.result = []interface{}{int64(3), int64(1), int64(1), "1", then, `{"queue": "queues:alert"}`, finally}
+	callbacks, _ = Record(redisMock, "batch", Failed)
+	if len(callbacks) != 1 || callbacks[0].QueueName != "queues:alert" {
+		t.Errorf("Expected catch callback on the first failure but got %v", callbacks)
+	}
+
+	redisMock.result = []interface{}{int64(0), int64(1), int64(0), "1", then, "", finally}
+	callbacks, _ = Record(redisMock, "batch", Cancelled)
+	if len(callbacks) != 1 || callbacks[0].QueueName != "queues:cleanup" {
+		t.Errorf("Expected only finally callback with failures but got %v", callbacks)
+	}
+}
+
+func TestRecordReturnError(t *testing.T) {
+	redisMock := newRedisClientMock()
+
+	redisMock.result = []interface{}{}
+	if _, err := Record(redisMock, "batch", Processed); err != ErrNotFound {
+		t.Errorf("Expected batch not found but got %v", err)
+	}
+
+	redisMock.result = errors.New("Eval")
+	if _, err := Record(redisMock, "batch", Processed); err == nil {
+		t.Errorf("Expected redis error")
+	}
+}
+
+func TestIsCancelled(t *testing.T) {
+	redisMock := newRedisClientMock()
+
+	redisMock.result = "1"
+	if cancelled, err := IsCancelled(redisMock, "batch"); !cancelled || err != nil {
+		t.Errorf("Expected cancelled batch but got %v %v", cancelled, err)
+	}
+
+	redisMock.result = redis.Nil
+	if cancelled, err := IsCancelled(redisMock, "batch"); cancelled || err != nil {
+		t.Errorf("Expected batch not cancelled but got %v %v", cancelled, err)
+	}
+}
+
+func TestRecordCancelledJobKeepBatchCancelled(t *testing.T) {
+	server, redisClient := newMiniredis(t)
+	defer server.Close()
+
+	client := &Client{Dispatcher: &dispatcher.Dispatcher{Client: redisClient}, Redis: redisClient}
+	id, _ := client.Dispatch(context.Background(), Batch{
+		Jobs: []chain.Step{chain.Step{QueueName: "queues:import"}, chain.Step{QueueName: "queues:import"}, chain.Step{QueueName: "queues:import"}},
+		Then: &chain.Step{QueueName: "queues:notify"},
+	})
+
+	if err := client.Cancel(id); err != nil {
+		t.Fatalf("Expected batch cancelled but got %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if cancelled, err := IsCancelled(redisClient, id); !cancelled || err != nil {
+			t.Fatalf("Expected batch still cancelled after %v jobs skipped but got %v %v", i, cancelled, err)
+		}
+
+		callbacks, err := Record(redisClient, id, Cancelled)
+		if err != nil || len(callbacks) != 0 {
+			t.Fatalf("Expected no then callback of the cancelled batch but got %v %v", callbacks, err)
+		}
+	}
+
+	progress, _ := client.Progress(id)
+	if !progress.Cancelled || !progress.Finished() || progress.Skipped != 3 {
+		t.Errorf("Expected cancelled batch with the jobs skipped but got %v", progress)
+	}
+}
+
+func TestRecordCancelledJobNotCancelBatch(t *testing.T) {
+	server, redisClient := newMiniredis(t)
+	defer server.Close()
+
+	client := &Client{Dispatcher: &dispatcher.Dispatcher{Client: redisClient}, Redis: redisClient}
+	id, _ := client.Dispatch(context.Background(), Batch{
+		Jobs: []chain.Step{chain.Step{QueueName: "queues:import"}, chain.Step{QueueName: "queues:import"}},
+	})
+
+	if _, err := Record(redisClient, id, Cancelled); err != nil {
+		t.Fatalf("Expected job recorded but got %v", err)
+	}
+
+	if cancelled, err := IsCancelled(redisClient, id); cancelled || err != nil {
+		t.Errorf("Expected batch not cancelled by a job cancelled but got %v %v", cancelled, err)
+	}
+}
@@ -0,0 +1,246 @@
+package breaker
+
+import (
+	"go-queue/logger"
+	"go-queue/providers"
+	"sort"
+	"sync"
+	"time"
+)
+
+//States of the circuit breakers
+const (
+	StateClosed   = "closed"
+	StateOpen     = "open"
+	StateHalfOpen = "half_open"
+)
+
+//Breaker is the circuit breaker of a dependency of the jobs. It opens after the threshold of
+//failures in a row and, after the cooldown, lets one job probe the dependency
+type Breaker struct {
+	Name    string
+	Configs providers.BreakerConfigs
+
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+	probeAt  time.Time
+}
+
+//State return the state of the breaker at the time
+func (b *Breaker) State(now time.Time) string {
+	if b.state == StateOpen && !now.Before(b.openedAt.Add(b.Configs.CooldownDuration())) {
+		return StateHalfOpen
+	}
+
+	if b.state == "" {
+		return StateClosed
+	}
+
+	return b.state
+}
+
+//Wait return the time until a job is allowed, zero when it is allowed now. A probe that
+//did not report in a cooldown is considered lost and another probe is allowed
+func (b *Breaker) Wait(now time.Time) time.Duration {
+	cooldown := b.Configs.CooldownDuration()
+
+	switch b.State(now) {
+	case StateOpen:
+		return b.openedAt.Add(cooldown).Sub(now)
+	case StateHalfOpen:
+		if b.probing && now.Before(b.probeAt.Add(cooldown)) {
+			return b.probeAt.Add(cooldown).Sub(now)
+		}
+	}
+
+	return 0
+}
+
+func (b *Breaker) allow(now time.Time) {
+	if b.State(now) == StateHalfOpen {
+		b.state = StateHalfOpen
+		b.probing = true
+		b.probeAt = now
+	}
+}
+
+func (b *Breaker) success() {
+	b.state = StateClosed
+	b.failures = 0
+	b.probing = false
+}
+
+//failure return if the failure opened the breaker, the failures of the jobs started before
+//it opened do not extend the cooldown
+func (b *Breaker) failure(now time.Time) bool {
+	b.failures++
+
+	state := b.State(now)
+	if state == StateOpen || state == StateClosed && b.failures < b.Configs.Threshold {
+		return false
+	}
+
+	b.state = StateOpen
+	b.openedAt = now
+	b.probing = false
+	return true
+}
+
+//Status is the state of a breaker
+type Status struct {
+	Name     string `json:"name"`
+	State    string `json:"state"`
+	Failures int    `json:"failures"`
+}
+
+//Breakers keeps the circuit breakers shared by the listeners of the worker, one by connection
+//name and one by queue and job type. A connection breaker is created with the configs of the
+//first job failing, and it is opened by the failures of any job using the connection
+type Breakers struct {
+	Logger logger.Logger
+
+	mutex    sync.Mutex
+	breakers map[string]*Breaker
+	now      func() time.Time
+}
+
+//NewBreakers return the breakers, all closed
+func NewBreakers() *Breakers {
+	return &Breakers{breakers: make(map[string]*Breaker)}
+}
+
+//Names return the names of the breakers guarding the job of the type
+func Names(job providers.JobsConfigs, jobType string) []string {
+	var names []string
+	for _, connection := range job.Connections {
+		names = append(names, "connection:"+connection)
+	}
+
+	if jobType == "" {
+		return append(names, "job:"+job.QueueName)
+	}
+
+	return append(names, "job:"+job.QueueName+":"+jobType)
+}
+
+//Wait return the time until the jobs of the type are allowed by all breakers, zero when allowed now
+func (b *Breakers) Wait(job providers.JobsConfigs, jobType string) time.Duration {
+	if b == nil || !job.Breaker.Enabled() {
+		return 0
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.wait(Names(job, jobType), b.clock())
+}
+
+//Allow return if the job of the type can run and, when it can not, the time until it is allowed.
+//A job allowed by a half open breaker is its probe and must report the result with Done
+func (b *Breakers) Allow(job providers.JobsConfigs, jobType string) (bool, time.Duration) {
+	if b == nil || !job.Breaker.Enabled() {
+		return true, 0
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := b.clock()
+	names := Names(job, jobType)
+	if wait := b.wait(names, now); wait > 0 {
+		return false, wait
+	}
+
+	for _, name := range names {
+		if breaker, ok := b.breakers[name]; ok {
+			breaker.allow(now)
+		}
+	}
+
+	return true, 0
+}
+
+//Done records the result of the job of the type in its breakers
+func (b *Breakers) Done(job providers.JobsConfigs, jobType string, err error) {
+	if b == nil || !job.Breaker.Enabled() {
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := b.clock()
+	for _, name := range Names(job, jobType) {
+		breaker, ok := b.breakers[name]
+		if err == nil {
+			if ok && breaker.State(now) != StateClosed {
+				b.logger(name).Infof("Circuit breaker closed")
+			}
+
+			if ok {
+				breaker.success()
+			}
+
+			continue
+		}
+
+		if !ok {
+			breaker = &Breaker{Name: name, Configs: job.Breaker}
+			if b.breakers == nil {
+				b.breakers = make(map[string]*Breaker)
+			}
+			b.breakers[name] = breaker
+		}
+
+		if breaker.failure(now) {
+			b.logger(name).Warnf("Circuit breaker opened after %v failures: %v", breaker.failures, err)
+		}
+	}
+}
+
+//Statuses return the state of the breakers ordered by name
+func (b *Breakers) Statuses() []Status {
+	statuses := []Status{}
+	if b == nil {
+		return statuses
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := b.clock()
+	for name, breaker := range b.breakers {
+		statuses = append(statuses, Status{Name: name, State: breaker.State(now), Failures: breaker.failures})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+
+	return statuses
+}
+
+func (b *Breakers) wait(names []string, now time.Time) time.Duration {
+	var wait time.Duration
+	for _, name := range names {
+		if breaker, ok := b.breakers[name]; ok && breaker.Wait(now) > wait {
+			wait = breaker.Wait(now)
+		}
+	}
+
+	return wait
+}
+
+func (b *Breakers) clock() time.Time {
+	if b.now == nil {
+		return time.Now()
+	}
+
+	return b.now()
+}
+
+func (b *Breakers) logger(name string) logger.Logger {
+	return logger.OrDefault(b.Logger).WithFields(logger.Fields{"breaker": name})
+}
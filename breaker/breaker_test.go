@@ -0,0 +1,157 @@
+package breaker
+
+import (
+	"errors"
+	"go-queue/providers"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//------------------------- MOCK FUNCTIONS ---------------------
+type clockMock struct {
+	now time.Time
+}
+
+func (c *clockMock) Now() time.Time {
+	return c.now
+}
+
+func newBreakersMock() (*Breakers, *clockMock) {
+	clock := &clockMock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	breakers := NewBreakers()
+	breakers.now = clock.Now
+
+	return breakers, clock
+}
+
+var breakerJob = providers.JobsConfigs{
+	QueueName:   "queues:test",
+	Connections: []string{"mysql"},
+	Breaker:     providers.BreakerConfigs{Threshold: 2, Cooldown: time.Minute},
+}
+
+//------------------------------ TESTS ---------------------------------
+func TestBreakersOpenAfterThreshold(t *testing.T) {
+	breakers, _ := newBreakersMock()
+	failure := errors.New("mysql is down")
+
+	breakers.Done(breakerJob, "", failure)
+	if allowed, _ := breakers.Allow(breakerJob, ""); !allowed {
+		t.Errorf("Expected job allowed before the threshold")
+	}
+
+	breakers.Done(breakerJob, "", failure)
+	allowed, wait := breakers.Allow(breakerJob, "")
+	if allowed || wait != time.Minute {
+		t.Errorf("Expected job denied for the cooldown but got %v %v", allowed, wait)
+	}
+
+	other := providers.JobsConfigs{QueueName: "queues:other", Connections: []string{"mysql"}, Breaker: breakerJob.Breaker}
+	if wait := breakers.Wait(other, ""); wait != time.Minute {
+		t.Errorf("Expected jobs of the same connection paused but got %v", wait)
+	}
+}
+
+func TestBreakersSuccessResetFailures(t *testing.T) {
+	breakers, _ := newBreakersMock()
+	failure := errors.New("mysql is down")
+
+	breakers.Done(breakerJob, "", failure)
+	breakers.Done(breakerJob, "", nil)
+	breakers.Done(breakerJob, "", failure)
+
+	if allowed, _ := breakers.Allow(breakerJob, ""); !allowed {
+		t.Errorf("Expected job allowed with failures not in a row")
+	}
+}
+
+func TestBreakersProbeInHalfOpen(t *testing.T) {
+	breakers, clock := newBreakersMock()
+	failure := errors.New("mysql is down")
+
+	breakers.Done(breakerJob, "", failure)
+	breakers.Done(breakerJob, "", failure)
+	clock.now = clock.now.Add(time.Minute)
+
+	if allowed, _ := breakers.Allow(breakerJob, ""); !allowed {
+		t.Fatalf("Expected probe allowed after the cooldown")
+	}
+
+	if allowed, wait := breakers.Allow(breakerJob, ""); allowed || wait != time.Minute {
+		t.Errorf("Expected one probe at a time but got %v %v", allowed, wait)
+	}
+
+	breakers.Done(breakerJob, "", failure)
+	if wait := breakers.Wait(breakerJob, ""); wait != time.Minute {
+		t.Errorf("Expected breaker opened again by the probe failure but got %v", wait)
+	}
+
+	clock.now = clock.now.Add(time.Minute)
+	breakers.Allow(breakerJob, "")
+	breakers.Done(breakerJob, "", nil)
+
+	expected := []Status{
+		Status{Name: "connection:mysql", State: StateClosed},
+		Status{Name: "job:queues:test", State: StateClosed},
+	}
+	if statuses := breakers.Statuses(); !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("Expected breakers closed by the probe success but got %v", statuses)
+	}
+}
+
+func TestBreakersAllowAnotherProbeWhenLost(t *testing.T) {
+	breakers, clock := newBreakersMock()
+	job := providers.JobsConfigs{QueueName: "queues:test", Breaker: providers.BreakerConfigs{Threshold: 1, Cooldown: time.Minute}}
+
+	breakers.Done(job, "", errors.New("api is down"))
+	clock.now = clock.now.Add(time.Minute)
+	breakers.Allow(job, "")
+
+	clock.now = clock.now.Add(time.Minute)
+	if allowed, _ := breakers.Allow(job, ""); !allowed {
+		t.Errorf("Expected another probe allowed after the probe was lost")
+	}
+}
+
+func TestBreakersByJobType(t *testing.T) {
+	breakers, _ := newBreakersMock()
+	job := providers.JobsConfigs{QueueName: "queues:default", Breaker: providers.BreakerConfigs{Threshold: 1}}
+
+	breakers.Done(job, "SendSms", errors.New("sms api is down"))
+
+	if allowed, wait := breakers.Allow(job, "SendSms"); allowed || wait != providers.DefaultCooldown {
+		t.Errorf("Expected job type denied for the default cooldown but got %v %v", allowed, wait)
+	}
+
+	if allowed, _ := breakers.Allow(job, "SendEmail"); !allowed {
+		t.Errorf("Expected other job type allowed")
+	}
+
+	if wait := breakers.Wait(job, ""); wait != 0 {
+		t.Errorf("Expected routed queue not paused by a job type but got %v", wait)
+	}
+}
+
+func TestBreakersDisabled(t *testing.T) {
+	breakers, _ := newBreakersMock()
+	job := providers.JobsConfigs{QueueName: "queues:test", Connections: []string{"mysql"}}
+
+	breakers.Done(job, "", errors.New("mysql is down"))
+	if allowed, _ := breakers.Allow(job, ""); !allowed || len(breakers.Statuses()) != 0 {
+		t.Errorf("Expected jobs without breaker configs not guarded")
+	}
+
+	var nilBreakers *Breakers
+	if allowed, _ := nilBreakers.Allow(breakerJob, ""); !allowed || nilBreakers.Wait(breakerJob, "") != 0 {
+		t.Errorf("Expected jobs allowed without breakers")
+	}
+	nilBreakers.Done(breakerJob, "", errors.New("mysql is down"))
+}
+
+func TestNames(t *testing.T) {
+	names := Names(breakerJob, "SendSms")
+	if !reflect.DeepEqual(names, []string{"connection:mysql", "job:queues:test:SendSms"}) {
+		t.Errorf("Expected breakers of the connection and the job type but got %v", names)
+	}
+}
@@ -0,0 +1,83 @@
+package cancellation
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+//DefaultTTL is the time a job is kept cancelled, it should outlive the job in the queue
+const DefaultTTL = 24 * time.Hour
+
+//DefaultCheckInterval is the time between the checks of the cancellation of a running job
+const DefaultCheckInterval = time.Second
+
+const keyPrefix = "cancelled:"
+
+//ErrCancelled is the error of the jobs cancelled
+var ErrCancelled = errors.New("job cancelled")
+
+//RedisInterface is the redis client used to cancel the jobs
+type RedisInterface interface {
+	Set(key string, value interface{}, expiration time.Duration) *redis.StatusCmd
+}
+
+//CheckInterface is the redis client used by the workers to check the cancellations
+type CheckInterface interface {
+	Exists(keys ...string) *redis.IntCmd
+}
+
+//Key return the key of the cancellation of the job
+func Key(jobID string) string {
+	return keyPrefix + jobID
+}
+
+//Cancel marks the job as cancelled for the ttl, the workers skip it when it is popped
+//and cancel the context of its handler when it is running
+func Cancel(client RedisInterface, jobID string, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	return client.Set(Key(jobID), "1", ttl).Err()
+}
+
+//IsCancelled return if the job is cancelled
+func IsCancelled(client CheckInterface, jobID string) (bool, error) {
+	exists, err := client.Exists(Key(jobID)).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return exists > 0, nil
+}
+
+//Watch return a context cancelled when the job is cancelled, checked each interval, and the
+//function to stop watching. The errors of the checks are ignored until the next check
+func Watch(ctx context.Context, client CheckInterface, jobID string, interval time.Duration) (context.Context, func()) {
+	if interval <= 0 {
+		interval = DefaultCheckInterval
+	}
+
+	watched, cancel := context.WithCancel(ctx)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-watched.Done():
+				return
+			case <-ticker.C:
+				if cancelled, err := IsCancelled(client, jobID); err == nil && cancelled {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	return watched, cancel
+}
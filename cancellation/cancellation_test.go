@@ -0,0 +1,93 @@
+package cancellation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+//------------------------- REDIS MOCK ------------------------
+type redisClientMock struct {
+	mutex sync.Mutex
+	keys  map[string]time.Duration
+	err   error
+}
+
+func (r *redisClientMock) Set(key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.keys[key] = expiration
+	return redis.NewStatusResult("OK", nil)
+}
+
+func (r *redisClientMock) Exists(keys ...string) *redis.IntCmd {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.err != nil {
+		return redis.NewIntResult(0, r.err)
+	}
+
+	if _, ok := r.keys[keys[0]]; ok {
+		return redis.NewIntResult(1, nil)
+	}
+
+	return redis.NewIntResult(0, nil)
+}
+
+//------------------------------ TESTS ---------------------------------
+func TestCancelAndIsCancelled(t *testing.T) {
+	redisMock := &redisClientMock{keys: make(map[string]time.Duration)}
+
+	if err := Cancel(redisMock, "job", 0); err != nil || redisMock.keys["cancelled:job"] != DefaultTTL {
+		t.Fatalf("Expected job cancelled for the default TTL but got %v %v", err, redisMock.keys)
+	}
+
+	if cancelled, err := IsCancelled(redisMock, "job"); !cancelled || err != nil {
+		t.Errorf("Expected job cancelled but got %v %v", cancelled, err)
+	}
+
+	if cancelled, _ := IsCancelled(redisMock, "other"); cancelled {
+		t.Errorf("Expected other job not cancelled")
+	}
+
+	redisMock.err = errors.New("Exists")
+	if _, err := IsCancelled(redisMock, "job"); err == nil {
+		t.Errorf("Expected redis error")
+	}
+}
+
+func TestWatchCancelContext(t *testing.T) {
+	redisMock := &redisClientMock{keys: make(map[string]time.Duration)}
+
+	ctx, stop := Watch(context.Background(), redisMock, "job", time.Millisecond)
+	defer stop()
+
+	Cancel(redisMock, "job", time.Minute)
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Errorf("Expected context cancelled with the job")
+	}
+}
+
+func TestWatchStop(t *testing.T) {
+	redisMock := &redisClientMock{keys: make(map[string]time.Duration)}
+
+	ctx, stop := Watch(context.Background(), redisMock, "job", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if ctx.Err() != nil {
+		t.Errorf("Expected context running while the job is not cancelled")
+	}
+
+	stop()
+	if ctx.Err() == nil {
+		t.Errorf("Expected context done after stop")
+	}
+}
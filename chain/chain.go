@@ -0,0 +1,126 @@
+package chain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-queue/dispatcher"
+)
+
+//Field is the envelope field with the state of the chain
+const Field = "chain"
+
+//Step is a job of the chain
+type Step struct {
+	QueueName string                 `json:"queue"`
+	Payload   map[string]interface{} `json:"payload"`
+}
+
+//Failure is the job of the chain that ran out of attempts, passed to the catch job with the position of the job
+type Failure struct {
+	QueueName string `json:"queue"`
+	JobID     string `json:"job_id"`
+	Error     string `json:"error"`
+}
+
+//State is the state of the chain carried by the envelope of its jobs. Steps are all the jobs of the chain,
+//Position is the position of the current one from 1 and Previous is the result of the job before it
+type State struct {
+	ID       string      `json:"id"`
+	Position int         `json:"position"`
+	Total    int         `json:"total"`
+	Steps    []Step      `json:"steps"`
+	Catch    *Step       `json:"catch,omitempty"`
+	Previous interface{} `json:"previous,omitempty"`
+	Failure  *Failure    `json:"failure,omitempty"`
+}
+
+//Chain runs the steps one after the other, each step receiving the result of the step before it.
+//When a step runs out of attempts the rest of the chain is abandoned and the catch job is dispatched
+type Chain struct {
+	Steps []Step
+	Catch *Step
+}
+
+//Dispatch push the first step and return the ID of the chain
+func (c Chain) Dispatch(ctx context.Context, d *dispatcher.Dispatcher) (string, error) {
+	if len(c.Steps) == 0 {
+		return "", errors.New("chain without steps")
+	}
+
+	state := State{
+		ID:       dispatcher.NewJobID(),
+		Position: 1,
+		Total:    len(c.Steps),
+		Steps:    c.Steps,
+		Catch:    c.Catch,
+	}
+
+	_, err := d.Dispatch(ctx, c.Steps[0].QueueName, withState(c.Steps[0].Payload, state))
+	if err != nil {
+		return "", err
+	}
+
+	return state.ID, nil
+}
+
+//FromPayload return the state of the chain of the job, false when the job is not in a chain
+func FromPayload(payload map[string]interface{}) (State, bool) {
+	var state State
+
+	value, ok := payload[Field]
+	if !ok {
+		return state, false
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil || json.Unmarshal(encoded, &state) != nil || state.ID == "" {
+		return state, false
+	}
+
+	return state, true
+}
+
+//Previous return the result of the step before the job, nil for the first step or a job out of a chain
+func Previous(payload map[string]interface{}) interface{} {
+	state, _ := FromPayload(payload)
+	return state.Previous
+}
+
+//Next return the queue and the envelope of the step after the job with its result,
+//false when the job is the last step or the catch job of the chain
+func Next(state State, result interface{}) (string, map[string]interface{}, bool) {
+	if state.Failure != nil || state.Position < 1 || state.Position >= len(state.Steps) {
+		return "", nil, false
+	}
+
+	step := state.Steps[state.Position]
+	state.Position++
+	state.Previous = result
+
+	return step.QueueName, dispatcher.NewEnvelope(withState(step.Payload, state)), true
+}
+
+//Catch return the queue and the envelope of the catch job with the failure, false without catch job.
+//The catch job keeps the steps of the chain with the position of the job failed
+func Catch(state State, failure Failure) (string, map[string]interface{}, bool) {
+	if state.Catch == nil {
+		return "", nil, false
+	}
+
+	step := *state.Catch
+	state.Catch = nil
+	state.Failure = &failure
+
+	return step.QueueName, dispatcher.NewEnvelope(withState(step.Payload, state)), true
+}
+
+func withState(payload map[string]interface{}, state State) map[string]interface{} {
+	withState := make(map[string]interface{})
+	for key, value := range payload {
+		withState[key] = value
+	}
+
+	withState[Field] = state
+	return withState
+}
@@ -0,0 +1,134 @@
+package chain
+
+import (
+	"context"
+	"encoding/json"
+	"go-queue/dispatcher"
+	"reflect"
+	"testing"
+
+	"github.com/go-redis/redis"
+)
+
+//------------------------- REDIS MOCK ------------------------
+type redisClientMock struct {
+	key    string
+	values []interface{}
+}
+
+func (r *redisClientMock) RPush(key string, values ...interface{}) *redis.IntCmd {
+	r.key = key
+	r.values = values
+	return redis.NewIntResult(1, nil)
+}
+
+//decode return the envelope as it is read by the consumers
+func decode(t *testing.T, envelope interface{}) map[string]interface{} {
+	encoded, _ := json.Marshal(envelope)
+	if text, ok := envelope.(string); ok {
+		encoded = []byte(text)
+	}
+
+	decoded := make(map[string]interface{})
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Error to decode envelope %v", err)
+	}
+
+	return decoded
+}
+
+var steps = []Step{
+	Step{QueueName: "queues:download", Payload: map[string]interface{}{"url": "http://file"}},
+	Step{QueueName: "queues:resize", Payload: map[string]interface{}{"width": float64(100)}},
+	Step{QueueName: "queues:upload"},
+}
+
+//------------------------------ TESTS ---------------------------------
+func TestDispatchPushFirstStep(t *testing.T) {
+	redisMock := &redisClientMock{}
+	catch := &Step{QueueName: "queues:cleanup"}
+
+	id, err := Chain{Steps: steps, Catch: catch}.Dispatch(context.Background(), &dispatcher.Dispatcher{Client: redisMock})
+	if err != nil || id == "" {
+		t.Fatalf("Expected chain dispatched but got %v", err)
+	}
+
+	envelope := decode(t, redisMock.values[0])
+	state, ok := FromPayload(envelope)
+	if redisMock.key != "queues:download" || envelope["url"] != "http://file" || !ok {
+		t.Fatalf("Expected first step pushed with the chain but got %v %v", redisMock.key, envelope)
+	}
+
+	if state.ID != id || state.Position != 1 || state.Total != 3 || !reflect.DeepEqual(state.Steps, steps) || state.Catch.QueueName != "queues:cleanup" {
+		t.Errorf("Expected chain state with all the steps but got %v", state)
+	}
+}
+
+func TestDispatchWithoutSteps(t *testing.T) {
+	if _, err := (Chain{}).Dispatch(context.Background(), &dispatcher.Dispatcher{Client: &redisClientMock{}}); err == nil {
+		t.Errorf("Expected an error for chain without steps")
+	}
+}
+
+func TestNextPassResultToNextStep(t *testing.T) {
+	state := State{ID: "chain", Position: 1, Total: 3, Steps: steps}
+
+	queueName, envelope, ok := Next(state, map[string]interface{}{"path": "/tmp/file"})
+	if !ok || queueName != "queues:resize" {
+		t.Fatalf("Expected next step but got %v %v", queueName, ok)
+	}
+
+	decoded := decode(t, envelope)
+	next, _ := FromPayload(decoded)
+	if decoded["width"] != float64(100) || decoded["id"] == nil || next.Position != 2 || len(next.Steps) != 3 {
+		t.Errorf("Expected envelope of the next step but got %v", decoded)
+	}
+
+	if previous := Previous(decoded); !reflect.DeepEqual(previous, map[string]interface{}{"path": "/tmp/file"}) {
+		t.Errorf("Expected result of the previous step but got %v", previous)
+	}
+
+	if queueName, _, ok := Next(State{ID: "chain", Position: 2, Total: 3, Steps: steps}, nil); !ok || queueName != "queues:upload" {
+		t.Errorf("Expected last step after the second one but got %v %v", queueName, ok)
+	}
+
+	if _, _, ok := Next(State{ID: "chain", Position: 3, Total: 3, Steps: steps}, nil); ok {
+		t.Errorf("Expected no step after the last one")
+	}
+}
+
+func TestCatchAbandonChain(t *testing.T) {
+	state := State{ID: "chain", Position: 2, Total: 3, Steps: steps, Catch: &Step{QueueName: "queues:cleanup"}}
+
+	queueName, envelope, ok := Catch(state, Failure{QueueName: "queues:resize", JobID: "job", Error: "too big"})
+	if !ok || queueName != "queues:cleanup" {
+		t.Fatalf("Expected catch job but got %v %v", queueName, ok)
+	}
+
+	catchState, _ := FromPayload(decode(t, envelope))
+	if len(catchState.Steps) != 3 || catchState.Catch != nil || catchState.Position != 2 || catchState.Failure.Error != "too big" {
+		t.Errorf("Expected catch job with the failure and the whole chain but got %v", catchState)
+	}
+
+	if _, _, ok := Next(catchState, nil); ok {
+		t.Errorf("Expected no step after the catch job")
+	}
+
+	if _, _, ok := Catch(State{ID: "chain"}, Failure{}); ok {
+		t.Errorf("Expected no catch job")
+	}
+}
+
+func TestFromPayloadOutOfChain(t *testing.T) {
+	if _, ok := FromPayload(map[string]interface{}{"id": "job"}); ok {
+		t.Errorf("Expected job out of chain")
+	}
+
+	if _, ok := FromPayload(map[string]interface{}{Field: "invalid"}); ok {
+		t.Errorf("Expected invalid chain ignored")
+	}
+
+	if previous := Previous(map[string]interface{}{}); previous != nil {
+		t.Errorf("Expected no previous result but got %v", previous)
+	}
+}
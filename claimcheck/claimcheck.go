@@ -0,0 +1,229 @@
+package claimcheck
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-queue/envelopes"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+//Header is the envelope header with the key of the payload offloaded to the store
+const Header = "claim-check"
+
+//DefaultThreshold is the size in bytes from which the payloads are offloaded when no threshold is configured
+const DefaultThreshold = 256 * 1024
+
+//ErrNotFound is returned for a payload that is not in the store
+var ErrNotFound = errors.New("payload not found")
+
+//storeError is an error of the store that may not happen when the payload is fetched again
+type storeError struct {
+	err error
+}
+
+func (e storeError) Error() string {
+	return e.err.Error()
+}
+
+//Transient return whether the error of Fetch is of the store, like a store down or not configured, and the payload
+//can be fetched later. The payloads not found and not readable are not fetched again
+func Transient(err error) bool {
+	_, ok := err.(storeError)
+	return ok
+}
+
+//Store keeps the payloads offloaded from the queues by key
+type Store interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+//Offloader stores the payloads from the threshold size in the store and pushes only their reference
+//to the queue, the claim check. The ID, the attempts and the headers stay in the queue
+type Offloader struct {
+	Store     Store
+	Threshold int
+}
+
+func (o *Offloader) threshold() int {
+	if o.Threshold <= 0 {
+		return DefaultThreshold
+	}
+
+	return o.Threshold
+}
+
+//Offload stores the envelope when its size reaches the threshold and return the envelope with its
+//reference, or the envelope as it is. A nil offloader return the envelope as it is
+func (o *Offloader) Offload(ctx context.Context, data string) (string, error) {
+	if o == nil || o.Store == nil || len(data) < o.threshold() {
+		return data, nil
+	}
+
+	envelope, err := envelopes.Decode(data)
+	if err != nil {
+		return "", err
+	}
+
+	headers := envelopes.Headers(envelope)
+	if _, ok := headers[Header]; ok {
+		return data, nil
+	}
+
+	key := newKey(envelope)
+	if err := o.Store.Put(ctx, key, []byte(data)); err != nil {
+		return "", err
+	}
+
+	headers[Header] = key
+	claim := map[string]interface{}{
+		"id":       envelope["id"],
+		"attempts": envelope["attempts"],
+		"headers":  headers,
+	}
+
+	encoded, err := json.Marshal(claim)
+	return string(encoded), err
+}
+
+//Fetch return the envelope stored by the reference of the envelope and the key of the payload,
+//the envelopes not offloaded are returned as they are with an empty key. The attempts of the
+//reference are kept, they are the attempts updated after the payload was offloaded. The errors
+//of the store are Transient
+func (o *Offloader) Fetch(ctx context.Context, data string) (string, string, error) {
+	key := Key(data)
+	if key == "" {
+		return data, "", nil
+	}
+
+	if o == nil || o.Store == nil {
+		return "", key, storeError{errors.New("payload is offloaded and there is no claim check store")}
+	}
+
+	stored, err := o.Store.Get(ctx, key)
+	if err == ErrNotFound {
+		return "", key, fmt.Errorf("payload %v can not be fetched: %v", key, err)
+	}
+
+	if err != nil {
+		return "", key, storeError{fmt.Errorf("payload %v can not be fetched: %v", key, err)}
+	}
+
+	envelope, err := envelopes.Decode(string(stored))
+	if err != nil {
+		return "", key, err
+	}
+
+	claim, _ := envelopes.Decode(data)
+	if attempts, ok := claim["attempts"]; ok && fmt.Sprint(attempts) != fmt.Sprint(envelope["attempts"]) {
+		envelope["attempts"] = attempts
+		stored, err = json.Marshal(envelope)
+	}
+
+	return string(stored), key, err
+}
+
+//Key return the key of the payload of the envelope offloaded, empty for the envelopes not offloaded
+func Key(data string) string {
+	if !strings.Contains(data, Header) {
+		return ""
+	}
+
+	claim, err := envelopes.Decode(data)
+	if err != nil {
+		return ""
+	}
+
+	key, _ := envelopes.Headers(claim)[Header].(string)
+	return key
+}
+
+//Delete removes the payload of the key from the store
+func (o *Offloader) Delete(ctx context.Context, key string) error {
+	if o == nil || o.Store == nil || key == "" {
+		return nil
+	}
+
+	return o.Store.Delete(ctx, key)
+}
+
+//newKey return a random key prefixed by the job ID
+func newKey(envelope map[string]interface{}) string {
+	random := make([]byte, 16)
+	rand.Read(random)
+
+	if id, ok := envelope["id"].(string); ok && id != "" && !strings.ContainsAny(id, `/\.`) {
+		return id + "-" + hex.EncodeToString(random)
+	}
+
+	return hex.EncodeToString(random)
+}
+
+//OffloaderFromEnv return the offloader of the store of CLAIM_CHECK_STORE, nil without store. The stores are
+//file, with the files in CLAIM_CHECK_DIR, s3, with the bucket S3_BUCKET of S3_ENDPOINT, signed with
+//S3_ACCESS_KEY and S3_SECRET_KEY for S3_REGION, and gridfs, with the bucket GRIDFS_BUCKET of the
+//database GRIDFS_DATABASE, MONGODB_DATABASE by default, of the mongo client. The payloads are offloaded
+//from CLAIM_CHECK_THRESHOLD bytes
+func OffloaderFromEnv(env map[string]string, mongoClient *mongo.Client) (*Offloader, error) {
+	offloader := &Offloader{}
+
+	if threshold := env["CLAIM_CHECK_THRESHOLD"]; threshold != "" {
+		bytes, err := strconv.Atoi(threshold)
+		if err != nil || bytes < 0 {
+			return nil, fmt.Errorf("CLAIM_CHECK_THRESHOLD must be a number of bytes: %v", threshold)
+		}
+
+		offloader.Threshold = bytes
+	}
+
+	switch store := env["CLAIM_CHECK_STORE"]; store {
+	case "":
+		return nil, nil
+	case "file":
+		if env["CLAIM_CHECK_DIR"] == "" {
+			return nil, errors.New("CLAIM_CHECK_DIR is required by the file claim check store")
+		}
+
+		offloader.Store = &FileStore{Dir: env["CLAIM_CHECK_DIR"]}
+	case "s3":
+		if env["S3_ENDPOINT"] == "" || env["S3_BUCKET"] == "" {
+			return nil, errors.New("S3_ENDPOINT and S3_BUCKET are required by the s3 claim check store")
+		}
+
+		offloader.Store = &S3Store{
+			Endpoint:  env["S3_ENDPOINT"],
+			Bucket:    env["S3_BUCKET"],
+			Region:    env["S3_REGION"],
+			AccessKey: env["S3_ACCESS_KEY"],
+			SecretKey: env["S3_SECRET_KEY"],
+		}
+	case "gridfs":
+		if mongoClient == nil {
+			return nil, errors.New("gridfs claim check store without mongo connection configured")
+		}
+
+		database := env["GRIDFS_DATABASE"]
+		if database == "" {
+			database = env["MONGODB_DATABASE"]
+		}
+
+		gridfsStore, err := NewGridFSStore(mongoClient, database, env["GRIDFS_BUCKET"])
+		if err != nil {
+			return nil, err
+		}
+
+		offloader.Store = gridfsStore
+	default:
+		return nil, fmt.Errorf("claim check store %v is unknown", store)
+	}
+
+	return offloader, nil
+}
@@ -0,0 +1,236 @@
+package claimcheck
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var largeEnvelope = `{"id":"job","attempts":0,"headers":{"traceparent":"00-trace"},"rows":"` + strings.Repeat("row,", 100) + `"}`
+
+//------------------------- S3 STAND-IN ------------------------
+type s3StandIn struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (s *s3StandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/") ||
+		r.Header.Get("x-amz-date") == "" || r.Header.Get("x-amz-content-sha256") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := ioutil.ReadAll(r.Body)
+		s.objects[r.URL.Path] = body
+	case http.MethodGet:
+		object, ok := s.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(object)
+	case http.MethodDelete:
+		delete(s.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func tempFileStore(t *testing.T) (*FileStore, func()) {
+	dir, err := ioutil.TempDir("", "payloads")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &FileStore{Dir: dir}, func() { os.RemoveAll(dir) }
+}
+
+//------------------------------ TESTS ---------------------------------
+func TestOffloadAndFetch(t *testing.T) {
+	store, cleanup := tempFileStore(t)
+	defer cleanup()
+
+	offloader := &Offloader{Store: store, Threshold: 100}
+
+	claim, err := offloader.Offload(context.Background(), largeEnvelope)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	reference := make(map[string]interface{})
+	json.Unmarshal([]byte(claim), &reference)
+	headers := reference["headers"].(map[string]interface{})
+	key, _ := headers[Header].(string)
+	if strings.Contains(claim, "row,") || reference["id"] != "job" || !strings.HasPrefix(key, "job-") || headers["traceparent"] != "00-trace" {
+		t.Errorf("Expected reference keeping the id and the headers but got %v", claim)
+	}
+
+	fetched, fetchedKey, err := offloader.Fetch(context.Background(), claim)
+	if err != nil || fetched != largeEnvelope || fetchedKey != key {
+		t.Errorf("Expected envelope fetched from the store but got %v %v %v", fetched, fetchedKey, err)
+	}
+
+	if err := offloader.Delete(context.Background(), key); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	if _, err := store.Get(context.Background(), key); err != ErrNotFound {
+		t.Errorf("Expected payload deleted but got %v", err)
+	}
+}
+
+func TestOffloadBelowThreshold(t *testing.T) {
+	store, cleanup := tempFileStore(t)
+	defer cleanup()
+
+	offloader := &Offloader{Store: store}
+	if claim, err := offloader.Offload(context.Background(), largeEnvelope); err != nil || claim != largeEnvelope {
+		t.Errorf("Expected envelope below the default threshold not offloaded but got %v %v", claim, err)
+	}
+
+	var disabled *Offloader
+	if claim, err := disabled.Offload(context.Background(), largeEnvelope); err != nil || claim != largeEnvelope {
+		t.Errorf("Expected envelope not offloaded without offloader but got %v %v", claim, err)
+	}
+}
+
+func TestFetchKeepAttemptsOfReference(t *testing.T) {
+	store, cleanup := tempFileStore(t)
+	defer cleanup()
+
+	offloader := &Offloader{Store: store, Threshold: 1}
+	claim, _ := offloader.Offload(context.Background(), largeEnvelope)
+	claim = strings.Replace(claim, `"attempts":0`, `"attempts":2`, 1)
+
+	fetched, _, err := offloader.Fetch(context.Background(), claim)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	envelope := make(map[string]interface{})
+	json.Unmarshal([]byte(fetched), &envelope)
+	if envelope["attempts"] != float64(2) || !strings.Contains(fetched, "row,") {
+		t.Errorf("Expected envelope with the attempts of the reference but got %v", fetched)
+	}
+}
+
+func TestFetchEnvelopeNotOffloaded(t *testing.T) {
+	var offloader *Offloader
+	for _, data := range []string{largeEnvelope, "not json claim-check"} {
+		if fetched, key, err := offloader.Fetch(context.Background(), data); err != nil || fetched != data || key != "" {
+			t.Errorf("Expected envelope not offloaded returned as it is but got %v %v %v", fetched, key, err)
+		}
+	}
+
+	_, key, err := offloader.Fetch(context.Background(), `{"id":"job","headers":{"claim-check":"job-1"}}`)
+	if err == nil || key != "job-1" || !Transient(err) {
+		t.Errorf("Expected transient error fetching without store but got %v %v", key, err)
+	}
+
+	store, cleanup := tempFileStore(t)
+	defer cleanup()
+
+	_, _, err = (&Offloader{Store: store}).Fetch(context.Background(), `{"id":"job","headers":{"claim-check":"job-1"}}`)
+	if err == nil || !strings.Contains(err.Error(), ErrNotFound.Error()) || Transient(err) {
+		t.Errorf("Expected error of the payload missing but got %v", err)
+	}
+}
+
+func TestFileStoreRejectInvalidKeys(t *testing.T) {
+	store, cleanup := tempFileStore(t)
+	defer cleanup()
+
+	for _, key := range []string{"", "../job", "dir/job", ".hidden"} {
+		if err := store.Put(context.Background(), key, []byte("data")); err == nil {
+			t.Errorf("Expected error for the key %q", key)
+		}
+	}
+
+	if err := store.Delete(context.Background(), "missing"); err != nil {
+		t.Errorf("Expected no error deleting a missing payload but got %v", err)
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	standIn := &s3StandIn{objects: make(map[string][]byte)}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	store := &S3Store{Endpoint: server.URL, Bucket: "payloads", AccessKey: "access", SecretKey: "secret"}
+	offloader := &Offloader{Store: store, Threshold: 1}
+
+	claim, err := offloader.Offload(context.Background(), largeEnvelope)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	fetched, key, err := offloader.Fetch(context.Background(), claim)
+	if err != nil || fetched != largeEnvelope {
+		t.Fatalf("Expected envelope fetched from the bucket but got %v %v", fetched, err)
+	}
+
+	if _, ok := standIn.objects["/payloads/"+key]; !ok {
+		t.Errorf("Expected object of the key %v in the bucket but got %v", key, standIn.objects)
+	}
+
+	if err := offloader.Delete(context.Background(), key); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	if _, err := store.Get(context.Background(), key); err != ErrNotFound {
+		t.Errorf("Expected object deleted but got %v", err)
+	}
+
+	store.AccessKey = "unknown"
+	if err := store.Put(context.Background(), "job", []byte("data")); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Expected error of the request refused but got %v", err)
+	}
+}
+
+func TestSigningKey(t *testing.T) {
+	key := signingKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+	if expected := "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d"; hex.EncodeToString(key) != expected {
+		t.Errorf("Expected signing key %v but got %x", expected, key)
+	}
+}
+
+func TestOffloaderFromEnv(t *testing.T) {
+	offloader, err := OffloaderFromEnv(map[string]string{}, nil)
+	if err != nil || offloader != nil {
+		t.Errorf("Expected no offloader without store but got %v %v", offloader, err)
+	}
+
+	offloader, err = OffloaderFromEnv(map[string]string{"CLAIM_CHECK_STORE": "file", "CLAIM_CHECK_DIR": "/tmp/payloads", "CLAIM_CHECK_THRESHOLD": "1024"}, nil)
+	if err != nil || offloader.Threshold != 1024 || offloader.Store.(*FileStore).Dir != "/tmp/payloads" {
+		t.Errorf("Expected file store offloader but got %v %v", offloader, err)
+	}
+
+	offloader, err = OffloaderFromEnv(map[string]string{"CLAIM_CHECK_STORE": "s3", "S3_ENDPOINT": "http://localhost:9000", "S3_BUCKET": "payloads"}, nil)
+	if err != nil || offloader.Store.(*S3Store).Bucket != "payloads" {
+		t.Errorf("Expected s3 store offloader but got %v %v", offloader, err)
+	}
+
+	for _, env := range []map[string]string{
+		{"CLAIM_CHECK_STORE": "file"},
+		{"CLAIM_CHECK_STORE": "s3"},
+		{"CLAIM_CHECK_STORE": "gridfs"},
+		{"CLAIM_CHECK_STORE": "ftp"},
+		{"CLAIM_CHECK_STORE": "file", "CLAIM_CHECK_DIR": "/tmp", "CLAIM_CHECK_THRESHOLD": "big"},
+	} {
+		if _, err := OffloaderFromEnv(env, nil); err == nil {
+			t.Errorf("Expected error for the env %v", env)
+		}
+	}
+}
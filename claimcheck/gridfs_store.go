@@ -0,0 +1,75 @@
+package claimcheck
+
+import (
+	"bytes"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//DefaultGridFSBucket is the name of the GridFS bucket when no bucket is configured
+const DefaultGridFSBucket = "claim_check"
+
+//GridFSStore keeps the payloads as files of a GridFS bucket, named by the key
+type GridFSStore struct {
+	Bucket *gridfs.Bucket
+}
+
+//NewGridFSStore return the store of the GridFS bucket of the database
+func NewGridFSStore(client *mongo.Client, database string, bucket string) (*GridFSStore, error) {
+	if bucket == "" {
+		bucket = DefaultGridFSBucket
+	}
+
+	gridfsBucket, err := gridfs.NewBucket(client.Database(database), options.GridFSBucket().SetName(bucket))
+	if err != nil {
+		return nil, err
+	}
+
+	return &GridFSStore{Bucket: gridfsBucket}, nil
+}
+
+//Put uploads the payload to the file of the key
+func (s *GridFSStore) Put(ctx context.Context, key string, data []byte) error {
+	_, err := s.Bucket.UploadFromStream(key, bytes.NewReader(data))
+	return err
+}
+
+//Get downloads the payload of the file of the key, ErrNotFound without file
+func (s *GridFSStore) Get(ctx context.Context, key string) ([]byte, error) {
+	var buffer bytes.Buffer
+	_, err := s.Bucket.DownloadToStreamByName(key, &buffer)
+	if err == gridfs.ErrFileNotFound {
+		return nil, ErrNotFound
+	}
+
+	return buffer.Bytes(), err
+}
+
+//Delete removes the files of the key
+func (s *GridFSStore) Delete(ctx context.Context, key string) error {
+	cursor, err := s.Bucket.Find(bson.M{"filename": key})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var file struct {
+			ID interface{} `bson:"_id"`
+		}
+
+		if err := cursor.Decode(&file); err != nil {
+			return err
+		}
+
+		if err := s.Bucket.Delete(file.ID); err != nil && err != gridfs.ErrFileNotFound {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
@@ -0,0 +1,168 @@
+package claimcheck
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+//DefaultS3Region is the region of the requests signed when no region is configured
+const DefaultS3Region = "us-east-1"
+
+//S3Store keeps the payloads as objects of a bucket of an S3-compatible store, like AWS S3 or MinIO.
+//The objects are addressed by path, Endpoint/Bucket/key, and the requests are signed with AWS signature V4
+type S3Store struct {
+	Endpoint  string
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	Client    *http.Client
+}
+
+//Put uploads the payload to the object of the key
+func (s *S3Store) Put(ctx context.Context, key string, data []byte) error {
+	response, err := s.do(ctx, http.MethodPut, key, data)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return statusError(response)
+	}
+
+	return nil
+}
+
+//Get downloads the payload of the object of the key, ErrNotFound without object
+func (s *S3Store) Get(ctx context.Context, key string) ([]byte, error) {
+	response, err := s.do(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+		return ioutil.ReadAll(response.Body)
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, statusError(response)
+	}
+}
+
+//Delete removes the object of the key
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	response, err := s.do(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		return statusError(response)
+	}
+}
+
+func (s *S3Store) do(ctx context.Context, method string, key string, body []byte) (*http.Response, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(s.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	if key == "" || strings.Contains(key, "/") {
+		return nil, fmt.Errorf("invalid key %q", key)
+	}
+
+	endpoint.Path = endpoint.Path + "/" + s.Bucket + "/" + key
+	request, err := http.NewRequest(method, endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	s.sign(request, body, time.Now().UTC())
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return client.Do(request.WithContext(ctx))
+}
+
+//sign adds the AWS signature V4 headers of the request
+func (s *S3Store) sign(request *http.Request, body []byte, now time.Time) {
+	region := s.Region
+	if region == "" {
+		region = DefaultS3Region
+	}
+
+	date, timestamp := now.Format("20060102"), now.Format("20060102T150405Z")
+	payloadHash := sha256Hex(body)
+
+	request.Header.Set("x-amz-date", timestamp)
+	request.Header.Set("x-amz-content-sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		request.URL.RawQuery,
+		"host:" + request.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + timestamp,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		timestamp,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signature := hex.EncodeToString(hmacSHA256(signingKey(s.SecretKey, date, region, "s3"), stringToSign))
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%v/%v, SignedHeaders=%v, Signature=%v",
+		s.AccessKey, scope, signedHeaders, signature))
+}
+
+//signingKey derives the AWS signature V4 key of the date, the region and the service
+func signingKey(secret string, date string, region string, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func statusError(response *http.Response) error {
+	message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 512))
+	return fmt.Errorf("s3 responded %v: %v", response.Status, strings.TrimSpace(string(message)))
+}
@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go-queue/cancellation"
+	"go-queue/claimcheck"
+	"go-queue/deadletter"
+	"go-queue/lease"
+	"io"
+	"time"
+)
+
+//Usage is the help of the commands
+const Usage = `Usage:
+  go-queue                       run the worker
+  go-queue cancel <job-id>...    cancel the jobs
+  go-queue progress <job-id>...  show the progress reported by the jobs
+  go-queue dlq depth <dlq>       show the number of jobs in the dead-letter queue
+  go-queue dlq list [-offset n] [-count n] <dlq>
+                                 print the jobs of the dead-letter queue from the oldest
+  go-queue dlq redrive [-limit n] [-rate n] <dlq>
+                                 push the jobs of the dead-letter queue back to their queues,
+                                 at most rate jobs per second
+  go-queue dlq purge <dlq>       remove the jobs of the dead-letter queue and their offloaded payloads`
+
+//RedisInterface is the redis client used by the commands
+type RedisInterface interface {
+	cancellation.RedisInterface
+	lease.ProgressInterface
+	deadletter.RedisInterface
+}
+
+//CLI runs the commands given in the command line, ClaimCheck is the store of the payloads
+//offloaded deleted with the jobs purged
+type CLI struct {
+	Redis      RedisInterface
+	ClaimCheck *claimcheck.Offloader
+	Out        io.Writer
+}
+
+//Run runs the command of the args, without the program name
+func (c *CLI) Run(args []string) error {
+	if len(args) == 0 {
+		return errors.New(Usage)
+	}
+
+	switch args[0] {
+	case "cancel":
+		return c.cancel(args[1:])
+	case "progress":
+		return c.progress(args[1:])
+	case "dlq":
+		return c.deadLetter(args[1:])
+	case "help":
+		fmt.Fprintln(c.Out, Usage)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %v\n%v", args[0], Usage)
+	}
+}
+
+func (c *CLI) cancel(jobIDs []string) error {
+	if len(jobIDs) == 0 {
+		return errors.New(Usage)
+	}
+
+	for _, jobID := range jobIDs {
+		if err := cancellation.Cancel(c.Redis, jobID, cancellation.DefaultTTL); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(c.Out, "Job %v cancelled\n", jobID)
+	}
+
+	return nil
+}
+
+func (c *CLI) progress(jobIDs []string) error {
+	if len(jobIDs) == 0 {
+		return errors.New(Usage)
+	}
+
+	for _, jobID := range jobIDs {
+		progress, err := lease.GetProgress(c.Redis, jobID)
+		if err == lease.ErrNotFound {
+			fmt.Fprintf(c.Out, "Job %v: no progress reported\n", jobID)
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(c.Out, "Job %v: %v%% %v (updated %v)\n", jobID, progress.Percent, progress.Message, progress.UpdatedAt.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+func (c *CLI) deadLetter(args []string) error {
+	if len(args) == 0 {
+		return errors.New(Usage)
+	}
+
+	client := &deadletter.Client{Redis: c.Redis, ClaimCheck: c.ClaimCheck}
+	flags := flag.NewFlagSet("dlq "+args[0], flag.ContinueOnError)
+	flags.SetOutput(c.Out)
+	offset := flags.Int64("offset", 0, "jobs skipped from the oldest")
+	count := flags.Int64("count", 10, "jobs printed")
+	limit := flags.Int("limit", 0, "max jobs redriven, all jobs when zero")
+	rate := flags.Float64("rate", 0, "max jobs redriven per second, unlimited when zero")
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New(Usage)
+	}
+	queueName := flags.Arg(0)
+
+	switch args[0] {
+	case "depth":
+		depth, err := client.Depth(queueName)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(c.Out, "%v jobs in %v\n", depth, queueName)
+		return nil
+	case "list":
+		entries, err := client.List(queueName, *offset, *count)
+		if err != nil {
+			return err
+		}
+
+		encoder := json.NewEncoder(c.Out)
+		for _, entry := range entries {
+			encoder.Encode(entry)
+		}
+
+		return nil
+	case "redrive":
+		redriven, err := client.Redrive(context.Background(), queueName, deadletter.RedriveOptions{Limit: *limit, PerSecond: *rate})
+		fmt.Fprintf(c.Out, "%v jobs redriven from %v\n", redriven, queueName)
+		if poisoned, _ := client.Depth(deadletter.PoisonKey(queueName)); poisoned > 0 {
+			fmt.Fprintf(c.Out, "%v jobs that can not be decoded in %v\n", poisoned, deadletter.PoisonKey(queueName))
+		}
+
+		return err
+	case "purge":
+		purged, err := client.Purge(context.Background(), queueName)
+		fmt.Fprintf(c.Out, "%v jobs purged from %v\n", purged, queueName)
+		return err
+	default:
+		return fmt.Errorf("unknown command dlq %v\n%v", args[0], Usage)
+	}
+}
@@ -0,0 +1,180 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+//------------------------- REDIS MOCK ------------------------
+type redisClientMock struct {
+	keys  []string
+	lists map[string][]string
+}
+
+func (r *redisClientMock) LPush(key string, values ...interface{}) *redis.IntCmd {
+	for _, value := range values {
+		r.lists[key] = append([]string{value.(string)}, r.lists[key]...)
+	}
+
+	return redis.NewIntResult(int64(len(r.lists[key])), nil)
+}
+
+func (r *redisClientMock) RPush(key string, values ...interface{}) *redis.IntCmd {
+	for _, value := range values {
+		r.lists[key] = append(r.lists[key], value.(string))
+	}
+
+	return redis.NewIntResult(int64(len(r.lists[key])), nil)
+}
+
+func (r *redisClientMock) RPop(key string) *redis.StringCmd {
+	list := r.lists[key]
+	if len(list) == 0 {
+		return redis.NewStringResult("", redis.Nil)
+	}
+
+	r.lists[key] = list[:len(list)-1]
+	return redis.NewStringResult(list[len(list)-1], nil)
+}
+
+func (r *redisClientMock) LRange(key string, start, stop int64) *redis.StringSliceCmd {
+	list := r.lists[key]
+	size := int64(len(list))
+	if start < 0 {
+		start += size
+	}
+	if stop < 0 {
+		stop += size
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= size {
+		stop = size - 1
+	}
+	if start > stop {
+		return redis.NewStringSliceResult([]string{}, nil)
+	}
+
+	return redis.NewStringSliceResult(list[start:stop+1], nil)
+}
+
+func (r *redisClientMock) LLen(key string) *redis.IntCmd {
+	if key == "error" {
+		return redis.NewIntResult(0, errors.New("LLen"))
+	}
+
+	return redis.NewIntResult(int64(len(r.lists[key])), nil)
+}
+
+func (r *redisClientMock) Set(key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	if key == "cancelled:error" {
+		return redis.NewStatusResult("", errors.New("Set"))
+	}
+
+	r.keys = append(r.keys, key)
+	return redis.NewStatusResult("OK", nil)
+}
+
+func (r *redisClientMock) Get(key string) *redis.StringCmd {
+	switch key {
+	case "progress:job1":
+		return redis.NewStringResult(`{"job_id":"job1","percent":40,"message":"rows imported","updated_at":"2020-01-01T00:00:00Z"}`, nil)
+	case "progress:error":
+		return redis.NewStringResult("", errors.New("Get"))
+	default:
+		return redis.NewStringResult("", redis.Nil)
+	}
+}
+
+//------------------------------ TESTS ---------------------------------
+func TestRunCancelJobs(t *testing.T) {
+	redisMock := &redisClientMock{}
+	out := &bytes.Buffer{}
+	commands := CLI{Redis: redisMock, Out: out}
+
+	err := commands.Run([]string{"cancel", "job1", "job2"})
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	if len(redisMock.keys) != 2 || redisMock.keys[1] != "cancelled:job2" {
+		t.Errorf("Expected jobs cancelled but got %v", redisMock.keys)
+	}
+
+	if out.String() != "Job job1 cancelled\nJob job2 cancelled\n" {
+		t.Errorf("Expected cancelled jobs printed but got %v", out.String())
+	}
+}
+
+func TestRunShowProgress(t *testing.T) {
+	out := &bytes.Buffer{}
+	commands := CLI{Redis: &redisClientMock{}, Out: out}
+
+	err := commands.Run([]string{"progress", "job1", "job2"})
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	expected := "Job job1: 40% rows imported (updated 2020-01-01T00:00:00Z)\nJob job2: no progress reported\n"
+	if out.String() != expected {
+		t.Errorf("Expected progress printed but got %v", out.String())
+	}
+}
+
+func TestRunDeadLetterCommands(t *testing.T) {
+	redisMock := &redisClientMock{lists: map[string][]string{
+		"queues:test:dead": []string{
+			`{"queue":"queues:test","job_id":"job2","payload":"{\"id\":\"job2\",\"attempts\":3}","error":"timeout","attempts":3,"failed_at":"2020-01-01T00:01:00Z"}`,
+			`{"queue":"queues:test","job_id":"job1","payload":"{\"id\":\"job1\",\"attempts\":3}","error":"timeout","attempts":3,"failed_at":"2020-01-01T00:00:00Z"}`,
+		},
+	}}
+	out := &bytes.Buffer{}
+	commands := CLI{Redis: redisMock, Out: out}
+
+	if err := commands.Run([]string{"dlq", "depth", "queues:test:dead"}); err != nil || out.String() != "2 jobs in queues:test:dead\n" {
+		t.Errorf("Expected depth printed but got %v %v", out.String(), err)
+	}
+
+	out.Reset()
+	if err := commands.Run([]string{"dlq", "list", "-count", "1", "queues:test:dead"}); err != nil || !strings.Contains(out.String(), `"job_id":"job1"`) || strings.Contains(out.String(), "job2") {
+		t.Errorf("Expected the oldest job printed but got %v %v", out.String(), err)
+	}
+
+	out.Reset()
+	if err := commands.Run([]string{"dlq", "redrive", "-limit", "1", "queues:test:dead"}); err != nil || out.String() != "1 jobs redriven from queues:test:dead\n" {
+		t.Errorf("Expected one job redriven but got %v %v", out.String(), err)
+	}
+
+	if len(redisMock.lists["queues:test"]) != 1 || redisMock.lists["queues:test"][0] != `{"attempts":0,"id":"job1"}` {
+		t.Errorf("Expected the oldest job pushed back with the attempts reset but got %v", redisMock.lists["queues:test"])
+	}
+
+	out.Reset()
+	if err := commands.Run([]string{"dlq", "purge", "queues:test:dead"}); err != nil || out.String() != "1 jobs purged from queues:test:dead\n" {
+		t.Errorf("Expected the last job purged but got %v %v", out.String(), err)
+	}
+
+	out.Reset()
+	redisMock.lists["queues:test:dead"] = []string{"not json"}
+	expected := "0 jobs redriven from queues:test:dead\n1 jobs that can not be decoded in queues:test:dead:poison\n"
+	if err := commands.Run([]string{"dlq", "redrive", "queues:test:dead"}); err != nil || out.String() != expected {
+		t.Errorf("Expected job that can not be decoded reported but got %v %v", out.String(), err)
+	}
+}
+
+func TestRunReturnError(t *testing.T) {
+	commands := CLI{Redis: &redisClientMock{}, Out: &bytes.Buffer{}}
+
+	for _, args := range [][]string{{}, {"cancel"}, {"unknown"}, {"cancel", "error"}, {"progress"}, {"progress", "error"},
+		{"dlq"}, {"dlq", "depth"}, {"dlq", "depth", "error"}, {"dlq", "unknown", "queues:test:dead"}, {"dlq", "list", "-unknown", "queues:test:dead"}} {
+		if err := commands.Run(args); err == nil {
+			t.Errorf("Expected an error for %v", args)
+		}
+	}
+}
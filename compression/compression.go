@@ -0,0 +1,146 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"go-queue/envelopes"
+	"go-queue/providers"
+	"io/ioutil"
+	"strings"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+//Header is the envelope header with the codec of the compressed payload
+const Header = "content-encoding"
+
+//Field is the envelope field with the compressed payload encoded in base64
+const Field = "compressed"
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+//Compress return the envelope compressed with the codec of the configs when its size reaches the threshold,
+//or the envelope as it is. The ID, the attempts and the headers are kept out of the compressed payload
+func Compress(data string, configs providers.CompressionConfigs) (string, error) {
+	if !configs.Enabled() || len(data) < configs.ThresholdBytes() {
+		return data, nil
+	}
+
+	envelope, err := envelopes.Decode(data)
+	if err != nil {
+		return "", err
+	}
+
+	if _, ok := envelope[Field]; ok {
+		return data, nil
+	}
+
+	compressed, err := compress(configs.Codec, []byte(data))
+	if err != nil {
+		return "", err
+	}
+
+	headers := envelopes.Headers(envelope)
+	headers[Header] = configs.Codec
+
+	wrapped := map[string]interface{}{
+		"id":       envelope["id"],
+		"attempts": envelope["attempts"],
+		"headers":  headers,
+		Field:      base64.StdEncoding.EncodeToString(compressed),
+	}
+
+	encoded, err := json.Marshal(wrapped)
+	return string(encoded), err
+}
+
+//Decompress return the envelope decompressed with the codec of its header, the envelopes not
+//compressed are returned as they are. The attempts out of the compressed payload are kept,
+//they are the attempts updated after the envelope was compressed
+func Decompress(data string) (string, error) {
+	if !strings.Contains(data, Header) {
+		return data, nil
+	}
+
+	wrapped, err := envelopes.Decode(data)
+	if err != nil {
+		return data, nil
+	}
+
+	codec, ok := envelopes.Headers(wrapped)[Header].(string)
+	if !ok {
+		return data, nil
+	}
+
+	encoded, _ := wrapped[Field].(string)
+	compressed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("compressed payload is not base64: %v", err)
+	}
+
+	decompressed, err := decompress(codec, compressed)
+	if err != nil {
+		return "", err
+	}
+
+	envelope, err := envelopes.Decode(string(decompressed))
+	if err != nil {
+		return "", err
+	}
+
+	if attempts, ok := wrapped["attempts"]; ok && fmt.Sprint(attempts) != fmt.Sprint(envelope["attempts"]) {
+		envelope["attempts"] = attempts
+		decompressed, err = json.Marshal(envelope)
+	}
+
+	return string(decompressed), err
+}
+
+func compress(codec string, data []byte) ([]byte, error) {
+	switch codec {
+	case providers.CompressionGzip:
+		var buffer bytes.Buffer
+		writer := gzip.NewWriter(&buffer)
+		if _, err := writer.Write(data); err != nil {
+			return nil, err
+		}
+
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+
+		return buffer.Bytes(), nil
+	case providers.CompressionZstd:
+		return zstdEncoder.EncodeAll(data, nil), nil
+	case providers.CompressionSnappy:
+		return snappy.Encode(nil, data), nil
+	default:
+		return nil, fmt.Errorf("compression codec %v is unknown", codec)
+	}
+}
+
+func decompress(codec string, data []byte) ([]byte, error) {
+	switch codec {
+	case providers.CompressionGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+
+		return ioutil.ReadAll(reader)
+	case providers.CompressionZstd:
+		return zstdDecoder.DecodeAll(data, nil)
+	case providers.CompressionSnappy:
+		return snappy.Decode(nil, data)
+	default:
+		return nil, fmt.Errorf("compression codec %v is unknown", codec)
+	}
+}
@@ -0,0 +1,82 @@
+package compression
+
+import (
+	"encoding/json"
+	"go-queue/providers"
+	"strings"
+	"testing"
+)
+
+var largeEnvelope = `{"id":"job","attempts":0,"headers":{"traceparent":"00-trace"},"rows":"` + strings.Repeat("row,", 100) + `"}`
+
+//------------------------------ TESTS ---------------------------------
+func TestCompressAndDecompress(t *testing.T) {
+	for _, codec := range []string{providers.CompressionGzip, providers.CompressionZstd, providers.CompressionSnappy} {
+		compressed, err := Compress(largeEnvelope, providers.CompressionConfigs{Codec: codec, Threshold: 100})
+		if err != nil {
+			t.Fatalf("Expected no error for %v but got %v", codec, err)
+		}
+
+		wrapped := make(map[string]interface{})
+		json.Unmarshal([]byte(compressed), &wrapped)
+		headers := wrapped["headers"].(map[string]interface{})
+		if len(compressed) >= len(largeEnvelope) || wrapped["id"] != "job" || headers[Header] != codec || headers["traceparent"] != "00-trace" {
+			t.Errorf("Expected envelope compressed with %v keeping the id and the headers but got %v", codec, compressed)
+		}
+
+		decompressed, err := Decompress(compressed)
+		if err != nil || decompressed != largeEnvelope {
+			t.Errorf("Expected envelope decompressed with %v but got %v %v", codec, decompressed, err)
+		}
+	}
+}
+
+func TestCompressBelowThreshold(t *testing.T) {
+	compressed, err := Compress(largeEnvelope, providers.CompressionConfigs{Codec: providers.CompressionGzip})
+	if err != nil || compressed != largeEnvelope {
+		t.Errorf("Expected envelope below the default threshold not compressed but got %v %v", compressed, err)
+	}
+
+	compressed, _ = Compress(largeEnvelope, providers.CompressionConfigs{Threshold: 1})
+	if compressed != largeEnvelope {
+		t.Errorf("Expected envelope not compressed without codec but got %v", compressed)
+	}
+}
+
+func TestDecompressEnvelopeNotCompressed(t *testing.T) {
+	for _, data := range []string{largeEnvelope, `{"id":"job","headers":{"content-type":"json"}}`, "not json content-encoding"} {
+		if decompressed, err := Decompress(data); err != nil || decompressed != data {
+			t.Errorf("Expected envelope not compressed returned as it is but got %v %v", decompressed, err)
+		}
+	}
+}
+
+func TestDecompressKeepAttemptsOutOfPayload(t *testing.T) {
+	compressed, _ := Compress(largeEnvelope, providers.CompressionConfigs{Codec: providers.CompressionSnappy, Threshold: 1})
+	compressed = strings.Replace(compressed, `"attempts":0`, `"attempts":2`, 1)
+
+	decompressed, err := Decompress(compressed)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	envelope := make(map[string]interface{})
+	json.Unmarshal([]byte(decompressed), &envelope)
+	if envelope["attempts"] != float64(2) || envelope["rows"] == nil {
+		t.Errorf("Expected attempts updated after the compression but got %v", envelope)
+	}
+}
+
+func TestDecompressReturnError(t *testing.T) {
+	corrupted := []string{
+		`{"id":"job","headers":{"content-encoding":"gzip"},"compressed":"not base64"}`,
+		`{"id":"job","headers":{"content-encoding":"gzip"},"compressed":"bm90IGd6aXA="}`,
+		`{"id":"job","headers":{"content-encoding":"lz4"},"compressed":"bm90IGx6NA=="}`,
+	}
+
+	for _, data := range corrupted {
+		if _, err := Decompress(data); err == nil {
+			t.Errorf("Expected an error for %v", data)
+		}
+	}
+}
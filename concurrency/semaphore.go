@@ -0,0 +1,74 @@
+package concurrency
+
+import (
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const keyPrefix = "concurrency:"
+
+//acquireScript expires the leases passed and adds the token when there is a free slot
+const acquireScript = `
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", ARGV[1])
+if redis.call("ZCARD", KEYS[1]) < tonumber(ARGV[2]) then
+	redis.call("ZADD", KEYS[1], ARGV[3], ARGV[4])
+	redis.call("PEXPIRE", KEYS[1], ARGV[5])
+	return 1
+end
+return 0`
+
+//extendScript extends the lease of the slot of the token while it is taken
+const extendScript = `
+if redis.call("ZSCORE", KEYS[1], ARGV[2]) then
+	redis.call("ZADD", KEYS[1], ARGV[1], ARGV[2])
+	redis.call("PEXPIRE", KEYS[1], ARGV[3])
+	return 1
+end
+return 0`
+
+const releaseScript = `return redis.call("ZREM", KEYS[1], ARGV[1])`
+
+//RedisInterface is the redis client used by the semaphores
+type RedisInterface interface {
+	Eval(script string, keys []string, args ...interface{}) *redis.Cmd
+}
+
+//Semaphore limits the concurrent executions by key across the workers sharing the redis.
+//Each slot is a lease that expires, so the slots of the crashed workers are freed
+type Semaphore struct {
+	Redis RedisInterface
+}
+
+//Key return the semaphore key of the queue and the concurrency key
+func Key(queueName string, key string) string {
+	return keyPrefix + queueName + ":" + key
+}
+
+//Acquire takes a slot of the key for the token when less than limit slots are taken
+func (s Semaphore) Acquire(key string, token string, limit int, lease time.Duration) (bool, error) {
+	now := time.Now()
+	expiry := now.Add(lease)
+
+	acquired, err := s.Redis.Eval(acquireScript, []string{key}, milliseconds(now), limit, milliseconds(expiry), token, int64(lease/time.Millisecond)).Int64()
+	if err != nil {
+		return false, err
+	}
+
+	return acquired == 1, nil
+}
+
+//Extend extends the lease of the slot of the token, a slot released or expired is not taken again
+func (s Semaphore) Extend(key string, token string, lease time.Duration) error {
+	expiry := time.Now().Add(lease)
+	return s.Redis.Eval(extendScript, []string{key}, milliseconds(expiry), token, int64(lease/time.Millisecond)).Err()
+}
+
+//Release frees the slot of the token
+func (s Semaphore) Release(key string, token string) error {
+	return s.Redis.Eval(releaseScript, []string{key}, token).Err()
+}
+
+func milliseconds(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
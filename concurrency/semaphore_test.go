@@ -0,0 +1,93 @@
+package concurrency
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis"
+)
+
+//redisClientMock keeps the slots of the keys ignoring the leases
+type redisClientMock struct {
+	slots map[string]map[string]bool
+	args  []interface{}
+}
+
+func (r *redisClientMock) Eval(script string, keys []string, args ...interface{}) *redis.Cmd {
+	if r.slots[keys[0]] == nil {
+		r.slots[keys[0]] = make(map[string]bool)
+	}
+
+	if script == releaseScript {
+		delete(r.slots[keys[0]], args[0].(string))
+		return redis.NewCmdResult(int64(1), nil)
+	}
+
+	r.args = args
+	if len(r.slots[keys[0]]) < args[1].(int) {
+		r.slots[keys[0]][args[3].(string)] = true
+		return redis.NewCmdResult(int64(1), nil)
+	}
+
+	return redis.NewCmdResult(int64(0), nil)
+}
+
+func TestSemaphoreLimitSlots(t *testing.T) {
+	redisClient := &redisClientMock{slots: make(map[string]map[string]bool)}
+	semaphore := Semaphore{Redis: redisClient}
+	key := Key("queues:test", "account:1")
+
+	for _, token := range []string{"a", "b"} {
+		if acquired, err := semaphore.Acquire(key, token, 2, time.Minute); !acquired || err != nil {
+			t.Fatalf("Expected slot acquired by %v but got %v", token, err)
+		}
+	}
+
+	if acquired, _ := semaphore.Acquire(key, "c", 2, time.Minute); acquired {
+		t.Errorf("Expected no slot over the limit")
+	}
+
+	if lease := redisClient.args[4].(int64); lease != 60000 {
+		t.Errorf("Expected lease in milliseconds but got %v", lease)
+	}
+
+	semaphore.Release(key, "a")
+	if acquired, _ := semaphore.Acquire(key, "c", 2, time.Minute); !acquired {
+		t.Errorf("Expected slot acquired after release")
+	}
+}
+
+func TestSemaphoreExtend(t *testing.T) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	semaphore := Semaphore{Redis: redisClient}
+	key := Key("queues:test", "account:1")
+
+	semaphore.Acquire(key, "a", 1, time.Second)
+	if err := semaphore.Extend(key, "a", time.Hour); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	server.FastForward(time.Minute)
+	if acquired, _ := semaphore.Acquire(key, "b", 1, time.Second); acquired {
+		t.Errorf("Expected slot kept by the extended lease")
+	}
+
+	semaphore.Release(key, "a")
+	semaphore.Extend(key, "a", time.Hour)
+	if acquired, _ := semaphore.Acquire(key, "b", 1, time.Second); !acquired {
+		t.Errorf("Expected released slot not taken again by the extension")
+	}
+}
+
+func TestKey(t *testing.T) {
+	if key := Key("queues:test", "account:1"); key != "concurrency:queues:test:account:1" {
+		t.Errorf("Expected key by queue but got %v", key)
+	}
+}
@@ -0,0 +1,198 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"go-queue/claimcheck"
+	"go-queue/envelopes"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+//Entry is a job that ran out of attempts kept in the dead-letter queue, Payload is the
+//envelope of the last attempt as it was popped from the source queue
+type Entry struct {
+	QueueName string    `json:"queue"`
+	JobID     string    `json:"job_id,omitempty"`
+	JobType   string    `json:"job_type,omitempty"`
+	Payload   string    `json:"payload"`
+	Error     string    `json:"error"`
+	Attempts  float64   `json:"attempts"`
+	FailedAt  time.Time `json:"failed_at"`
+}
+
+//PushInterface is the redis client used by the workers to push the jobs to the dead-letter queues
+type PushInterface interface {
+	LPush(key string, values ...interface{}) *redis.IntCmd
+}
+
+//RedisInterface is the redis client used to inspect and redrive the dead-letter queues
+type RedisInterface interface {
+	PushInterface
+	RPush(key string, values ...interface{}) *redis.IntCmd
+	RPop(key string) *redis.StringCmd
+	LRange(key string, start, stop int64) *redis.StringSliceCmd
+	LLen(key string) *redis.IntCmd
+}
+
+//PoisonKey return the key of the list with the entries of the dead-letter queue that can not be decoded,
+//they are moved apart when the queue is redriven
+func PoisonKey(queueName string) string {
+	return queueName + ":poison"
+}
+
+//Push pushes the entry to the dead-letter queue, the newest entries are at the head of the list
+func Push(client PushInterface, queueName string, entry Entry) error {
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return client.LPush(queueName, string(encoded)).Err()
+}
+
+//RedriveOptions limits the redrive, Limit is the max number of jobs redriven, all jobs without limit,
+//and PerSecond the max number of jobs pushed back to the source queues per second, unlimited when zero
+type RedriveOptions struct {
+	Limit     int
+	PerSecond float64
+}
+
+//Client inspects, redrives and purges the dead-letter queues. The payloads of the jobs offloaded
+//are deleted from the ClaimCheck store when the jobs are purged
+type Client struct {
+	Redis      RedisInterface
+	ClaimCheck *claimcheck.Offloader
+}
+
+//Depth return the number of jobs in the dead-letter queue
+func (c *Client) Depth(queueName string) (int64, error) {
+	return c.Redis.LLen(queueName).Result()
+}
+
+//List return up to count jobs of the dead-letter queue from the oldest, skipping offset jobs
+func (c *Client) List(queueName string, offset int64, count int64) ([]Entry, error) {
+	values, err := c.Redis.LRange(queueName, -offset-count, -offset-1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(values))
+	for i := len(values) - 1; i >= 0; i-- {
+		var entry Entry
+		if err := json.Unmarshal([]byte(values[i]), &entry); err != nil {
+			return entries, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+//Redrive pushes the jobs of the dead-letter queue back to their source queues from the oldest, with the
+//attempts reset, until the queue is empty, the limit is reached or the context is done. Return the number
+//of jobs redriven, a job that could not be pushed is kept in the dead-letter queue and the entries that can
+//not be decoded are moved to the list of PoisonKey
+func (c *Client) Redrive(ctx context.Context, queueName string, options RedriveOptions) (int, error) {
+	var throttle <-chan time.Time
+	if options.PerSecond > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / options.PerSecond))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	redriven := 0
+	for options.Limit <= 0 || redriven < options.Limit {
+		if redriven > 0 && throttle != nil {
+			select {
+			case <-ctx.Done():
+				return redriven, ctx.Err()
+			case <-throttle:
+			}
+		} else if ctx.Err() != nil {
+			return redriven, ctx.Err()
+		}
+
+		value, err := c.Redis.RPop(queueName).Result()
+		if err == redis.Nil {
+			return redriven, nil
+		}
+
+		if err != nil {
+			return redriven, err
+		}
+
+		entry, payload, err := decode(value)
+		if err != nil {
+			if err := c.Redis.LPush(PoisonKey(queueName), value).Err(); err != nil {
+				c.Redis.RPush(queueName, value)
+				return redriven, err
+			}
+
+			continue
+		}
+
+		if err := c.Redis.LPush(entry.QueueName, payload).Err(); err != nil {
+			c.Redis.RPush(queueName, value)
+			return redriven, err
+		}
+
+		redriven++
+	}
+
+	return redriven, nil
+}
+
+//Purge removes the jobs of the dead-letter queue from the oldest and their payloads offloaded, until
+//the queue is empty or the context is done. Return the number of jobs purged, a job whose payload
+//could not be deleted is kept in the dead-letter queue
+func (c *Client) Purge(ctx context.Context, queueName string) (int, error) {
+	purged := 0
+	for ctx.Err() == nil {
+		value, err := c.Redis.RPop(queueName).Result()
+		if err == redis.Nil {
+			return purged, nil
+		}
+
+		if err != nil {
+			return purged, err
+		}
+
+		var entry Entry
+		if err := json.Unmarshal([]byte(value), &entry); err == nil {
+			if err := c.ClaimCheck.Delete(ctx, claimcheck.Key(entry.Payload)); err != nil {
+				c.Redis.RPush(queueName, value)
+				return purged, err
+			}
+		}
+
+		purged++
+	}
+
+	return purged, ctx.Err()
+}
+
+//decode return the entry and its payload with the attempts reset
+func decode(value string) (Entry, string, error) {
+	var entry Entry
+	if err := json.Unmarshal([]byte(value), &entry); err != nil {
+		return entry, "", err
+	}
+
+	payload, err := resetAttempts(entry.Payload)
+	return entry, payload, err
+}
+
+//resetAttempts return the envelope with the attempts reset, keeping the numbers of the payload as they are
+func resetAttempts(envelope string) (string, error) {
+	payload, err := envelopes.Decode(envelope)
+	if err != nil {
+		return "", err
+	}
+
+	payload["attempts"] = 0
+	encoded, err := json.Marshal(payload)
+	return string(encoded), err
+}
@@ -0,0 +1,239 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-queue/claimcheck"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+//------------------------- REDIS MOCK ------------------------
+type redisClientMock struct {
+	lists    map[string][]string
+	pushFail string
+}
+
+func (r *redisClientMock) LPush(key string, values ...interface{}) *redis.IntCmd {
+	if key == r.pushFail {
+		return redis.NewIntResult(0, errors.New("LPush"))
+	}
+
+	for _, value := range values {
+		r.lists[key] = append([]string{value.(string)}, r.lists[key]...)
+	}
+
+	return redis.NewIntResult(int64(len(r.lists[key])), nil)
+}
+
+func (r *redisClientMock) RPush(key string, values ...interface{}) *redis.IntCmd {
+	for _, value := range values {
+		r.lists[key] = append(r.lists[key], value.(string))
+	}
+
+	return redis.NewIntResult(int64(len(r.lists[key])), nil)
+}
+
+func (r *redisClientMock) RPop(key string) *redis.StringCmd {
+	list := r.lists[key]
+	if len(list) == 0 {
+		return redis.NewStringResult("", redis.Nil)
+	}
+
+	r.lists[key] = list[:len(list)-1]
+	return redis.NewStringResult(list[len(list)-1], nil)
+}
+
+func (r *redisClientMock) LRange(key string, start, stop int64) *redis.StringSliceCmd {
+	list := r.lists[key]
+	size := int64(len(list))
+	if start < 0 {
+		start += size
+	}
+	if stop < 0 {
+		stop += size
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= size {
+		stop = size - 1
+	}
+	if start > stop {
+		return redis.NewStringSliceResult([]string{}, nil)
+	}
+
+	return redis.NewStringSliceResult(list[start:stop+1], nil)
+}
+
+func (r *redisClientMock) LLen(key string) *redis.IntCmd {
+	return redis.NewIntResult(int64(len(r.lists[key])), nil)
+}
+
+//claimCheckStoreMock keeps the payloads offloaded in memory
+type claimCheckStoreMock struct {
+	payloads map[string][]byte
+	fail     bool
+}
+
+func (s *claimCheckStoreMock) Put(ctx context.Context, key string, data []byte) error {
+	s.payloads[key] = data
+	return nil
+}
+
+func (s *claimCheckStoreMock) Get(ctx context.Context, key string) ([]byte, error) {
+	return s.payloads[key], nil
+}
+
+func (s *claimCheckStoreMock) Delete(ctx context.Context, key string) error {
+	if s.fail {
+		return errors.New("Delete")
+	}
+
+	delete(s.payloads, key)
+	return nil
+}
+
+func newRedisMock(jobIDs ...string) *redisClientMock {
+	redisMock := &redisClientMock{lists: make(map[string][]string)}
+	for _, jobID := range jobIDs {
+		payload, _ := json.Marshal(map[string]interface{}{"id": jobID, "attempts": 3, "amount": 12345678901})
+		Push(redisMock, "queues:test:dead", Entry{QueueName: "queues:test", JobID: jobID, Payload: string(payload), Error: "timeout", Attempts: 3})
+	}
+
+	return redisMock
+}
+
+//------------------------------ TESTS ---------------------------------
+func TestListFromTheOldest(t *testing.T) {
+	client := Client{Redis: newRedisMock("job1", "job2", "job3")}
+
+	if depth, err := client.Depth("queues:test:dead"); err != nil || depth != 3 {
+		t.Errorf("Expected depth equal 3 but got %v %v", depth, err)
+	}
+
+	entries, err := client.List("queues:test:dead", 1, 5)
+	if err != nil || len(entries) != 2 || entries[0].JobID != "job2" || entries[1].JobID != "job3" {
+		t.Errorf("Expected the jobs after the oldest but got %v %v", entries, err)
+	}
+}
+
+func TestRedriveResetAttempts(t *testing.T) {
+	redisMock := newRedisMock("job1", "job2", "job3")
+	client := Client{Redis: redisMock}
+
+	redriven, err := client.Redrive(context.Background(), "queues:test:dead", RedriveOptions{Limit: 2})
+	if err != nil || redriven != 2 {
+		t.Fatalf("Expected 2 jobs redriven but got %v %v", redriven, err)
+	}
+
+	queue := redisMock.lists["queues:test"]
+	if len(queue) != 2 || queue[1] != `{"amount":12345678901,"attempts":0,"id":"job1"}` {
+		t.Errorf("Expected the oldest jobs pushed back with the attempts reset but got %v", queue)
+	}
+
+	if len(redisMock.lists["queues:test:dead"]) != 1 {
+		t.Errorf("Expected the job over the limit kept but got %v", redisMock.lists["queues:test:dead"])
+	}
+}
+
+func TestRedriveRateLimited(t *testing.T) {
+	client := Client{Redis: newRedisMock("job1", "job2", "job3")}
+
+	start := time.Now()
+	redriven, err := client.Redrive(context.Background(), "queues:test:dead", RedriveOptions{PerSecond: 50})
+	if err != nil || redriven != 3 {
+		t.Fatalf("Expected all jobs redriven but got %v %v", redriven, err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Expected jobs redriven at most 50 per second but took %v", elapsed)
+	}
+}
+
+func TestRedriveStopWithContext(t *testing.T) {
+	client := Client{Redis: newRedisMock("job1", "job2")}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	redriven, err := client.Redrive(ctx, "queues:test:dead", RedriveOptions{PerSecond: 1})
+	if err != context.Canceled || redriven != 0 {
+		t.Errorf("Expected redrive stopped by the context but got %v %v", redriven, err)
+	}
+}
+
+func TestRedriveKeepJobNotPushed(t *testing.T) {
+	redisMock := newRedisMock("job1")
+	redisMock.pushFail = "queues:test"
+	client := Client{Redis: redisMock}
+
+	redriven, err := client.Redrive(context.Background(), "queues:test:dead", RedriveOptions{})
+	if err == nil || redriven != 0 {
+		t.Errorf("Expected push error but got %v %v", redriven, err)
+	}
+
+	if len(redisMock.lists["queues:test:dead"]) != 1 {
+		t.Errorf("Expected job kept in the dead-letter queue but got %v", redisMock.lists["queues:test:dead"])
+	}
+}
+
+func TestRedriveMovePoisonEntries(t *testing.T) {
+	redisMock := newRedisMock("job1")
+	redisMock.lists["queues:test:dead"] = append(redisMock.lists["queues:test:dead"], "not json")
+	Push(redisMock, "queues:test:dead", Entry{QueueName: "queues:test", JobID: "job2", Payload: "not json"})
+	client := Client{Redis: redisMock}
+
+	redriven, err := client.Redrive(context.Background(), "queues:test:dead", RedriveOptions{})
+	if err != nil || redriven != 1 {
+		t.Fatalf("Expected the job decoded redriven but got %v %v", redriven, err)
+	}
+
+	if depth, _ := client.Depth("queues:test:dead"); depth != 0 {
+		t.Errorf("Expected dead-letter queue drained but got %v", depth)
+	}
+
+	poison := redisMock.lists[PoisonKey("queues:test:dead")]
+	if len(poison) != 2 || poison[1] != "not json" {
+		t.Errorf("Expected entries that can not be decoded moved apart but got %v", poison)
+	}
+}
+
+func TestPurgeDeleteOffloadedPayloads(t *testing.T) {
+	redisMock := newRedisMock("job-1")
+	store := &claimCheckStoreMock{payloads: map[string][]byte{"job-2-key": []byte("{}"), "other": []byte("{}")}}
+	Push(redisMock, "queues:test:dead", Entry{QueueName: "queues:test", JobID: "job-2",
+		Payload: `{"id":"job-2","attempts":3,"headers":{"claim-check":"job-2-key"}}`})
+
+	client := &Client{Redis: redisMock, ClaimCheck: &claimcheck.Offloader{Store: store}}
+	purged, err := client.Purge(context.Background(), "queues:test:dead")
+	if err != nil || purged != 2 {
+		t.Fatalf("Expected jobs purged but got %v %v", purged, err)
+	}
+
+	if depth, _ := client.Depth("queues:test:dead"); depth != 0 {
+		t.Errorf("Expected dead-letter queue empty but got %v", depth)
+	}
+
+	if _, ok := store.payloads["job-2-key"]; ok || len(store.payloads) != 1 {
+		t.Errorf("Expected only the payload of the job purged deleted but got %v", store.payloads)
+	}
+}
+
+func TestPurgeKeepJobWithPayloadNotDeleted(t *testing.T) {
+	redisMock := newRedisMock()
+	Push(redisMock, "queues:test:dead", Entry{QueueName: "queues:test", JobID: "job",
+		Payload: `{"id":"job","attempts":3,"headers":{"claim-check":"job-key"}}`})
+
+	client := &Client{Redis: redisMock, ClaimCheck: &claimcheck.Offloader{Store: &claimCheckStoreMock{fail: true}}}
+	if purged, err := client.Purge(context.Background(), "queues:test:dead"); err == nil || purged != 0 {
+		t.Errorf("Expected error deleting the payload but got %v %v", purged, err)
+	}
+
+	if depth, _ := client.Depth("queues:test:dead"); depth != 1 {
+		t.Errorf("Expected job kept in the dead-letter queue but got %v", depth)
+	}
+}
@@ -0,0 +1,69 @@
+package delayed
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+//MigrateBatch is the max number of due jobs moved to the queue on each migration
+const MigrateBatch = 100
+
+//migrateScript moves the due jobs of the delayed set to the queue and return the score of the next one, -1 without jobs
+const migrateScript = `
+local due = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, tonumber(ARGV[2]))
+for i = 1, #due do
+	redis.call("ZREM", KEYS[1], due[i])
+	redis.call("LPUSH", KEYS[2], due[i])
+end
+local next = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+if #next == 0 then
+	return -1
+end
+return next[2]`
+
+//RedisInterface is the redis client used to delay the jobs
+type RedisInterface interface {
+	ZAdd(key string, members ...redis.Z) *redis.IntCmd
+	Eval(script string, keys []string, args ...interface{}) *redis.Cmd
+}
+
+//Key return the key of the sorted set with the delayed jobs of the queue, scored by the time they are due
+func Key(queueName string) string {
+	return queueName + ":delayed"
+}
+
+//Push delays the job data in the queue until the delay passed
+func Push(redisClient RedisInterface, queueName string, data string, delay time.Duration) error {
+	due := time.Now().Add(delay)
+	return redisClient.ZAdd(Key(queueName), redis.Z{Score: float64(due.UnixNano() / int64(time.Millisecond)), Member: data}).Err()
+}
+
+//Migrate moves the due jobs of the queue from the delayed set to the queue and
+//return when the next delayed job is due, zero without delayed jobs
+func Migrate(redisClient RedisInterface, queueName string, now time.Time) (time.Time, error) {
+	nowMs := now.UnixNano() / int64(time.Millisecond)
+	result, err := redisClient.Eval(migrateScript, []string{Key(queueName), queueName}, nowMs, MigrateBatch).Result()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var next int64
+	switch value := result.(type) {
+	case string:
+		score, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		next = int64(score)
+	case int64:
+		next = value
+	}
+
+	if next < 0 {
+		return time.Time{}, nil
+	}
+
+	return time.Unix(0, next*int64(time.Millisecond)), nil
+}
@@ -0,0 +1,71 @@
+package delayed
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+type redisClientMock struct {
+	added      []redis.Z
+	evalResult interface{}
+	evalKeys   []string
+}
+
+func (r *redisClientMock) ZAdd(key string, members ...redis.Z) *redis.IntCmd {
+	r.added = append(r.added, members...)
+	return redis.NewIntResult(1, nil)
+}
+
+func (r *redisClientMock) Eval(script string, keys []string, args ...interface{}) *redis.Cmd {
+	r.evalKeys = keys
+	if err, ok := r.evalResult.(error); ok {
+		return redis.NewCmdResult(nil, err)
+	}
+
+	return redis.NewCmdResult(r.evalResult, nil)
+}
+
+func TestPushScoreByDueTime(t *testing.T) {
+	redisClient := &redisClientMock{}
+
+	before := time.Now().Add(time.Minute).UnixNano() / int64(time.Millisecond)
+	err := Push(redisClient, "queues:test", "data", time.Minute)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	if len(redisClient.added) != 1 || redisClient.added[0].Member != "data" || int64(redisClient.added[0].Score) < before {
+		t.Errorf("Expected job delayed by a minute but got %v", redisClient.added)
+	}
+}
+
+func TestMigrateReturnNextDue(t *testing.T) {
+	redisClient := &redisClientMock{evalResult: "1767261600000"}
+
+	next, err := Migrate(redisClient, "queues:test", time.Now())
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	if !next.Equal(time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected next due time but got %v", next)
+	}
+
+	if redisClient.evalKeys[0] != "queues:test:delayed" || redisClient.evalKeys[1] != "queues:test" {
+		t.Errorf("Expected migration from the delayed set to the queue but got %v", redisClient.evalKeys)
+	}
+}
+
+func TestMigrateWithoutDelayedJobs(t *testing.T) {
+	next, err := Migrate(&redisClientMock{evalResult: int64(-1)}, "queues:test", time.Now())
+	if err != nil || !next.IsZero() {
+		t.Errorf("Expected no next due time but got %v %v", next, err)
+	}
+
+	if _, err := Migrate(&redisClientMock{evalResult: errors.New("Eval")}, "queues:test", time.Now()); err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+}
@@ -0,0 +1,134 @@
+package dispatcher
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-queue/cancellation"
+	"go-queue/claimcheck"
+	"go-queue/compression"
+	"go-queue/envelopes"
+	"go-queue/logger"
+	"go-queue/providers"
+	"go-queue/security"
+
+	"github.com/go-redis/redis"
+)
+
+//RedisInterface is the redis client used to push the jobs
+type RedisInterface interface {
+	RPush(key string, values ...interface{}) *redis.IntCmd
+}
+
+//EnvelopeHook changes the job envelope before it is pushed to the queue
+type EnvelopeHook func(ctx context.Context, envelope map[string]interface{}) error
+
+//Dispatcher push jobs to the queues
+type Dispatcher struct {
+	Client      RedisInterface
+	Hooks       []EnvelopeHook
+	Logger      logger.Logger
+	Compression providers.CompressionConfigs
+	Sealer      *security.Sealer
+	ClaimCheck  *claimcheck.Offloader
+}
+
+//Dispatch push the payload to the tail of the queue and return the job ID, the workers pop the queues from the head
+func (d *Dispatcher) Dispatch(ctx context.Context, queueName string, payload map[string]interface{}) (string, error) {
+	envelope := NewEnvelope(payload)
+
+	for _, hook := range d.Hooks {
+		if err := hook(ctx, envelope); err != nil {
+			return "", err
+		}
+	}
+
+	marshaledData, err := json.Marshal(envelope)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := compression.Compress(string(marshaledData), d.Compression)
+	if err != nil {
+		return "", err
+	}
+
+	data, err = d.Sealer.Seal(data)
+	if err != nil {
+		return "", err
+	}
+
+	data, err = d.ClaimCheck.Offload(ctx, data)
+	if err != nil {
+		return "", err
+	}
+
+	err = d.Client.RPush(queueName, data).Err()
+	if err != nil {
+		logger.OrDefault(d.Logger).WithFields(logger.Fields{"queue": queueName, "job_id": envelope["id"]}).Errorf("Error to dispatch job: %v", err)
+		return "", err
+	}
+
+	return envelope["id"].(string), nil
+}
+
+//Cancel cancels the job dispatched, the workers skip it when it is popped and cancel the context
+//of its handler when it is running. The client must be able to set keys, like the redis client
+func (d *Dispatcher) Cancel(jobID string) error {
+	client, ok := d.Client.(cancellation.RedisInterface)
+	if !ok {
+		return errors.New("dispatcher client can not cancel jobs")
+	}
+
+	return cancellation.Cancel(client, jobID, cancellation.DefaultTTL)
+}
+
+//NewEnvelope copy the payload adding the job ID and attempts when they are missing
+func NewEnvelope(payload map[string]interface{}) map[string]interface{} {
+	envelope := make(map[string]interface{})
+	for key, value := range payload {
+		envelope[key] = value
+	}
+
+	if _, ok := envelope["id"].(string); !ok {
+		envelope["id"] = NewJobID()
+	}
+
+	if _, ok := envelope["attempts"]; !ok {
+		envelope["attempts"] = float64(0)
+	}
+
+	return envelope
+}
+
+//Headers return the text headers of the envelope
+func Headers(envelope map[string]interface{}) map[string]string {
+	headers := make(map[string]string)
+	for key, value := range envelopes.Headers(envelope) {
+		if converted, ok := value.(string); ok {
+			headers[key] = converted
+		}
+	}
+
+	return headers
+}
+
+//SetHeader sets a header in the envelope
+func SetHeader(envelope map[string]interface{}, key string, value string) {
+	headers := Headers(envelope)
+	headers[key] = value
+	envelope["headers"] = headers
+}
+
+//NewJobID return a random UUID
+func NewJobID() string {
+	uuid := make([]byte, 16)
+	rand.Read(uuid)
+
+	uuid[6] = (uuid[6] & 0x0f) | 0x40
+	uuid[8] = (uuid[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:])
+}
@@ -0,0 +1,232 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-queue/cancellation"
+	"go-queue/claimcheck"
+	"go-queue/compression"
+	"go-queue/providers"
+	"go-queue/security"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+//------------------------- REDIS MOCK ------------------------
+type redisClientMock struct {
+	key    string
+	values []interface{}
+}
+
+func (r *redisClientMock) RPush(key string, values ...interface{}) *redis.IntCmd {
+	if key == "error" {
+		return redis.NewIntResult(0, errors.New("RPush"))
+	}
+
+	r.key = key
+	r.values = values
+	return redis.NewIntResult(1, nil)
+}
+
+//------------------------------ TESTS ---------------------------------
+func TestDispatchPushEnvelope(t *testing.T) {
+	redisMock := &redisClientMock{}
+	d := Dispatcher{Client: redisMock}
+
+	id, err := d.Dispatch(context.Background(), "queues:test", map[string]interface{}{"name": "test"})
+	if err != nil {
+		t.Fatalf("Expected error is nil but got %v", err)
+	}
+
+	if redisMock.key != "queues:test" {
+		t.Errorf("Expected job pushed to queues:test but got %v", redisMock.key)
+	}
+
+	envelope := make(map[string]interface{})
+	json.Unmarshal([]byte(redisMock.values[0].(string)), &envelope)
+
+	if envelope["id"] != id || envelope["name"] != "test" || envelope["attempts"] != float64(0) {
+		t.Errorf("Envelope pushed is different from expected %v", envelope)
+	}
+}
+
+func TestDispatchRunHooks(t *testing.T) {
+	redisMock := &redisClientMock{}
+	hook := func(ctx context.Context, envelope map[string]interface{}) error {
+		SetHeader(envelope, "test", "value")
+		return nil
+	}
+
+	d := Dispatcher{Client: redisMock, Hooks: []EnvelopeHook{hook}}
+	d.Dispatch(context.Background(), "queues:test", map[string]interface{}{})
+
+	envelope := make(map[string]interface{})
+	json.Unmarshal([]byte(redisMock.values[0].(string)), &envelope)
+
+	if Headers(envelope)["test"] != "value" {
+		t.Errorf("Expected header set by the hook but got %v", envelope)
+	}
+}
+
+func TestDispatchReturnHookError(t *testing.T) {
+	redisMock := &redisClientMock{}
+	hook := func(ctx context.Context, envelope map[string]interface{}) error {
+		return errors.New("Test")
+	}
+
+	d := Dispatcher{Client: redisMock, Hooks: []EnvelopeHook{hook}}
+	_, err := d.Dispatch(context.Background(), "queues:test", map[string]interface{}{})
+
+	if err == nil || err.Error() != "Test" {
+		t.Errorf("Expected hook error but got %v", err)
+	}
+
+	if redisMock.values != nil {
+		t.Errorf("Expected job not pushed when a hook fails")
+	}
+}
+
+func TestDispatchReturnRPushError(t *testing.T) {
+	d := Dispatcher{Client: &redisClientMock{}}
+	_, err := d.Dispatch(context.Background(), "error", map[string]interface{}{})
+
+	if err == nil || err.Error() != "RPush" {
+		t.Errorf("Expected RPush error but got %v", err)
+	}
+}
+
+func TestNewEnvelopeKeepJobID(t *testing.T) {
+	payload := map[string]interface{}{"id": "test", "attempts": float64(2)}
+	envelope := NewEnvelope(payload)
+
+	if envelope["id"] != "test" || envelope["attempts"] != float64(2) {
+		t.Errorf("Expected id and attempts kept but got %v", envelope)
+	}
+}
+
+func TestHeadersFromDecodedJSON(t *testing.T) {
+	envelope := make(map[string]interface{})
+	json.Unmarshal([]byte(`{"headers": {"traceparent": "test", "number": 1}}`), &envelope)
+
+	headers := Headers(envelope)
+
+	if len(headers) != 1 || headers["traceparent"] != "test" {
+		t.Errorf("Expected only string headers but got %v", headers)
+	}
+}
+
+func TestNewJobID(t *testing.T) {
+	id := NewJobID()
+
+	matched, _ := regexp.MatchString("^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$", id)
+	if !matched {
+		t.Errorf("Expected an UUID v4 but got %v", id)
+	}
+
+	if id == NewJobID() {
+		t.Errorf("Expected different IDs")
+	}
+}
+
+type cancelRedisMock struct {
+	redisClientMock
+	cancelled map[string]time.Duration
+}
+
+func (r *cancelRedisMock) Set(key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	r.cancelled[key] = expiration
+	return redis.NewStatusResult("OK", nil)
+}
+
+func TestCancelMarkJobCancelled(t *testing.T) {
+	redisMock := &cancelRedisMock{cancelled: make(map[string]time.Duration)}
+	d := Dispatcher{Client: redisMock}
+
+	if err := d.Cancel("job"); err != nil || redisMock.cancelled["cancelled:job"] != cancellation.DefaultTTL {
+		t.Errorf("Expected job cancelled but got %v %v", err, redisMock.cancelled)
+	}
+
+	if err := (&Dispatcher{Client: &redisClientMock{}}).Cancel("job"); err == nil {
+		t.Errorf("Expected error for client that can not cancel jobs")
+	}
+}
+
+func TestDispatchCompressLargePayload(t *testing.T) {
+	redisMock := &redisClientMock{}
+	d := Dispatcher{Client: redisMock, Compression: providers.CompressionConfigs{Codec: providers.CompressionZstd, Threshold: 100}}
+
+	rows := strings.Repeat("row,", 100)
+	id, err := d.Dispatch(context.Background(), "queues:test", map[string]interface{}{"rows": rows})
+	if err != nil {
+		t.Fatalf("Expected error is nil but got %v", err)
+	}
+
+	pushed := redisMock.values[0].(string)
+	if len(pushed) >= len(rows) || Headers(decodeEnvelope(pushed))[compression.Header] != providers.CompressionZstd {
+		t.Errorf("Expected payload compressed with zstd but got %v", pushed)
+	}
+
+	decompressed, _ := compression.Decompress(pushed)
+	envelope := decodeEnvelope(decompressed)
+	if envelope["id"] != id || envelope["rows"] != rows {
+		t.Errorf("Expected payload decompressed but got %v", envelope)
+	}
+}
+
+func TestDispatchSealPayload(t *testing.T) {
+	redisMock := &redisClientMock{}
+	sealer := &security.Sealer{Encryption: security.Keyring{Keys: map[string][]byte{"key": []byte(strings.Repeat("k", 32))}, Active: "key"}}
+	d := Dispatcher{Client: redisMock, Sealer: sealer}
+
+	_, err := d.Dispatch(context.Background(), "queues:test", map[string]interface{}{"document": "123.456.789-00"})
+	if err != nil {
+		t.Fatalf("Expected error is nil but got %v", err)
+	}
+
+	pushed := redisMock.values[0].(string)
+	if strings.Contains(pushed, "123.456.789-00") || Headers(decodeEnvelope(pushed))[security.KeyIDHeader] != "key" {
+		t.Errorf("Expected payload encrypted but got %v", pushed)
+	}
+}
+
+func TestDispatchOffloadLargePayload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "payloads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	redisMock := &redisClientMock{}
+	offloader := &claimcheck.Offloader{Store: &claimcheck.FileStore{Dir: dir}, Threshold: 100}
+	d := Dispatcher{Client: redisMock, ClaimCheck: offloader}
+
+	rows := strings.Repeat("row,", 100)
+	id, err := d.Dispatch(context.Background(), "queues:test", map[string]interface{}{"rows": rows})
+	if err != nil {
+		t.Fatalf("Expected error is nil but got %v", err)
+	}
+
+	pushed := redisMock.values[0].(string)
+	if strings.Contains(pushed, rows) || Headers(decodeEnvelope(pushed))[claimcheck.Header] == "" {
+		t.Errorf("Expected claim check of the payload pushed but got %v", pushed)
+	}
+
+	fetched, _, _ := offloader.Fetch(context.Background(), pushed)
+	envelope := decodeEnvelope(fetched)
+	if envelope["id"] != id || envelope["rows"] != rows {
+		t.Errorf("Expected payload fetched from the store but got %v", envelope)
+	}
+}
+
+func decodeEnvelope(data string) map[string]interface{} {
+	envelope := make(map[string]interface{})
+	json.Unmarshal([]byte(data), &envelope)
+	return envelope
+}
@@ -0,0 +1,34 @@
+package envelopes
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+//Decode decodes the envelope keeping the numbers as they are
+func Decode(data string) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+
+	envelope := make(map[string]interface{})
+	err := decoder.Decode(&envelope)
+	return envelope, err
+}
+
+//Headers return a copy of the headers of the envelope, empty without headers
+func Headers(envelope map[string]interface{}) map[string]interface{} {
+	headers := make(map[string]interface{})
+
+	switch values := envelope["headers"].(type) {
+	case map[string]string:
+		for key, value := range values {
+			headers[key] = value
+		}
+	case map[string]interface{}:
+		for key, value := range values {
+			headers[key] = value
+		}
+	}
+
+	return headers
+}
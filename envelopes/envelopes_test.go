@@ -0,0 +1,40 @@
+package envelopes
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+//------------------------------ TESTS ---------------------------------
+func TestDecodeKeepNumbers(t *testing.T) {
+	envelope, err := Decode(`{"id":"job","amount":12345678901234567890}`)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	if amount, ok := envelope["amount"].(json.Number); !ok || amount.String() != "12345678901234567890" {
+		t.Errorf("Expected number kept as it is but got %v", envelope["amount"])
+	}
+
+	if _, err := Decode("not json"); err == nil {
+		t.Errorf("Expected an error for data that is not an envelope")
+	}
+}
+
+func TestHeadersCopy(t *testing.T) {
+	envelope, _ := Decode(`{"id":"job","headers":{"claim-check":"key"}}`)
+
+	headers := Headers(envelope)
+	headers["content-encoding"] = "gzip"
+	if len(headers) != 2 || len(envelope["headers"].(map[string]interface{})) != 1 {
+		t.Errorf("Expected copy of the headers but got %v %v", headers, envelope["headers"])
+	}
+
+	if headers := Headers(map[string]interface{}{"headers": map[string]string{"traceparent": "00"}}); headers["traceparent"] != "00" {
+		t.Errorf("Expected headers set by the dispatcher but got %v", headers)
+	}
+
+	if headers := Headers(map[string]interface{}{"id": "job"}); len(headers) != 0 {
+		t.Errorf("Expected no headers but got %v", headers)
+	}
+}
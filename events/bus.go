@@ -0,0 +1,81 @@
+package events
+
+import "sync"
+
+//AllEvents subscribes a listener to every event
+const AllEvents = "*"
+
+//Listener is a function called when an event is emitted
+type Listener func(Event)
+
+type subscription struct {
+	listener Listener
+	async    bool
+}
+
+//Bus dispatches the events to the subscribed listeners
+type Bus struct {
+	mutex         sync.RWMutex
+	subscriptions map[string][]subscription
+	pending       sync.WaitGroup
+}
+
+//NewBus return an empty events bus
+func NewBus() *Bus {
+	return &Bus{subscriptions: make(map[string][]subscription)}
+}
+
+//Subscribe register a listener called in the worker goroutine before it continues
+func (bus *Bus) Subscribe(eventName string, listener Listener) {
+	bus.subscribe(eventName, subscription{listener: listener})
+}
+
+//SubscribeAsync register a listener called in its own goroutine
+func (bus *Bus) SubscribeAsync(eventName string, listener Listener) {
+	bus.subscribe(eventName, subscription{listener: listener, async: true})
+}
+
+//Emit send the event to the listeners of its name and to the listeners of all events
+func (bus *Bus) Emit(event Event) {
+	if bus == nil {
+		return
+	}
+
+	bus.mutex.RLock()
+	subscriptions := append([]subscription{}, bus.subscriptions[event.Name()]...)
+	subscriptions = append(subscriptions, bus.subscriptions[AllEvents]...)
+	bus.mutex.RUnlock()
+
+	for _, sub := range subscriptions {
+		if !sub.async {
+			sub.listener(event)
+			continue
+		}
+
+		bus.pending.Add(1)
+		go func(listener Listener) {
+			defer bus.pending.Done()
+			listener(event)
+		}(sub.listener)
+	}
+}
+
+//Wait blocks until all async listeners have returned
+func (bus *Bus) Wait() {
+	if bus == nil {
+		return
+	}
+
+	bus.pending.Wait()
+}
+
+func (bus *Bus) subscribe(eventName string, sub subscription) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	if bus.subscriptions == nil {
+		bus.subscriptions = make(map[string][]subscription)
+	}
+
+	bus.subscriptions[eventName] = append(bus.subscriptions[eventName], sub)
+}
@@ -0,0 +1,85 @@
+package events
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestEmitCallSyncListeners(t *testing.T) {
+	bus := NewBus()
+
+	var received []Event
+	bus.Subscribe(JobFailedEvent, func(event Event) {
+		received = append(received, event)
+	})
+
+	bus.Emit(JobFailed{Job: Job{QueueName: "test"}, Err: errors.New("Test")})
+	bus.Emit(JobProcessed{Job: Job{QueueName: "test"}})
+
+	if len(received) != 1 {
+		t.Fatalf("Expected 1 event received but got %v", len(received))
+	}
+
+	failed := received[0].(JobFailed)
+	if failed.QueueName != "test" || failed.Err.Error() != "Test" {
+		t.Errorf("Event received is different from emitted %v", failed)
+	}
+}
+
+func TestEmitCallAsyncListeners(t *testing.T) {
+	bus := NewBus()
+
+	var mutex sync.Mutex
+	count := 0
+	bus.SubscribeAsync(JobProcessedEvent, func(event Event) {
+		mutex.Lock()
+		count++
+		mutex.Unlock()
+	})
+
+	bus.Emit(JobProcessed{})
+	bus.Emit(JobProcessed{})
+	bus.Wait()
+
+	if count != 2 {
+		t.Errorf("Expected 2 events received but got %v", count)
+	}
+}
+
+func TestEmitCallAllEventsListeners(t *testing.T) {
+	bus := NewBus()
+
+	var names []string
+	bus.Subscribe(AllEvents, func(event Event) {
+		names = append(names, event.Name())
+	})
+
+	bus.Emit(JobReserved{})
+	bus.Emit(WorkerStopping{QueueName: "test"})
+
+	if len(names) != 2 || names[0] != JobReservedEvent || names[1] != WorkerStoppingEvent {
+		t.Errorf("Expected JobReserved and WorkerStopping events but got %v", names)
+	}
+}
+
+func TestEmitInNilBus(t *testing.T) {
+	var bus *Bus
+
+	bus.Emit(JobReserved{})
+	bus.Wait()
+}
+
+func TestSubscribeInZeroValueBus(t *testing.T) {
+	bus := Bus{}
+
+	called := false
+	bus.Subscribe(JobReservedEvent, func(event Event) {
+		called = true
+	})
+	bus.Emit(JobReserved{})
+
+	if !called {
+		t.Errorf("Expected listener to be called")
+	}
+}
@@ -0,0 +1,136 @@
+package events
+
+import (
+	"context"
+	"time"
+)
+
+//Names of the events emitted by the workers
+const (
+	JobReservedEvent          = "JobReserved"
+	JobProcessingEvent        = "JobProcessing"
+	JobProcessedEvent         = "JobProcessed"
+	JobRetryingEvent          = "JobRetrying"
+	JobFailedEvent            = "JobFailed"
+	JobExceptionOccurredEvent = "JobExceptionOccurred"
+	JobUnroutedEvent          = "JobUnrouted"
+	JobReleasedEvent          = "JobReleased"
+	JobCancelledEvent         = "JobCancelled"
+	JobProgressEvent          = "JobProgress"
+	WorkerStoppingEvent       = "WorkerStopping"
+)
+
+//Event is implemented by all events emitted by the workers
+type Event interface {
+	Name() string
+}
+
+//Job is the data of the job that emitted the event
+type Job struct {
+	Context   context.Context
+	QueueName string
+	JobType   string
+	JobID     string
+	Attempt   float64
+	Payload   map[string]interface{}
+}
+
+//JobReserved is emitted when a job is taken from the queue
+type JobReserved struct {
+	Job
+}
+
+//JobProcessing is emitted before the job handler is called
+type JobProcessing struct {
+	Job
+}
+
+//JobProcessed is emitted when the job handler finished without error
+type JobProcessed struct {
+	Job
+	Duration time.Duration
+}
+
+//JobExceptionOccurred is emitted when the job handler returned an error
+type JobExceptionOccurred struct {
+	Job
+	Duration time.Duration
+	Err      error
+}
+
+//JobRetrying is emitted when a failed job is requeued to be tried again
+type JobRetrying struct {
+	Job
+	Err error
+}
+
+//JobFailed is emitted when a job ran out of attempts and is moved to failed_jobs
+type JobFailed struct {
+	Job
+	Err error
+}
+
+//JobUnrouted is emitted when there is no handler for the type of the job, with the fallback applied
+type JobUnrouted struct {
+	Job
+	Fallback string
+}
+
+//JobReleased is emitted when a job is released back to the queue with a delay without consuming an attempt
+type JobReleased struct {
+	Job
+	Delay  time.Duration
+	Reason string
+}
+
+//JobCancelled is emitted when a cancelled job is skipped or its running handler returned after the cancellation
+type JobCancelled struct {
+	Job
+	Running bool
+}
+
+//JobProgress is emitted when the handler of a running job reports its progress
+type JobProgress struct {
+	Job
+	Percent float64
+	Message string
+}
+
+//WorkerStopping is emitted when the listener of a queue stops
+type WorkerStopping struct {
+	QueueName string
+	Err       error
+}
+
+//Name return the event name
+func (e JobReserved) Name() string { return JobReservedEvent }
+
+//Name return the event name
+func (e JobProcessing) Name() string { return JobProcessingEvent }
+
+//Name return the event name
+func (e JobProcessed) Name() string { return JobProcessedEvent }
+
+//Name return the event name
+func (e JobExceptionOccurred) Name() string { return JobExceptionOccurredEvent }
+
+//Name return the event name
+func (e JobRetrying) Name() string { return JobRetryingEvent }
+
+//Name return the event name
+func (e JobFailed) Name() string { return JobFailedEvent }
+
+//Name return the event name
+func (e JobUnrouted) Name() string { return JobUnroutedEvent }
+
+//Name return the event name
+func (e JobReleased) Name() string { return JobReleasedEvent }
+
+//Name return the event name
+func (e JobCancelled) Name() string { return JobCancelledEvent }
+
+//Name return the event name
+func (e JobProgress) Name() string { return JobProgressEvent }
+
+//Name return the event name
+func (e WorkerStopping) Name() string { return WorkerStoppingEvent }
@@ -0,0 +1,111 @@
+package health
+
+import (
+	"encoding/json"
+	"go-queue/managers/listenersManager"
+	"net/http"
+)
+
+//ConnectionsChecker checks the health of the database clients
+type ConnectionsChecker interface {
+	CheckConnection(name string) error
+}
+
+//StatusProvider return the state of the listeners
+type StatusProvider interface {
+	Statuses() []listenersManager.ListenerStatus
+}
+
+//Server answers the health checks of the orchestrator
+type Server struct {
+	ConnManager ConnectionsChecker
+	Connections []string
+	Queues      []string
+	Status      StatusProvider
+}
+
+//Readiness is the body of the /readyz response
+type Readiness struct {
+	Ready       bool              `json:"ready"`
+	Connections map[string]string `json:"connections"`
+	Listeners   map[string]string `json:"listeners"`
+}
+
+//Handler return the http handler with the /healthz, /readyz and /status paths
+func (server *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", server.Healthz)
+	mux.HandleFunc("/readyz", server.Readyz)
+	mux.HandleFunc("/status", server.StatusHandler)
+
+	return mux
+}
+
+//Serve answers the health checks in the address
+func (server *Server) Serve(addr string) error {
+	return http.ListenAndServe(addr, server.Handler())
+}
+
+//Healthz answers that the process is alive
+func (server *Server) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+//Readyz answers if the required connections are reachable and all listeners are running
+func (server *Server) Readyz(w http.ResponseWriter, r *http.Request) {
+	readiness := server.Readiness()
+
+	statusCode := http.StatusOK
+	if !readiness.Ready {
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	writeJSON(w, statusCode, readiness)
+}
+
+//StatusHandler answers the state of each listener
+func (server *Server) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, server.Status.Statuses())
+}
+
+//Readiness checks the connections and the listeners
+func (server *Server) Readiness() Readiness {
+	readiness := Readiness{
+		Ready:       true,
+		Connections: make(map[string]string),
+		Listeners:   make(map[string]string),
+	}
+
+	for _, name := range server.Connections {
+		if err := server.ConnManager.CheckConnection(name); err != nil {
+			readiness.Ready = false
+			readiness.Connections[name] = err.Error()
+			continue
+		}
+
+		readiness.Connections[name] = "ok"
+	}
+
+	running := make(map[string]bool)
+	for _, status := range server.Status.Statuses() {
+		running[status.QueueName] = status.Running
+	}
+
+	for _, queue := range server.Queues {
+		if !running[queue] {
+			readiness.Ready = false
+			readiness.Listeners[queue] = "stopped"
+			continue
+		}
+
+		readiness.Listeners[queue] = "running"
+	}
+
+	return readiness
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,122 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"go-queue/managers/listenersManager"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+//------------------------- MOCK FUNCTIONS ---------------------
+type connectionsCheckerMock struct {
+	down string
+}
+
+func (c connectionsCheckerMock) CheckConnection(name string) error {
+	if name == c.down {
+		return errors.New("Test")
+	}
+
+	return nil
+}
+
+func newStatus(running ...string) *listenersManager.StatusRegistry {
+	status := listenersManager.NewStatusRegistry()
+	for _, queue := range running {
+		status.Started(queue, "redis")
+	}
+
+	return status
+}
+
+func request(server *Server, path string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+	return recorder
+}
+
+//------------------------------ TESTS ---------------------------------
+func TestHealthz(t *testing.T) {
+	recorder := request(&Server{}, "/healthz")
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status 200 but got %v", recorder.Code)
+	}
+}
+
+func TestReadyzReturnOk(t *testing.T) {
+	server := &Server{
+		ConnManager: connectionsCheckerMock{},
+		Connections: []string{"redis"},
+		Queues:      []string{"queues:test"},
+		Status:      newStatus("queues:test"),
+	}
+
+	recorder := request(server, "/readyz")
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status 200 but got %v", recorder.Code)
+	}
+}
+
+func TestReadyzReturnUnavailableWhenConnectionIsDown(t *testing.T) {
+	server := &Server{
+		ConnManager: connectionsCheckerMock{down: "mysql"},
+		Connections: []string{"redis", "mysql"},
+		Queues:      []string{"queues:test"},
+		Status:      newStatus("queues:test"),
+	}
+
+	recorder := request(server, "/readyz")
+
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 but got %v", recorder.Code)
+	}
+
+	readiness := Readiness{}
+	json.NewDecoder(recorder.Body).Decode(&readiness)
+
+	if readiness.Ready || readiness.Connections["mysql"] != "Test" || readiness.Connections["redis"] != "ok" {
+		t.Errorf("Readiness is different from expected %v", readiness)
+	}
+}
+
+func TestReadyzReturnUnavailableWhenListenerStopped(t *testing.T) {
+	status := newStatus("queues:test", "queues:other")
+	status.Stopped("queues:other", errors.New("BLPop"))
+
+	server := &Server{
+		ConnManager: connectionsCheckerMock{},
+		Queues:      []string{"queues:test", "queues:other", "queues:never"},
+		Status:      status,
+	}
+
+	readiness := server.Readiness()
+
+	if readiness.Ready {
+		t.Errorf("Expected not ready when a listener is stopped")
+	}
+
+	expected := map[string]string{"queues:test": "running", "queues:other": "stopped", "queues:never": "stopped"}
+	for queue, state := range expected {
+		if readiness.Listeners[queue] != state {
+			t.Errorf("Expected listener %v %v but got %v", queue, state, readiness.Listeners[queue])
+		}
+	}
+}
+
+func TestStatusReturnListeners(t *testing.T) {
+	status := newStatus("queues:test")
+	status.Stopped("queues:test", errors.New("BLPop"))
+
+	recorder := request(&Server{Status: status}, "/status")
+
+	var statuses []listenersManager.ListenerStatus
+	json.NewDecoder(recorder.Body).Decode(&statuses)
+
+	if len(statuses) != 1 || statuses[0].QueueName != "queues:test" || statuses[0].Running || statuses[0].LastError != "BLPop" {
+		t.Errorf("Status is different from expected %v", statuses)
+	}
+}
@@ -1,8 +1,14 @@
 package interfaces
 
 import (
+	"go-queue/breaker"
+	"go-queue/claimcheck"
+	"go-queue/events"
+	"go-queue/logger"
 	connectionsmanager "go-queue/managers/connectionsManager"
 	"go-queue/providers"
+	"go-queue/results"
+	"go-queue/security"
 	"time"
 
 	"github.com/go-redis/redis"
@@ -13,6 +19,10 @@ type RedisInterface interface {
 	LLen(string) *redis.IntCmd
 	BLPop(time.Duration, ...string) *redis.StringSliceCmd
 	LPush(key string, values ...interface{}) *redis.IntCmd
+	RPush(key string, values ...interface{}) *redis.IntCmd
+	ZAdd(key string, members ...redis.Z) *redis.IntCmd
+	Eval(script string, keys []string, args ...interface{}) *redis.Cmd
+	Exists(keys ...string) *redis.IntCmd
 }
 
 //JobsManagerInterface is a interface for JobsManager
@@ -24,5 +34,22 @@ type JobsManagerInterface interface {
 	SetClient(client interface{})
 	SetQueueData(queueData interface{})
 	GetQueueData() interface{}
+	SetReservation(token string)
+	GetReservation() string
+	SetMiddlewares(middlewares []providers.Middleware)
+	GetMiddlewares() []providers.Middleware
+	SetEvents(bus *events.Bus)
+	GetEvents() *events.Bus
+	SetLogger(log logger.Logger)
+	GetLogger() logger.Logger
+	SetBreakers(breakers *breaker.Breakers)
+	GetBreakers() *breaker.Breakers
+	SetResults(store results.Store)
+	GetResults() results.Store
+	SetSealer(sealer *security.Sealer)
+	GetSealer() *security.Sealer
+	SetClaimCheck(offloader *claimcheck.Offloader)
+	GetClaimCheck() *claimcheck.Offloader
+	WorkerStopping(err error)
 	CallDynamically() error
 }
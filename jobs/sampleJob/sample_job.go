@@ -1,6 +1,8 @@
 package sampleJob
 
-//Write the jobs with a function that has the same params type and return of this function Handle
+//Write the jobs with a function that has the same params type and return of this function Handle,
+//a context.Context can be received as first param to get the trace of the job and a result can be
+//returned before the error to be passed to the next job of a chain
 func Handle(queueData interface{}, connections map[string]interface{}) error {
 	return nil
 }
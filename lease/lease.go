@@ -0,0 +1,292 @@
+package lease
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+//DefaultProgressTTL is the time the progress of a job is kept after its last report
+const DefaultProgressTTL = 24 * time.Hour
+
+//ReclaimBatch is the max number of abandoned jobs pushed back to the queue on each reclaim
+const ReclaimBatch = 100
+
+const progressKeyPrefix = "progress:"
+
+//reserveScript reserves the job popped by the token until the expiration
+const reserveScript = `
+redis.call("ZADD", KEYS[1], ARGV[2], ARGV[1])
+return redis.call("HSET", KEYS[2], ARGV[1], ARGV[3])`
+
+const extendScript = `return redis.call("ZADD", KEYS[1], "XX", "CH", ARGV[1], ARGV[2])`
+
+//releaseScript removes the reservation of the token
+const releaseScript = `
+redis.call("HDEL", KEYS[2], ARGV[1])
+return redis.call("ZREM", KEYS[1], ARGV[1])`
+
+//reclaimScript pushes the jobs with the lease expired back to the queue and return how many were pushed
+const reclaimScript = `
+local expired = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, tonumber(ARGV[2]))
+local reclaimed = 0
+for i = 1, #expired do
+	local data = redis.call("HGET", KEYS[2], expired[i])
+	redis.call("ZREM", KEYS[1], expired[i])
+	redis.call("HDEL", KEYS[2], expired[i])
+	if data then
+		redis.call("LPUSH", KEYS[3], data)
+		reclaimed = reclaimed + 1
+	end
+end
+return reclaimed`
+
+const progressScript = `return redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])`
+
+//ErrNotFound is returned for a job without progress reported or when its progress expired
+var ErrNotFound = errors.New("progress not found")
+
+//RedisInterface is the redis client used by the workers to reserve the running jobs
+type RedisInterface interface {
+	Eval(script string, keys []string, args ...interface{}) *redis.Cmd
+}
+
+//ProgressInterface is the redis client used to read the progress of the jobs
+type ProgressInterface interface {
+	Get(key string) *redis.StringCmd
+}
+
+//Key return the key of the sorted set with the reservation tokens of the running jobs of the queue, scored by the time their lease expires
+func Key(queueName string) string {
+	return queueName + ":reserved"
+}
+
+//JobsKey return the key of the hash with the data of the running jobs of the queue by their reservation tokens
+func JobsKey(queueName string) string {
+	return queueName + ":reserved:jobs"
+}
+
+//ProgressKey return the key of the progress of the job
+func ProgressKey(jobID string) string {
+	return progressKeyPrefix + jobID
+}
+
+//Reserve reserves the job popped from the queue for the timeout. Return the token of the reservation,
+//unique to each pop, so the same job popped again, like when it is reclaimed, is reserved apart
+func Reserve(client RedisInterface, queueName string, data string, timeout time.Duration) (string, error) {
+	token := newToken(data)
+	err := client.Eval(reserveScript, []string{Key(queueName), JobsKey(queueName)}, token, expiration(timeout), data).Err()
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+//Extend extends the reservation of the token for the timeout, a released reservation is not reserved again
+func Extend(client RedisInterface, queueName string, token string, timeout time.Duration) error {
+	return client.Eval(extendScript, []string{Key(queueName)}, expiration(timeout), token).Err()
+}
+
+//Release removes the reservation of the token when the job finished
+func Release(client RedisInterface, queueName string, token string) error {
+	return client.Eval(releaseScript, []string{Key(queueName), JobsKey(queueName)}, token).Err()
+}
+
+//Reclaim pushes the jobs of the queue whose lease expired back to the queue, return how many were pushed
+func Reclaim(client RedisInterface, queueName string, now time.Time) (int64, error) {
+	nowMs := now.UnixNano() / int64(time.Millisecond)
+	result, err := client.Eval(reclaimScript, []string{Key(queueName), JobsKey(queueName), queueName}, nowMs, ReclaimBatch).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	reclaimed, _ := result.(int64)
+	return reclaimed, nil
+}
+
+//newToken return a random token prefixed by the ID of the job
+func newToken(data string) string {
+	random := make([]byte, 16)
+	rand.Read(random)
+
+	var envelope struct {
+		ID interface{} `json:"id"`
+	}
+
+	if err := json.Unmarshal([]byte(data), &envelope); err == nil {
+		if id, ok := envelope.ID.(string); ok && id != "" {
+			return id + "-" + hex.EncodeToString(random)
+		}
+	}
+
+	return hex.EncodeToString(random)
+}
+
+func expiration(timeout time.Duration) int64 {
+	return time.Now().Add(timeout).UnixNano() / int64(time.Millisecond)
+}
+
+//Progress is the progress reported by the handler of a job
+type Progress struct {
+	JobID     string    `json:"job_id"`
+	QueueName string    `json:"queue"`
+	Percent   float64   `json:"percent"`
+	Message   string    `json:"message,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+//SaveProgress stores the progress of the job for the ttl
+func SaveProgress(client RedisInterface, progress Progress, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = DefaultProgressTTL
+	}
+
+	encoded, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+
+	return client.Eval(progressScript, []string{ProgressKey(progress.JobID)}, string(encoded), int64(ttl/time.Millisecond)).Err()
+}
+
+//GetProgress return the last progress reported by the job, ErrNotFound without progress
+func GetProgress(client ProgressInterface, jobID string) (Progress, error) {
+	var progress Progress
+
+	encoded, err := client.Get(ProgressKey(jobID)).Result()
+	if err == redis.Nil {
+		return progress, ErrNotFound
+	}
+
+	if err != nil {
+		return progress, err
+	}
+
+	err = json.Unmarshal([]byte(encoded), &progress)
+	return progress, err
+}
+
+//Heartbeat keeps the lease of a running job and reports its progress. The Token of the reservation
+//is empty for the jobs not leased, the Extensions extend the other leases of the job, like its concurrency slot
+type Heartbeat struct {
+	Redis      RedisInterface
+	QueueName  string
+	JobID      string
+	Token      string
+	Timeout    time.Duration
+	Extensions []func() error
+	OnProgress func(Progress)
+
+	mutex sync.Mutex
+}
+
+//Beat extends the lease of the job and its other leases
+func (h *Heartbeat) Beat() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.Token != "" {
+		if err := Extend(h.Redis, h.QueueName, h.Token, h.Timeout); err != nil {
+			return err
+		}
+	}
+
+	for _, extend := range h.Extensions {
+		if err := extend(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//Report stores the progress of the job and extends its lease
+func (h *Heartbeat) Report(percent float64, message string) error {
+	progress := Progress{JobID: h.JobID, QueueName: h.QueueName, Percent: percent, Message: message, UpdatedAt: time.Now()}
+
+	if h.JobID != "" {
+		if err := SaveProgress(h.Redis, progress, DefaultProgressTTL); err != nil {
+			return err
+		}
+	}
+
+	if h.OnProgress != nil {
+		h.OnProgress(progress)
+	}
+
+	return h.Beat()
+}
+
+//Start extends the lease each interval until the returned function is called,
+//the errors of the extensions are passed to onError
+func (h *Heartbeat) Start(interval time.Duration, onError func(error)) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := h.Beat(); err != nil && onError != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+		})
+	}
+}
+
+type contextKey struct{}
+
+//NewContext return the context of the handler with the heartbeat of the job
+func NewContext(ctx context.Context, heartbeat *Heartbeat) context.Context {
+	return context.WithValue(ctx, contextKey{}, heartbeat)
+}
+
+//FromContext return the heartbeat of the job of the handler, false when the job runs without heartbeat
+func FromContext(ctx context.Context) (*Heartbeat, bool) {
+	heartbeat, ok := ctx.Value(contextKey{}).(*Heartbeat)
+	return heartbeat, ok
+}
+
+//Beat extends the leases of the job of the handler, the handlers of jobs without heartbeat are ignored
+func Beat(ctx context.Context) error {
+	heartbeat, ok := FromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	return heartbeat.Beat()
+}
+
+//Report reports the progress of the job of the handler and extends its leases,
+//the handlers of jobs without heartbeat are ignored
+func Report(ctx context.Context, percent float64, message string) error {
+	heartbeat, ok := FromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	return heartbeat.Report(percent, message)
+}
@@ -0,0 +1,275 @@
+package lease
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis"
+)
+
+//------------------------- REDIS MOCK ------------------------
+type evalCall struct {
+	script string
+	keys   []string
+	args   []interface{}
+}
+
+type redisClientMock struct {
+	mutex  sync.Mutex
+	calls  []evalCall
+	result interface{}
+	values map[string]string
+}
+
+func (r *redisClientMock) Eval(script string, keys []string, args ...interface{}) *redis.Cmd {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.calls = append(r.calls, evalCall{script: script, keys: keys, args: args})
+	if strings.Contains(script, "SET") {
+		r.values[keys[0]] = args[0].(string)
+	}
+
+	return redis.NewCmdResult(r.result, nil)
+}
+
+func (r *redisClientMock) Get(key string) *redis.StringCmd {
+	value, ok := r.values[key]
+	if !ok {
+		return redis.NewStringResult("", redis.Nil)
+	}
+
+	return redis.NewStringResult(value, nil)
+}
+
+func (r *redisClientMock) extensions() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	extensions := 0
+	for _, call := range r.calls {
+		if call.script == extendScript {
+			extensions++
+		}
+	}
+
+	return extensions
+}
+
+func newRedisMock() *redisClientMock {
+	return &redisClientMock{result: int64(1), values: make(map[string]string)}
+}
+
+func newMiniredis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return server, redis.NewClient(&redis.Options{Addr: server.Addr()})
+}
+
+//------------------------------ TESTS ---------------------------------
+func TestReserveJobsByToken(t *testing.T) {
+	server, redisClient := newMiniredis(t)
+	defer server.Close()
+
+	before := time.Now().Add(time.Minute).UnixNano() / int64(time.Millisecond)
+	first, err := Reserve(redisClient, "queues:leased", `{"id":"job","rows":1}`, time.Minute)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	second, _ := Reserve(redisClient, "queues:leased", `{"id":"job","rows":1}`, time.Minute)
+	if first == second || !strings.HasPrefix(first, "job-") {
+		t.Errorf("Expected unique tokens prefixed by the job ID but got %v %v", first, second)
+	}
+
+	reserved, _ := redisClient.ZRangeWithScores(Key("queues:leased"), 0, -1).Result()
+	members := make(map[interface{}]bool)
+	for _, member := range reserved {
+		members[member.Member] = int64(member.Score) >= before
+	}
+
+	if len(members) != 2 || !members[first] || !members[second] {
+		t.Errorf("Expected each pop of the same job reserved until the timeout but got %v", reserved)
+	}
+
+	if data := redisClient.HGet(JobsKey("queues:leased"), first).Val(); data != `{"id":"job","rows":1}` {
+		t.Errorf("Expected data of the reserved job kept but got %v", data)
+	}
+
+	if token, _ := Reserve(redisClient, "queues:leased", "not json", time.Minute); len(token) != 32 {
+		t.Errorf("Expected random token for the job without ID but got %v", token)
+	}
+}
+
+func TestExtendAndReleaseReservation(t *testing.T) {
+	server, redisClient := newMiniredis(t)
+	defer server.Close()
+
+	token, _ := Reserve(redisClient, "queues:test", `{"id":"job","attempts":0}`, time.Second)
+	if err := Extend(redisClient, "queues:test", token, time.Hour); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	if score := redisClient.ZScore(Key("queues:test"), token).Val(); int64(score) < time.Now().Add(time.Minute).UnixNano()/int64(time.Millisecond) {
+		t.Errorf("Expected lease extended but got %v", score)
+	}
+
+	Release(redisClient, "queues:test", token)
+	if redisClient.ZCard(Key("queues:test")).Val() != 0 || redisClient.HLen(JobsKey("queues:test")).Val() != 0 {
+		t.Errorf("Expected reservation released")
+	}
+
+	Extend(redisClient, "queues:test", token, time.Hour)
+	if redisClient.ZCard(Key("queues:test")).Val() != 0 {
+		t.Errorf("Expected released job not reserved again")
+	}
+}
+
+func TestReleaseKeepReservationOfJobReclaimed(t *testing.T) {
+	server, redisClient := newMiniredis(t)
+	defer server.Close()
+
+	abandoned, _ := Reserve(redisClient, "queues:test", `{"id":"job"}`, time.Millisecond)
+	Reclaim(redisClient, "queues:test", time.Now().Add(time.Minute))
+
+	data := redisClient.LPop("queues:test").Val()
+	running, _ := Reserve(redisClient, "queues:test", data, time.Hour)
+
+	Release(redisClient, "queues:test", abandoned)
+	if reserved := redisClient.ZRange(Key("queues:test"), 0, -1).Val(); len(reserved) != 1 || reserved[0] != running {
+		t.Errorf("Expected reservation of the job popped again kept but got %v", reserved)
+	}
+}
+
+func TestReclaim(t *testing.T) {
+	server, redisClient := newMiniredis(t)
+	defer server.Close()
+
+	Reserve(redisClient, "queues:test", `{"id":"abandoned"}`, time.Minute)
+	running, _ := Reserve(redisClient, "queues:test", `{"id":"running"}`, time.Hour)
+
+	reclaimed, err := Reclaim(redisClient, "queues:test", time.Now().Add(2*time.Minute))
+	if err != nil || reclaimed != 1 {
+		t.Fatalf("Expected 1 job reclaimed but got %v %v", reclaimed, err)
+	}
+
+	if queue := redisClient.LRange("queues:test", 0, -1).Val(); len(queue) != 1 || queue[0] != `{"id":"abandoned"}` {
+		t.Errorf("Expected abandoned job pushed back to the queue but got %v", queue)
+	}
+
+	if reserved := redisClient.ZRange(Key("queues:test"), 0, -1).Val(); len(reserved) != 1 || reserved[0] != running {
+		t.Errorf("Expected running job kept reserved but got %v", reserved)
+	}
+
+	if data := redisClient.HKeys(JobsKey("queues:test")).Val(); len(data) != 1 || data[0] != running {
+		t.Errorf("Expected data of the abandoned job removed but got %v", data)
+	}
+}
+
+func TestHeartbeatReportProgress(t *testing.T) {
+	redisMock := newRedisMock()
+
+	var reported []Progress
+	heartbeat := &Heartbeat{Redis: redisMock, QueueName: "queues:test", JobID: "job", Token: "job-token", Timeout: time.Minute,
+		OnProgress: func(progress Progress) { reported = append(reported, progress) }}
+
+	ctx := NewContext(context.Background(), heartbeat)
+	if err := Report(ctx, 40, "rows imported"); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	progress, err := GetProgress(redisMock, "job")
+	if err != nil || progress.Percent != 40 || progress.Message != "rows imported" || progress.QueueName != "queues:test" {
+		t.Errorf("Expected progress stored but got %v %v", progress, err)
+	}
+
+	if len(reported) != 1 || redisMock.extensions() != 1 {
+		t.Errorf("Expected progress reported and lease extended but got %v %v", reported, redisMock.extensions())
+	}
+
+	if _, err := GetProgress(redisMock, "other"); err != ErrNotFound {
+		t.Errorf("Expected not found error but got %v", err)
+	}
+}
+
+func TestHeartbeatWithoutLease(t *testing.T) {
+	if err := Report(context.Background(), 50, ""); err != nil {
+		t.Errorf("Expected jobs not leased ignored but got %v", err)
+	}
+
+	if err := Beat(context.Background()); err != nil {
+		t.Errorf("Expected jobs not leased ignored but got %v", err)
+	}
+}
+
+func TestHeartbeatBeatExtensions(t *testing.T) {
+	redisMock := newRedisMock()
+	slots := 0
+	heartbeat := &Heartbeat{Redis: redisMock, QueueName: "queues:test", Timeout: time.Minute,
+		Extensions: []func() error{func() error { slots++; return nil }}}
+
+	if err := heartbeat.Beat(); err != nil || slots != 1 || redisMock.extensions() != 0 {
+		t.Errorf("Expected only the extensions of the job not leased run but got %v %v %v", err, slots, redisMock.extensions())
+	}
+
+	heartbeat.Token = "job-token"
+	heartbeat.Extensions = append(heartbeat.Extensions, func() error { return errors.New("slot lost") })
+	if err := heartbeat.Beat(); err == nil || slots != 2 || redisMock.extensions() != 1 {
+		t.Errorf("Expected lease and slot extended with the error of the extensions but got %v %v %v", err, slots, redisMock.extensions())
+	}
+}
+
+func TestHeartbeatStartExtendUntilStop(t *testing.T) {
+	redisMock := newRedisMock()
+	heartbeat := &Heartbeat{Redis: redisMock, QueueName: "queues:test", Token: "job-token", Timeout: time.Minute}
+
+	stop := heartbeat.Start(time.Millisecond, func(err error) {
+		t.Errorf("Expected no error but got %v", err)
+	})
+	time.Sleep(20 * time.Millisecond)
+	stop()
+	stop()
+
+	extensions := redisMock.extensions()
+	if extensions == 0 {
+		t.Fatalf("Expected lease extended while running")
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if redisMock.extensions() != extensions {
+		t.Errorf("Expected lease not extended after stop")
+	}
+}
+
+type failingRedisMock struct{}
+
+func (r *failingRedisMock) Eval(script string, keys []string, args ...interface{}) *redis.Cmd {
+	return redis.NewCmdResult(nil, errors.New("Eval"))
+}
+
+func TestHeartbeatStartReportErrors(t *testing.T) {
+	heartbeat := &Heartbeat{Redis: &failingRedisMock{}, QueueName: "queues:test", Token: "job-token", Timeout: time.Minute}
+
+	failures := make(chan error, 10)
+	stop := heartbeat.Start(time.Millisecond, func(err error) {
+		select {
+		case failures <- err:
+		default:
+		}
+	})
+	defer stop()
+
+	select {
+	case <-failures:
+	case <-time.After(time.Second):
+		t.Errorf("Expected extension errors reported")
+	}
+}
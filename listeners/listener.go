@@ -1,43 +1,252 @@
 package listener
 
 import (
+	"go-queue/breaker"
+	"go-queue/delayed"
 	"go-queue/interfaces"
-	"log"
+	"go-queue/lease"
+	"go-queue/logger"
+	"go-queue/providers"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/go-redis/redis"
 )
 
-//Listener is the listeners struct
-type Listener struct{}
+//DefaultBlockTimeout is the time BLPop waits for a job when no block timeout is configured
+const DefaultBlockTimeout = 5 * time.Second
+
+//DefaultMigrateInterval is the time between the migrations of the due delayed jobs when no interval is configured
+const DefaultMigrateInterval = time.Second
+
+//DefaultReclaimInterval is the time between the reclaims of the abandoned jobs when no interval is configured
+const DefaultReclaimInterval = 30 * time.Second
 
-//ListenRedis listen queues
+//Listener is the listeners struct. The queues are blocked on by BLPop and the jobs popped
+//from the queues with lease are reserved until they finish
+type Listener struct {
+	Logger          logger.Logger
+	BlockTimeout    time.Duration
+	MigrateInterval time.Duration
+	ReclaimInterval time.Duration
+	Stop            <-chan struct{}
+}
+
+//ListenRedis blocks on the queue until a job arrives, returning without error when the listener is stopped
 func (l Listener) ListenRedis(jobManager interfaces.JobsManagerInterface) error {
+	return l.listenRedis(jobManager, []providers.JobsConfigs{jobManager.GetJob()}, nil)
+}
+
+//ListenRedisQueues blocks on the queues of the jobs, ordered from the highest priority, and calls
+//the job of the queue the data was popped from. With weights the lower priority queues are not starved
+func (l Listener) ListenRedisQueues(jobManager interfaces.JobsManagerInterface, jobs []providers.JobsConfigs, weights []int) error {
+	return l.listenRedis(jobManager, jobs, weights)
+}
+
+func (l Listener) listenRedis(jobManager interfaces.JobsManagerInterface, jobs []providers.JobsConfigs, weights []int) error {
+	var queues, leasedQueues []string
+	jobsByQueue := make(map[string]providers.JobsConfigs)
+	for _, job := range jobs {
+		queues = append(queues, job.QueueName)
+		jobsByQueue[job.QueueName] = job
+		if job.Lease.Enabled {
+			leasedQueues = append(leasedQueues, job.QueueName)
+		}
+	}
+
+	picker := newQueuePicker(queues, weights)
+
+	var tasks sync.WaitGroup
+	done := make(chan struct{})
+	defer tasks.Wait()
+	defer close(done)
+
+	tasksClient := jobManager.GetClient().(interfaces.RedisInterface)
+	tasks.Add(1)
+	go func() {
+		defer tasks.Done()
+		l.every(l.migrateInterval(), done, func() { l.migrateDelayed(tasksClient, queues) })
+	}()
+
+	if len(leasedQueues) > 0 {
+		tasks.Add(1)
+		go func() {
+			defer tasks.Done()
+			l.every(l.reclaimInterval(), done, func() { l.reclaimAbandoned(tasksClient, leasedQueues) })
+		}()
+	}
+
 	for {
+		if l.stopped() {
+			return nil
+		}
+
 		redisClient := jobManager.GetClient().(interfaces.RedisInterface)
-		job := jobManager.GetJob()
 
-		items, err := redisClient.LLen(job.QueueName).Result()
+		active, resume := l.activeQueues(jobManager.GetBreakers(), picker.order(), jobsByQueue)
+		if len(active) == 0 {
+			l.queueLogger(strings.Join(queues, ",")).Warnf("Queues paused by open circuit breakers for %v", resume)
+			if !l.pause(resume) {
+				return nil
+			}
+
+			continue
+		}
+
+		timeout := l.blockTimeout()
+		if resume > 0 && resume < timeout {
+			timeout = resume
+			if timeout < time.Second {
+				timeout = time.Second
+			}
+		}
+
+		queueData, err := redisClient.BLPop(timeout, active...).Result()
+		if err == redis.Nil {
+			continue
+		}
+
 		if err != nil {
-			log.Printf("Error to check length of the queue: %v in redis: %v", job.QueueName, err)
+			l.queueLogger(strings.Join(queues, ",")).Errorf("Error to pop queue in redis: %v", err)
 			return err
 		}
 
-		if items < 1 {
-			time.Sleep(3 * time.Second)
-			continue
+		job, ok := jobsByQueue[queueData[0]]
+		if ok {
+			jobManager.SetJob(job)
 		}
 
-		queueData, err := redisClient.BLPop(0, job.QueueName).Result()
-		if err == nil {
-			jobManager.SetQueueData(queueData)
-			err = jobManager.CallDynamically()
+		token := ""
+		if ok && job.Lease.Enabled {
+			token, err = lease.Reserve(redisClient, queueData[0], queueData[1], job.Lease.TimeoutDuration())
 			if err != nil {
-				return err
+				l.queueLogger(queueData[0]).Errorf("Error to reserve job in redis: %v", err)
 			}
+		}
 
+		jobManager.SetQueueData(queueData)
+		jobManager.SetReservation(token)
+		err = jobManager.CallDynamically()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+//migrateDelayed moves the due delayed jobs to the queues, BLPop pops them as soon as they are pushed
+func (l Listener) migrateDelayed(redisClient interfaces.RedisInterface, queues []string) {
+	now := time.Now()
+
+	for _, queueName := range queues {
+		if _, err := delayed.Migrate(redisClient, queueName, now); err != nil {
+			l.queueLogger(queueName).Errorf("Error to migrate delayed jobs in redis: %v", err)
+		}
+	}
+}
+
+//reclaimAbandoned pushes the jobs whose lease expired back to the queues, their workers stopped without releasing them
+func (l Listener) reclaimAbandoned(redisClient interfaces.RedisInterface, queues []string) {
+	now := time.Now()
+
+	for _, queueName := range queues {
+		reclaimed, err := lease.Reclaim(redisClient, queueName, now)
+		if err != nil {
+			l.queueLogger(queueName).Errorf("Error to reclaim abandoned jobs in redis: %v", err)
 			continue
 		}
 
-		log.Printf("Error to pop queue with key: %v, error: %v", job.QueueName, err)
-		return err
+		if reclaimed > 0 {
+			l.queueLogger(queueName).Warnf("Reclaimed %v jobs with the lease expired", reclaimed)
+		}
 	}
 }
+
+//activeQueues return the queues not paused by open circuit breakers and the time until the first paused queue resumes,
+//the jobs of routed queues are paused by job type when they are popped
+func (l Listener) activeQueues(breakers *breaker.Breakers, queues []string, jobs map[string]providers.JobsConfigs) ([]string, time.Duration) {
+	var active []string
+	var resume time.Duration
+
+	for _, queueName := range queues {
+		wait := breakers.Wait(jobs[queueName], "")
+		if wait <= 0 {
+			active = append(active, queueName)
+			continue
+		}
+
+		if resume == 0 || wait < resume {
+			resume = wait
+		}
+	}
+
+	return active, resume
+}
+
+//pause waits the duration, return false when the listener is stopped meanwhile
+func (l Listener) pause(duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-l.Stop:
+		return false
+	}
+}
+
+//every runs the task at once and on each tick of the interval, until done is closed or the listener is stopped
+func (l Listener) every(interval time.Duration, done <-chan struct{}, task func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		task()
+
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		case <-l.Stop:
+			return
+		}
+	}
+}
+
+func (l Listener) migrateInterval() time.Duration {
+	if l.MigrateInterval <= 0 {
+		return DefaultMigrateInterval
+	}
+
+	return l.MigrateInterval
+}
+
+func (l Listener) reclaimInterval() time.Duration {
+	if l.ReclaimInterval <= 0 {
+		return DefaultReclaimInterval
+	}
+
+	return l.ReclaimInterval
+}
+
+func (l Listener) blockTimeout() time.Duration {
+	if l.BlockTimeout <= 0 {
+		return DefaultBlockTimeout
+	}
+
+	return l.BlockTimeout
+}
+
+func (l Listener) stopped() bool {
+	select {
+	case <-l.Stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l Listener) queueLogger(queueName string) logger.Logger {
+	return logger.OrDefault(l.Logger).WithFields(logger.Fields{"queue": queueName, "driver": "redis"})
+}
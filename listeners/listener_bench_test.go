@@ -0,0 +1,156 @@
+package listener
+
+import (
+	"go-queue/interfaces"
+	"go-queue/providers"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+//------------------------- BENCH REDIS ------------------------
+//benchRedis is an in memory queue that counts the commands sent to redis
+type benchRedis struct {
+	jobs chan []string
+	ops  int64
+}
+
+func newBenchRedis() *benchRedis {
+	return &benchRedis{jobs: make(chan []string, 1)}
+}
+
+func (r *benchRedis) LLen(queueName string) *redis.IntCmd {
+	atomic.AddInt64(&r.ops, 1)
+	return redis.NewIntResult(int64(len(r.jobs)), nil)
+}
+
+func (r *benchRedis) BLPop(timeout time.Duration, keys ...string) *redis.StringSliceCmd {
+	atomic.AddInt64(&r.ops, 1)
+
+	var expire <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expire = timer.C
+	}
+
+	select {
+	case job := <-r.jobs:
+		return redis.NewStringSliceResult(job, nil)
+	case <-expire:
+		return redis.NewStringSliceResult(nil, redis.Nil)
+	}
+}
+
+func (r *benchRedis) LPush(key string, values ...interface{}) *redis.IntCmd {
+	r.jobs <- []string{key, values[0].(string)}
+	return redis.NewIntResult(1, nil)
+}
+
+func (r *benchRedis) RPush(key string, values ...interface{}) *redis.IntCmd {
+	return r.LPush(key, values...)
+}
+
+func (r *benchRedis) ZAdd(key string, members ...redis.Z) *redis.IntCmd {
+	return redis.NewIntResult(1, nil)
+}
+
+func (r *benchRedis) Eval(script string, keys []string, args ...interface{}) *redis.Cmd {
+	atomic.AddInt64(&r.ops, 1)
+	return redis.NewCmdResult(int64(-1), nil)
+}
+
+func (r *benchRedis) Exists(keys ...string) *redis.IntCmd {
+	return redis.NewIntResult(0, nil)
+}
+
+//------------------------ BENCH JOBS MANAGER -----------------------
+type benchJobsManager struct {
+	JobsManagerMock
+	client    *benchRedis
+	processed chan struct{}
+}
+
+func (j *benchJobsManager) GetClient() interface{} {
+	return j.client
+}
+
+func (j *benchJobsManager) GetJob() providers.JobsConfigs {
+	return providers.JobsConfigs{QueueName: "queues:bench", Driver: "redis"}
+}
+
+func (j *benchJobsManager) CallDynamically() error {
+	j.processed <- struct{}{}
+	return nil
+}
+
+//pollingListenRedis is the consumer loop replaced by the blocking one, kept to compare them
+func pollingListenRedis(jobManager interfaces.JobsManagerInterface, stop <-chan struct{}) error {
+	for {
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+
+		redisClient := jobManager.GetClient().(interfaces.RedisInterface)
+		job := jobManager.GetJob()
+
+		items, err := redisClient.LLen(job.QueueName).Result()
+		if err != nil {
+			return err
+		}
+
+		if items < 1 {
+			time.Sleep(3 * time.Second)
+			continue
+		}
+
+		queueData, err := redisClient.BLPop(0, job.QueueName).Result()
+		if err != nil {
+			return err
+		}
+
+		jobManager.SetQueueData(queueData)
+		if err := jobManager.CallDynamically(); err != nil {
+			return err
+		}
+	}
+}
+
+//benchmarkListener pushes one job at a time to an idle queue and waits for it,
+//so ns/op is the latency between dispatch and processing
+func benchmarkListener(b *testing.B, listen func(interfaces.JobsManagerInterface, <-chan struct{}) error) {
+	client := newBenchRedis()
+	jobManager := &benchJobsManager{client: client, processed: make(chan struct{})}
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go listen(jobManager, stop)
+	time.Sleep(10 * time.Millisecond)
+
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		client.LPush("queues:bench", `{"id":"bench"}`)
+		<-jobManager.processed
+	}
+	elapsed := time.Since(start)
+	b.StopTimer()
+
+	ops := float64(atomic.LoadInt64(&client.ops))
+	b.ReportMetric(ops/float64(b.N), "redis-ops/job")
+	b.ReportMetric(ops/elapsed.Seconds(), "redis-ops/s")
+}
+
+func BenchmarkListenRedisBlocking(b *testing.B) {
+	benchmarkListener(b, func(jobManager interfaces.JobsManagerInterface, stop <-chan struct{}) error {
+		return Listener{BlockTimeout: DefaultBlockTimeout, Stop: stop}.ListenRedis(jobManager)
+	})
+}
+
+func BenchmarkListenRedisPolling(b *testing.B) {
+	benchmarkListener(b, pollingListenRedis)
+}
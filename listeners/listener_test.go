@@ -2,12 +2,23 @@ package listener
 
 import (
 	"errors"
+	"go-queue/breaker"
+	"go-queue/claimcheck"
+	"go-queue/events"
+	"go-queue/lease"
+	"go-queue/logger"
 	connectionsmanager "go-queue/managers/connectionsManager"
 	"go-queue/managers/jobsManager"
 	"go-queue/providers"
+	"go-queue/results"
+	"go-queue/security"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis"
 	"github.com/go-redis/redis"
 )
 
@@ -22,26 +33,26 @@ func HandlerTest2(paramTest interface{}, paramTestConn map[string]interface{}) e
 
 //------------------------- REDIS MOCK ------------------------
 type redisClientMock struct {
-	rounds int
+	rounds   int
+	timeouts []time.Duration
 }
 
 func (r *redisClientMock) LLen(queueName string) *redis.IntCmd {
-	err := errors.New("LLen")
-	if queueName == "1" {
-		return redis.NewIntResult(0, err)
-	}
-
-	if queueName == "2" {
-		if r.rounds < 1 {
-			r.rounds = 2
-			return redis.NewIntResult(0, nil)
-		}
-	}
-
 	return redis.NewIntResult(1, nil)
 }
 
 func (r *redisClientMock) BLPop(timeVar time.Duration, args ...string) *redis.StringSliceCmd {
+	r.timeouts = append(r.timeouts, timeVar)
+
+	if args[0] == "1" {
+		return redis.NewStringSliceResult(nil, redis.Nil)
+	}
+
+	if args[0] == "2" && r.rounds < 2 {
+		r.rounds++
+		return redis.NewStringSliceResult(nil, redis.Nil)
+	}
+
 	if args[0] == "3" && r.rounds == 0 {
 		r.rounds++
 		return redis.NewStringSliceResult([]string{"teste"}, nil)
@@ -55,6 +66,36 @@ func (r *redisClientMock) LPush(key string, values ...interface{}) *redis.IntCmd
 	return nil
 }
 
+func (r *redisClientMock) RPush(key string, values ...interface{}) *redis.IntCmd {
+	return nil
+}
+
+func (r *redisClientMock) ZAdd(key string, members ...redis.Z) *redis.IntCmd {
+	return redis.NewIntResult(1, nil)
+}
+
+func (r *redisClientMock) Eval(script string, keys []string, args ...interface{}) *redis.Cmd {
+	return redis.NewCmdResult(int64(-1), nil)
+}
+
+func (r *redisClientMock) Exists(keys ...string) *redis.IntCmd {
+	return redis.NewIntResult(0, nil)
+}
+
+type queuesRedisMock struct {
+	redisClientMock
+	pops [][]string
+}
+
+func (r *queuesRedisMock) BLPop(timeVar time.Duration, args ...string) *redis.StringSliceCmd {
+	r.pops = append(r.pops, args)
+	if len(r.pops) == 1 {
+		return redis.NewStringSliceResult([]string{"queues:low", `{"id":"1"}`}, nil)
+	}
+
+	return redis.NewStringSliceResult(nil, errors.New("BLPop"))
+}
+
 //------------------------ JOBS MANAGER MOCK -----------------------
 type JobsManagerMock struct{}
 
@@ -62,6 +103,10 @@ func (j *JobsManagerMock) SetConnManager(connManager *connectionsmanager.Manager
 func (j *JobsManagerMock) SetJob(job providers.JobsConfigs)                       {}
 func (j *JobsManagerMock) SetClient(client interface{})                           {}
 func (j *JobsManagerMock) SetQueueData(queueData interface{})                     {}
+func (j *JobsManagerMock) SetReservation(token string)                            {}
+func (j *JobsManagerMock) GetReservation() string {
+	return ""
+}
 func (j *JobsManagerMock) GetJobConnections() map[string]interface{} {
 	connections := make(map[string]interface{})
 	connections["teste"] = "teste"
@@ -84,47 +129,129 @@ func (j *JobsManagerMock) GetClient() interface{} {
 func (j *JobsManagerMock) GetQueueData() interface{} {
 	return nil
 }
+func (j *JobsManagerMock) SetMiddlewares(middlewares []providers.Middleware) {}
+func (j *JobsManagerMock) GetMiddlewares() []providers.Middleware {
+	return nil
+}
+func (j *JobsManagerMock) SetEvents(bus *events.Bus) {}
+func (j *JobsManagerMock) GetEvents() *events.Bus {
+	return nil
+}
+func (j *JobsManagerMock) SetLogger(log logger.Logger) {}
+func (j *JobsManagerMock) GetLogger() logger.Logger {
+	return nil
+}
+func (j *JobsManagerMock) SetBreakers(breakers *breaker.Breakers) {}
+func (j *JobsManagerMock) GetBreakers() *breaker.Breakers {
+	return nil
+}
+func (j *JobsManagerMock) SetResults(store results.Store) {}
+func (j *JobsManagerMock) GetResults() results.Store {
+	return nil
+}
+func (j *JobsManagerMock) SetSealer(sealer *security.Sealer) {}
+func (j *JobsManagerMock) GetSealer() *security.Sealer {
+	return nil
+}
+func (j *JobsManagerMock) SetClaimCheck(offloader *claimcheck.Offloader) {}
+func (j *JobsManagerMock) GetClaimCheck() *claimcheck.Offloader {
+	return nil
+}
+func (j *JobsManagerMock) WorkerStopping(err error) {}
 func (j *JobsManagerMock) CallDynamically() error {
 	return errors.New("Test")
 }
 
+type recordJobsManagerMock struct {
+	JobsManagerMock
+	client   interface{}
+	breakers *breaker.Breakers
+	jobs     []string
+}
+
+func (j *recordJobsManagerMock) GetClient() interface{} {
+	return j.client
+}
+
+func (j *recordJobsManagerMock) GetBreakers() *breaker.Breakers {
+	return j.breakers
+}
+
+func (j *recordJobsManagerMock) SetJob(job providers.JobsConfigs) {
+	j.jobs = append(j.jobs, job.QueueName)
+}
+
+func (j *recordJobsManagerMock) CallDynamically() error {
+	return nil
+}
+
 //------------------------------ TESTS ---------------------------------
-func TestListenRedisReturnLLenError(t *testing.T) {
-	listeners := Listener{}
+func TestListenRedisReturnOnStop(t *testing.T) {
+	stop := make(chan struct{})
+	close(stop)
+	listeners := Listener{Stop: stop}
 
-	var teste = []providers.JobsConfigs{
-		providers.JobsConfigs{"1", "test", "", float64(1), []string{"test"}},
+	redisClient := redisClientMock{}
+	jobManager := jobsManager.Manager{
+		Job:    providers.JobsConfigs{QueueName: "1", Driver: "test", Attempts: float64(1)},
+		Client: &redisClient,
 	}
 
-	redisClient := redisClientMock{}
+	err := listeners.ListenRedis(&jobManager)
+	if err != nil {
+		t.Errorf("Expected no error but got %v", err)
+	}
 
-	connections := make(map[string]interface{})
-	connections["teste"] = "teste"
+	if len(redisClient.timeouts) != 0 {
+		t.Errorf("Expected no BLPop after stop but got %v", len(redisClient.timeouts))
+	}
+}
 
-	connManager := connectionsmanager.Manager{DBClients: connections}
+func TestListenRedisKeepBlockingOnTimeout(t *testing.T) {
+	listeners := Listener{BlockTimeout: 2 * time.Second}
 
+	redisClient := redisClientMock{}
 	jobManager := jobsManager.Manager{
-		Job:         teste[0],
-		Client:      &redisClient,
-		ConnManager: &connManager,
+		Job:    providers.JobsConfigs{QueueName: "2", Driver: "test", Attempts: float64(1)},
+		Client: &redisClient,
 	}
 
-	var err error
-	err = listeners.ListenRedis(&jobManager)
+	err := listeners.ListenRedis(&jobManager)
+	if err == nil || err.Error() != "BLPop" {
+		t.Errorf("Expected an error equal 'BLPop' but got %v", err)
+	}
 
-	if err == nil {
-		t.Errorf("Expected an error but got %v", err)
+	if len(redisClient.timeouts) != 3 {
+		t.Fatalf("Expected 3 BLPop but got %v", len(redisClient.timeouts))
+	}
+
+	for _, timeout := range redisClient.timeouts {
+		if timeout != 2*time.Second {
+			t.Errorf("Expected BLPop timeout equal 2s but got %v", timeout)
+		}
+	}
+}
+
+func TestListenRedisUseDefaultBlockTimeout(t *testing.T) {
+	listeners := Listener{}
+
+	redisClient := redisClientMock{}
+	jobManager := jobsManager.Manager{
+		Job:    providers.JobsConfigs{QueueName: "4", Driver: "test", Attempts: float64(1)},
+		Client: &redisClient,
 	}
 
-	if err.Error() != "LLen" {
-		t.Errorf("Expected an error equal 'LLEN' but got %v", err)
+	listeners.ListenRedis(&jobManager)
+
+	if len(redisClient.timeouts) != 1 || redisClient.timeouts[0] != DefaultBlockTimeout {
+		t.Errorf("Expected BLPop with default timeout but got %v", redisClient.timeouts)
 	}
 }
 
 func TestListenRedisReturnBLPopError(t *testing.T) {
 	listeners := Listener{}
 	var teste = []providers.JobsConfigs{
-		providers.JobsConfigs{"2", "test", "", float64(1), []string{"test"}},
+		providers.JobsConfigs{QueueName: "2", Driver: "test", Handle: "", Attempts: float64(1), Connections: []string{"test"}},
 	}
 
 	redisClient := redisClientMock{}
@@ -154,7 +281,7 @@ func TestListenRedisReturnBLPopError(t *testing.T) {
 func TestListenRedisReturnBLPopCallFunction(t *testing.T) {
 	listeners := Listener{}
 	var teste = []providers.JobsConfigs{
-		providers.JobsConfigs{"3", "test", HandlerTest, float64(1), []string{"test"}},
+		providers.JobsConfigs{QueueName: "3", Driver: "test", Handle: HandlerTest, Attempts: float64(1), Connections: []string{"test"}},
 	}
 
 	redisClient := redisClientMock{}
@@ -184,7 +311,7 @@ func TestListenRedisReturnBLPopCallFunction(t *testing.T) {
 func TestListenRedisCallJobAndReturnBlpopError(t *testing.T) {
 	listeners := Listener{}
 	var teste = []providers.JobsConfigs{
-		providers.JobsConfigs{"3", "test", HandlerTest, float64(1), []string{"test"}},
+		providers.JobsConfigs{QueueName: "3", Driver: "test", Handle: HandlerTest, Attempts: float64(1), Connections: []string{"test"}},
 	}
 
 	redisClient := redisClientMock{}
@@ -224,3 +351,221 @@ func TestListenRedisCallJobAndReturnError(t *testing.T) {
 		t.Errorf("Expected an error equal 'BLPop' but got %v", err)
 	}
 }
+
+func TestListenRedisQueuesCallJobOfPoppedQueue(t *testing.T) {
+	listeners := Listener{}
+	redisClient := &queuesRedisMock{}
+	jobManager := &recordJobsManagerMock{client: redisClient}
+
+	jobs := []providers.JobsConfigs{
+		providers.JobsConfigs{QueueName: "queues:high", Driver: "redis"},
+		providers.JobsConfigs{QueueName: "queues:low", Driver: "redis"},
+	}
+
+	err := listeners.ListenRedisQueues(jobManager, jobs, nil)
+	if err == nil || err.Error() != "BLPop" {
+		t.Errorf("Expected an error equal 'BLPop' but got %v", err)
+	}
+
+	if len(redisClient.pops) != 2 || redisClient.pops[0][0] != "queues:high" || redisClient.pops[0][1] != "queues:low" {
+		t.Errorf("Expected BLPop on the queues in priority order but got %v", redisClient.pops)
+	}
+
+	if len(jobManager.jobs) != 1 || jobManager.jobs[0] != "queues:low" {
+		t.Errorf("Expected job of queues:low called but got %v", jobManager.jobs)
+	}
+}
+
+type delayedRedisMock struct {
+	redisClientMock
+	migrated  []string
+	reclaimed []string
+}
+
+func (r *delayedRedisMock) Eval(script string, keys []string, args ...interface{}) *redis.Cmd {
+	if strings.HasSuffix(keys[0], ":reserved") {
+		r.reclaimed = append(r.reclaimed, keys[0])
+		return redis.NewCmdResult(int64(0), nil)
+	}
+
+	r.migrated = append(r.migrated, keys[0])
+	due := time.Now().Add(1500*time.Millisecond).UnixNano() / int64(time.Millisecond)
+	return redis.NewCmdResult(strconv.FormatInt(due, 10), nil)
+}
+
+func TestListenRedisMigrateDelayedJobs(t *testing.T) {
+	listeners := Listener{BlockTimeout: time.Minute}
+
+	redisClient := &delayedRedisMock{}
+	jobManager := jobsManager.Manager{
+		Job:    providers.JobsConfigs{QueueName: "4", Driver: "test", Attempts: float64(1)},
+		Client: redisClient,
+	}
+
+	listeners.ListenRedis(&jobManager)
+
+	if len(redisClient.migrated) != 1 || redisClient.migrated[0] != "4:delayed" {
+		t.Errorf("Expected delayed jobs migrated when the listener starts but got %v", redisClient.migrated)
+	}
+
+	if len(redisClient.reclaimed) != 0 {
+		t.Errorf("Expected no reclaim of the queue without lease but got %v", redisClient.reclaimed)
+	}
+
+	if timeout := redisClient.timeouts[0]; timeout != time.Minute {
+		t.Errorf("Expected block timeout not shortened by the delayed jobs but got %v", timeout)
+	}
+}
+
+type tickingRedisMock struct {
+	delayedRedisMock
+	mutex sync.Mutex
+}
+
+func (r *tickingRedisMock) BLPop(timeVar time.Duration, args ...string) *redis.StringSliceCmd {
+	time.Sleep(5 * time.Millisecond)
+	return redis.NewStringSliceResult(nil, redis.Nil)
+}
+
+func (r *tickingRedisMock) Eval(script string, keys []string, args ...interface{}) *redis.Cmd {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.delayedRedisMock.Eval(script, keys, args...)
+}
+
+func (r *tickingRedisMock) migrations() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return len(r.migrated)
+}
+
+func TestListenRedisMigrateDelayedJobsOnEachTick(t *testing.T) {
+	stop := make(chan struct{})
+	listeners := Listener{MigrateInterval: 10 * time.Millisecond, Stop: stop}
+
+	redisClient := &tickingRedisMock{}
+	jobManager := jobsManager.Manager{
+		Job:    providers.JobsConfigs{QueueName: "5", Driver: "test", Attempts: float64(1)},
+		Client: redisClient,
+	}
+
+	time.AfterFunc(55*time.Millisecond, func() { close(stop) })
+	if err := listeners.ListenRedis(&jobManager); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	migrations := redisClient.migrations()
+	if migrations < 2 {
+		t.Errorf("Expected delayed jobs migrated on each tick but got %v migrations", migrations)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if redisClient.migrations() != migrations {
+		t.Errorf("Expected no migration after the listener stopped")
+	}
+}
+
+//reservedJobsManagerMock records the job popped, its reservation and the reserved jobs when it is called, stopping the listener
+type reservedJobsManagerMock struct {
+	recordJobsManagerMock
+	queueData   []string
+	reservation string
+	reserved    []string
+	stop        chan struct{}
+}
+
+func (j *reservedJobsManagerMock) SetQueueData(queueData interface{}) {
+	j.queueData = queueData.([]string)
+}
+
+func (j *reservedJobsManagerMock) SetReservation(token string) {
+	j.reservation = token
+}
+
+func (j *reservedJobsManagerMock) CallDynamically() error {
+	j.reserved = j.client.(*redis.Client).ZRange(lease.Key("queues:leased"), 0, -1).Val()
+	close(j.stop)
+	return nil
+}
+
+func TestListenRedisQueuesReclaimAndReserveLeasedJobs(t *testing.T) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	abandoned, _ := lease.Reserve(redisClient, "queues:leased", `{"id":"abandoned"}`, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	jobs := []providers.JobsConfigs{
+		providers.JobsConfigs{QueueName: "queues:plain", Driver: "redis"},
+		providers.JobsConfigs{QueueName: "queues:leased", Driver: "redis", Lease: providers.LeaseConfigs{Enabled: true}},
+	}
+
+	stop := make(chan struct{})
+	listeners := Listener{Stop: stop}
+	jobManager := &reservedJobsManagerMock{recordJobsManagerMock: recordJobsManagerMock{client: redisClient}, stop: stop}
+
+	if err := listeners.ListenRedisQueues(jobManager, jobs, nil); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	if len(jobManager.queueData) != 2 || jobManager.queueData[0] != "queues:leased" || jobManager.queueData[1] != `{"id":"abandoned"}` {
+		t.Errorf("Expected abandoned job reclaimed and popped again but got %v", jobManager.queueData)
+	}
+
+	if jobManager.reservation == "" || jobManager.reservation == abandoned {
+		t.Errorf("Expected new reservation of the job popped again but got %v", jobManager.reservation)
+	}
+
+	if len(jobManager.reserved) != 1 || jobManager.reserved[0] != jobManager.reservation {
+		t.Errorf("Expected job reserved when it was popped but got %v", jobManager.reserved)
+	}
+}
+
+func TestListenRedisQueuesSkipQueuesWithOpenBreaker(t *testing.T) {
+	listeners := Listener{}
+	redisClient := &queuesRedisMock{}
+
+	jobs := []providers.JobsConfigs{
+		providers.JobsConfigs{QueueName: "queues:high", Driver: "redis", Connections: []string{"mysql"},
+			Breaker: providers.BreakerConfigs{Threshold: 1, Cooldown: time.Hour}},
+		providers.JobsConfigs{QueueName: "queues:low", Driver: "redis"},
+	}
+
+	breakers := breaker.NewBreakers()
+	breakers.Done(jobs[0], "", errors.New("mysql is down"))
+	jobManager := &recordJobsManagerMock{client: redisClient, breakers: breakers}
+
+	listeners.ListenRedisQueues(jobManager, jobs, nil)
+
+	if len(redisClient.pops) == 0 || len(redisClient.pops[0]) != 1 || redisClient.pops[0][0] != "queues:low" {
+		t.Errorf("Expected BLPop only on the queue with closed breakers but got %v", redisClient.pops)
+	}
+}
+
+func TestListenRedisPauseUntilStopWhenAllBreakersOpen(t *testing.T) {
+	stop := make(chan struct{})
+	listeners := Listener{Stop: stop}
+	redisClient := &queuesRedisMock{}
+
+	job := providers.JobsConfigs{QueueName: "queues:test", Driver: "redis", Breaker: providers.BreakerConfigs{Threshold: 1, Cooldown: time.Hour}}
+	breakers := breaker.NewBreakers()
+	breakers.Done(job, "", errors.New("api is down"))
+	jobManager := &recordJobsManagerMock{client: redisClient, breakers: breakers}
+
+	time.AfterFunc(20*time.Millisecond, func() { close(stop) })
+
+	err := listeners.ListenRedisQueues(jobManager, []providers.JobsConfigs{job}, nil)
+	if err != nil {
+		t.Errorf("Expected no error but got %v", err)
+	}
+
+	if len(redisClient.pops) != 0 {
+		t.Errorf("Expected paused queue not consumed but got %v", redisClient.pops)
+	}
+}
@@ -0,0 +1,40 @@
+package listener
+
+//queuePicker orders the queues popped by a worker. Without weights the order is always the
+//priority order, with weights the first queue is chosen by smooth weighted round robin and
+//the others follow in priority order
+type queuePicker struct {
+	queues  []string
+	weights []int
+	current []int
+}
+
+func newQueuePicker(queues []string, weights []int) *queuePicker {
+	return &queuePicker{queues: queues, weights: weights, current: make([]int, len(weights))}
+}
+
+func (p *queuePicker) order() []string {
+	if len(p.weights) == 0 {
+		return p.queues
+	}
+
+	total := 0
+	first := 0
+	for i, weight := range p.weights {
+		p.current[i] += weight
+		total += weight
+		if p.current[i] > p.current[first] {
+			first = i
+		}
+	}
+	p.current[first] -= total
+
+	ordered := []string{p.queues[first]}
+	for i, queueName := range p.queues {
+		if i != first {
+			ordered = append(ordered, queueName)
+		}
+	}
+
+	return ordered
+}
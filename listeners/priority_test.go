@@ -0,0 +1,38 @@
+package listener
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQueuePickerStrictPriority(t *testing.T) {
+	picker := newQueuePicker([]string{"high", "default", "low"}, nil)
+
+	for i := 0; i < 3; i++ {
+		if order := picker.order(); !reflect.DeepEqual(order, []string{"high", "default", "low"}) {
+			t.Errorf("Expected queues in priority order but got %v", order)
+		}
+	}
+}
+
+func TestQueuePickerWeighted(t *testing.T) {
+	picker := newQueuePicker([]string{"high", "default", "low"}, []int{5, 3, 2})
+
+	firsts := make(map[string]int)
+	for i := 0; i < 10; i++ {
+		order := picker.order()
+		firsts[order[0]]++
+
+		if len(order) != 3 {
+			t.Fatalf("Expected all queues in order but got %v", order)
+		}
+	}
+
+	if firsts["high"] != 5 || firsts["default"] != 3 || firsts["low"] != 2 {
+		t.Errorf("Expected queues first in proportion to the weights but got %v", firsts)
+	}
+
+	if order := picker.order(); !reflect.DeepEqual(order, []string{"high", "default", "low"}) {
+		t.Errorf("Expected the other queues in priority order but got %v", order)
+	}
+}
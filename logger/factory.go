@@ -0,0 +1,100 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Graylog2/go-gelf/gelf"
+	"github.com/sirupsen/logrus"
+)
+
+//Config of the loggers
+type Config struct {
+	Format          string
+	Level           string
+	ComponentLevels map[string]string
+	GraylogHost     string
+	Worker          string
+}
+
+//ConfigFromEnv read the loggers config from LOG_FORMAT, LOG_LEVEL, LOG_LEVEL_<COMPONENT> and GRAYLOG_HOST
+func ConfigFromEnv(env map[string]string) Config {
+	config := Config{
+		Format:          env["LOG_FORMAT"],
+		Level:           env["LOG_LEVEL"],
+		ComponentLevels: make(map[string]string),
+		GraylogHost:     env["GRAYLOG_HOST"],
+	}
+
+	for key, value := range env {
+		if strings.HasPrefix(key, "LOG_LEVEL_") && value != "" {
+			component := strings.ToLower(strings.TrimPrefix(key, "LOG_LEVEL_"))
+			config.ComponentLevels[component] = value
+		}
+	}
+
+	return config
+}
+
+//Factory creates the loggers of the components sharing the same outputs
+type Factory struct {
+	Config Config
+	Out    io.Writer
+	Hooks  []logrus.Hook
+}
+
+//NewFactory return a factory writing in stdout and, when GraylogHost is set, in graylog
+func NewFactory(config Config) (*Factory, error) {
+	if config.Worker == "" {
+		hostname, _ := os.Hostname()
+		config.Worker = fmt.Sprintf("%v:%v", hostname, os.Getpid())
+	}
+
+	factory := &Factory{Config: config, Out: os.Stdout}
+
+	if config.GraylogHost != "" {
+		gelfWriter, err := gelf.NewWriter(config.GraylogHost)
+		if err != nil {
+			return nil, err
+		}
+
+		factory.Hooks = append(factory.Hooks, NewGelfHook(gelfWriter))
+	}
+
+	return factory, nil
+}
+
+//Component return the logger of the component with its configured level
+func (factory *Factory) Component(name string) Logger {
+	log := logrus.New()
+	log.Out = factory.Out
+	log.Level = factory.level(name)
+
+	if factory.Config.Format == "text" {
+		log.Formatter = &logrus.TextFormatter{}
+	} else {
+		log.Formatter = &logrus.JSONFormatter{}
+	}
+
+	for _, hook := range factory.Hooks {
+		log.AddHook(hook)
+	}
+
+	return New(log).WithFields(Fields{"component": name, "worker": factory.Config.Worker})
+}
+
+func (factory *Factory) level(component string) logrus.Level {
+	levelName := factory.Config.Level
+	if componentLevel, ok := factory.Config.ComponentLevels[component]; ok {
+		levelName = componentLevel
+	}
+
+	level, err := logrus.ParseLevel(levelName)
+	if err != nil {
+		return logrus.InfoLevel
+	}
+
+	return level
+}
@@ -0,0 +1,68 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	env := map[string]string{
+		"LOG_FORMAT":         "text",
+		"LOG_LEVEL":          "warn",
+		"LOG_LEVEL_LISTENER": "debug",
+		"LOG_LEVEL_JOBS":     "",
+	}
+
+	config := ConfigFromEnv(env)
+
+	if config.Format != "text" || config.Level != "warn" {
+		t.Errorf("Config is different from env %v", config)
+	}
+
+	if len(config.ComponentLevels) != 1 || config.ComponentLevels["listener"] != "debug" {
+		t.Errorf("Expected only listener level configured but got %v", config.ComponentLevels)
+	}
+}
+
+func TestComponentUseItsLevel(t *testing.T) {
+	out := &bytes.Buffer{}
+	factory, _ := NewFactory(Config{Level: "error", ComponentLevels: map[string]string{"listener": "debug"}})
+	factory.Out = out
+
+	factory.Component("jobs").Infof("hidden")
+	factory.Component("listener").Debugf("shown")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected 1 line logged but got %v", lines)
+	}
+
+	line := make(map[string]interface{})
+	json.Unmarshal([]byte(lines[0]), &line)
+
+	if line["msg"] != "shown" || line["component"] != "listener" || line["worker"] == "" {
+		t.Errorf("Log line is different from expected %v", line)
+	}
+}
+
+func TestComponentWithTextFormat(t *testing.T) {
+	out := &bytes.Buffer{}
+	factory, _ := NewFactory(Config{Format: "text", Worker: "test"})
+	factory.Out = out
+
+	factory.Component("jobs").Infof("text line")
+
+	if !strings.Contains(out.String(), `msg="text line"`) || !strings.Contains(out.String(), "worker=test") {
+		t.Errorf("Expected text formatted line but got %v", out.String())
+	}
+}
+
+func TestComponentWithInvalidLevelUseInfo(t *testing.T) {
+	factory, _ := NewFactory(Config{Level: "invalid"})
+
+	if factory.level("jobs").String() != "info" {
+		t.Errorf("Expected info level but got %v", factory.level("jobs"))
+	}
+}
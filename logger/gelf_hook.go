@@ -0,0 +1,70 @@
+package logger
+
+import (
+	"os"
+
+	"github.com/Graylog2/go-gelf/gelf"
+	"github.com/sirupsen/logrus"
+)
+
+//GelfWriter is the writer of the GELF messages
+type GelfWriter interface {
+	WriteMessage(*gelf.Message) error
+}
+
+//GelfHook is a logrus hook that sends the lines to graylog with the fields as additional fields
+type GelfHook struct {
+	Writer GelfWriter
+	Host   string
+}
+
+//NewGelfHook return a hook writing in the gelf writer
+func NewGelfHook(writer GelfWriter) *GelfHook {
+	hostname, _ := os.Hostname()
+	return &GelfHook{Writer: writer, Host: hostname}
+}
+
+//Levels implements logrus.Hook
+func (hook *GelfHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+//Fire implements logrus.Hook
+func (hook *GelfHook) Fire(entry *logrus.Entry) error {
+	extra := make(map[string]interface{})
+	for key, value := range entry.Data {
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+
+		extra["_"+key] = value
+	}
+
+	message := &gelf.Message{
+		Version:  "1.1",
+		Host:     hook.Host,
+		Short:    entry.Message,
+		TimeUnix: float64(entry.Time.UnixNano()) / 1e9,
+		Level:    syslogLevel(entry.Level),
+		Extra:    extra,
+	}
+
+	return hook.Writer.WriteMessage(message)
+}
+
+func syslogLevel(level logrus.Level) int32 {
+	switch level {
+	case logrus.PanicLevel:
+		return 1
+	case logrus.FatalLevel:
+		return 2
+	case logrus.ErrorLevel:
+		return 3
+	case logrus.WarnLevel:
+		return 4
+	case logrus.InfoLevel:
+		return 6
+	default:
+		return 7
+	}
+}
@@ -0,0 +1,58 @@
+package logger
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/Graylog2/go-gelf/gelf"
+	"github.com/sirupsen/logrus"
+)
+
+type gelfWriterMock struct {
+	messages []*gelf.Message
+}
+
+func (g *gelfWriterMock) WriteMessage(message *gelf.Message) error {
+	g.messages = append(g.messages, message)
+	return nil
+}
+
+func TestGelfHookSendFieldsAsAdditionalFields(t *testing.T) {
+	writer := &gelfWriterMock{}
+	log := logrus.New()
+	log.Out = ioutil.Discard
+	log.AddHook(NewGelfHook(writer))
+
+	New(log).WithFields(Fields{"queue": "test", "error": errors.New("Test")}).Errorf("Job failed")
+
+	if len(writer.messages) != 1 {
+		t.Fatalf("Expected 1 message sent but got %v", len(writer.messages))
+	}
+
+	message := writer.messages[0]
+	if message.Short != "Job failed" || message.Level != 3 {
+		t.Errorf("Message is different from expected %v", message)
+	}
+
+	if message.Extra["_queue"] != "test" || message.Extra["_error"] != "Test" {
+		t.Errorf("Expected fields as additional fields but got %v", message.Extra)
+	}
+}
+
+func TestSyslogLevel(t *testing.T) {
+	levels := map[logrus.Level]int32{
+		logrus.PanicLevel: 1,
+		logrus.FatalLevel: 2,
+		logrus.ErrorLevel: 3,
+		logrus.WarnLevel:  4,
+		logrus.InfoLevel:  6,
+		logrus.DebugLevel: 7,
+	}
+
+	for level, expected := range levels {
+		if syslogLevel(level) != expected {
+			t.Errorf("Expected level %v to be %v but got %v", level, expected, syslogLevel(level))
+		}
+	}
+}
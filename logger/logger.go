@@ -0,0 +1,71 @@
+package logger
+
+import "github.com/sirupsen/logrus"
+
+//Fields are the structured data of a log line
+type Fields map[string]interface{}
+
+//Logger is the structured logger injected in the go-queue components
+type Logger interface {
+	WithFields(fields Fields) Logger
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+}
+
+type logrusLogger struct {
+	entry *logrus.Entry
+}
+
+var defaultLogger = New(logrus.StandardLogger())
+
+//New return a Logger that writes with the logrus logger
+func New(log *logrus.Logger) Logger {
+	return &logrusLogger{entry: logrus.NewEntry(log)}
+}
+
+//Default return the logger of the components without one injected
+func Default() Logger {
+	return defaultLogger
+}
+
+//OrDefault return the logger or the default one when it is nil
+func OrDefault(log Logger) Logger {
+	if log == nil {
+		return defaultLogger
+	}
+
+	return log
+}
+
+//WithFields return a logger that adds the fields to every line
+func (l *logrusLogger) WithFields(fields Fields) Logger {
+	return &logrusLogger{entry: l.entry.WithFields(logrus.Fields(fields))}
+}
+
+//Debugf log in debug level
+func (l *logrusLogger) Debugf(format string, args ...interface{}) {
+	l.entry.Debugf(format, args...)
+}
+
+//Infof log in info level
+func (l *logrusLogger) Infof(format string, args ...interface{}) {
+	l.entry.Infof(format, args...)
+}
+
+//Warnf log in warning level
+func (l *logrusLogger) Warnf(format string, args ...interface{}) {
+	l.entry.Warnf(format, args...)
+}
+
+//Errorf log in error level
+func (l *logrusLogger) Errorf(format string, args ...interface{}) {
+	l.entry.Errorf(format, args...)
+}
+
+//Fatalf log in fatal level and exit
+func (l *logrusLogger) Fatalf(format string, args ...interface{}) {
+	l.entry.Fatalf(format, args...)
+}
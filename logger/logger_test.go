@@ -0,0 +1,36 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestOrDefault(t *testing.T) {
+	if OrDefault(nil) != Default() {
+		t.Errorf("Expected default logger when nil is given")
+	}
+
+	log := New(logrus.New())
+	if OrDefault(log) != log {
+		t.Errorf("Expected the given logger to be returned")
+	}
+}
+
+func TestWithFieldsWriteFields(t *testing.T) {
+	out := &bytes.Buffer{}
+	log := logrus.New()
+	log.Out = out
+	log.Formatter = &logrus.JSONFormatter{}
+
+	New(log).WithFields(Fields{"queue": "test"}).WithFields(Fields{"attempt": 2}).Warnf("Job %v", "failed")
+
+	line := make(map[string]interface{})
+	json.Unmarshal(out.Bytes(), &line)
+
+	if line["queue"] != "test" || line["attempt"] != float64(2) || line["msg"] != "Job failed" || line["level"] != "warning" {
+		t.Errorf("Log line is different from expected %v", line)
+	}
+}
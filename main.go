@@ -1,38 +1,52 @@
 package main
 
 import (
-	listener "go-queue/listeners"
+	"go-queue/claimcheck"
+	"go-queue/cli"
+	"go-queue/logger"
 	connectionsmanager "go-queue/managers/connectionsManager"
-	"go-queue/managers/jobsManager"
-	"go-queue/managers/listenersManager"
 	"go-queue/providers"
-	"log"
+	"go-queue/worker"
+	"os"
 
 	"github.com/joho/godotenv"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 func main() {
 	envVariables, err := godotenv.Read()
 	failOnError(err, "Error to get params in env file: ")
 
-	connManager := connectionsmanager.Manager{Env: envVariables}
+	if len(os.Args) > 1 {
+		connManager := connectionsmanager.Manager{Env: envVariables}
 
-	err = connManager.GraylogHook()
-	failOnError(err, "Error to create GraylogHook")
+		var mongoClient *mongo.Client
+		if envVariables["CLAIM_CHECK_STORE"] == "gridfs" {
+			mongoClient = connManager.GetMongoClient()
+		}
 
-	connManager.SetDatabaseClients()
+		offloader, err := claimcheck.OffloaderFromEnv(envVariables, mongoClient)
+		failOnError(err, "Error to read the claim check store")
 
-	lstnManager := listenersManager.ListenerManager{
-		Providers:   providers.GetAllJobs(),
-		Listeners:   listener.Listener{},
-		ConnManager: &connManager,
-		JobsManager: &jobsManager.Manager{},
+		commands := cli.CLI{Redis: connManager.GetRedisClient(), ClaimCheck: offloader, Out: os.Stdout}
+		failOnError(commands.Run(os.Args[1:]), "Error to run the command")
+		return
 	}
-	lstnManager.RunListeners()
+
+	config, err := worker.ConfigFromEnv(envVariables)
+	failOnError(err, "Error to read the worker config")
+
+	registry, err := providers.DefaultRegistry()
+	failOnError(err, "Error to register the jobs")
+
+	queueWorker, err := worker.New(registry, config)
+	failOnError(err, "Error to create the worker")
+
+	queueWorker.Run()
 }
 
 func failOnError(err error, msg string) {
 	if err != nil {
-		log.Fatalf("%s: %s", msg, err)
+		logger.Default().Fatalf("%s: %s", msg, err)
 	}
 }
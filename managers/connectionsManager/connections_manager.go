@@ -2,24 +2,22 @@ package connectionsmanager
 
 import (
 	"context"
-	"flag"
-	"io"
-	"log"
-	"os"
+	"errors"
+	"go-queue/logger"
 
-	"github.com/Graylog2/go-gelf/gelf"
 	"github.com/go-redis/redis"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 
 	"database/sql"
 	//mysql driver
 	_ "github.com/go-sql-driver/mysql"
 )
 
-func failOnError(err error, msg string) {
+func (connManager *Manager) failOnError(err error, msg string) {
 	if err != nil {
-		log.Fatalf("%s: %s", msg, err)
+		logger.OrDefault(connManager.Logger).Fatalf("%s: %s", msg, err)
 	}
 }
 
@@ -27,6 +25,7 @@ func failOnError(err error, msg string) {
 type Manager struct {
 	Env       map[string]string
 	DBClients map[string]interface{}
+	Logger    logger.Logger
 }
 
 //SetDatabaseClients get all DB clients setted on env file
@@ -72,6 +71,34 @@ func (connManager *Manager) GetJobDatabaseManagers(jobsConnections []string) map
 	return connectionsToReturn
 }
 
+type redisPinger interface {
+	Ping() *redis.StatusCmd
+}
+
+type mongoPinger interface {
+	Ping(context.Context, *readpref.ReadPref) error
+}
+
+type sqlPinger interface {
+	Ping() error
+}
+
+//CheckConnection ping the database client with the given name
+func (connManager *Manager) CheckConnection(name string) error {
+	switch client := connManager.DBClients[name].(type) {
+	case redisPinger:
+		return client.Ping().Err()
+	case mongoPinger:
+		return client.Ping(context.TODO(), readpref.Primary())
+	case sqlPinger:
+		return client.Ping()
+	case nil:
+		return errors.New("Connection " + name + " is not configured")
+	default:
+		return errors.New("Connection " + name + " can not be checked")
+	}
+}
+
 //GetRedisClient return redis connected client
 func (connManager *Manager) GetRedisClient() *redis.Client {
 	redisClient := redis.NewClient(&redis.Options{
@@ -80,7 +107,7 @@ func (connManager *Manager) GetRedisClient() *redis.Client {
 		DB:       0,
 	})
 	_, err := redisClient.Ping().Result()
-	failOnError(err, "Failed to connect to redis")
+	connManager.failOnError(err, "Failed to connect to redis")
 
 	return redisClient
 }
@@ -89,33 +116,13 @@ func (connManager *Manager) GetRedisClient() *redis.Client {
 func (connManager *Manager) GetMongoClient() *mongo.Client {
 	clientOptions := options.Client().ApplyURI(connManager.Env["MONGO_URL"])
 	client, err := mongo.Connect(context.TODO(), clientOptions)
-	failOnError(err, "Error to connect to Mongo")
+	connManager.failOnError(err, "Error to connect to Mongo")
 	return client
 }
 
 //GetMysqlClient return MySQL connection
 func (connManager *Manager) GetMysqlClient() *sql.DB {
 	db, err := sql.Open("mysql", connManager.Env["MYSQL_URL"])
-	failOnError(err, "Error to connect to MySQL")
+	connManager.failOnError(err, "Error to connect to MySQL")
 	return db
 }
-
-//GraylogHook attached log with graylog
-func (connManager *Manager) GraylogHook() error {
-	graylogHost := connManager.Env["GRAYLOG_HOST"]
-	if graylogHost != "" {
-		var graylogAddr string
-
-		flag.StringVar(&graylogAddr, "graylog", graylogHost, "")
-		flag.Parse()
-
-		gelfWriter, err := gelf.NewWriter(graylogAddr)
-		if err != nil {
-			return err
-		}
-		// log to both stderr and graylog2
-		log.SetOutput(io.MultiWriter(os.Stderr, gelfWriter))
-	}
-
-	return nil
-}
@@ -1,6 +1,11 @@
 package connectionsmanager
 
-import "testing"
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-redis/redis"
+)
 
 func TestGetJobDatabaseManagers(t *testing.T) {
 	jobsConnections := []string{"mysql", "mongo", "redis"}
@@ -23,3 +28,44 @@ func TestGetJobDatabaseManagers(t *testing.T) {
 		}
 	}
 }
+
+type redisPingMock struct {
+	err error
+}
+
+func (r redisPingMock) Ping() *redis.StatusCmd {
+	return redis.NewStatusResult("PONG", r.err)
+}
+
+type sqlPingMock struct {
+	err error
+}
+
+func (s sqlPingMock) Ping() error {
+	return s.err
+}
+
+func TestCheckConnection(t *testing.T) {
+	dbClients := make(map[string]interface{})
+	dbClients["redis"] = redisPingMock{}
+	dbClients["mysql"] = sqlPingMock{err: errors.New("Test")}
+	dbClients["other"] = "teste"
+
+	connManager := Manager{DBClients: dbClients}
+
+	if err := connManager.CheckConnection("redis"); err != nil {
+		t.Errorf("Expected redis connection to be healthy but got %v", err)
+	}
+
+	if err := connManager.CheckConnection("mysql"); err == nil || err.Error() != "Test" {
+		t.Errorf("Expected mysql ping error but got %v", err)
+	}
+
+	if err := connManager.CheckConnection("mongo"); err == nil {
+		t.Errorf("Expected an error for a connection not configured")
+	}
+
+	if err := connManager.CheckConnection("other"); err == nil {
+		t.Errorf("Expected an error for a connection that can not be checked")
+	}
+}
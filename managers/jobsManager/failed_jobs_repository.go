@@ -2,7 +2,7 @@ package jobsManager
 
 import (
 	"database/sql"
-	"log"
+	"go-queue/logger"
 )
 
 // SQLDB ... Interface for *slq.DB
@@ -12,20 +12,21 @@ type SQLDB interface {
 
 //FailedJobsRepository struct
 type FailedJobsRepository struct {
-	DB SQLDB
+	DB     SQLDB
+	Logger logger.Logger
 }
 
 //InsertQuery return Insert prepared query of failed_jobs table
 func (failedJobs *FailedJobsRepository) InsertQuery(params ...interface{}) error {
 	stmt, err := failedJobs.DB.Prepare("INSERT failed_jobs SET connection=?, queue=?, payload=?, exception=?, failed_at=?")
 	if err != nil {
-		log.Printf("Error to create InsertQuery of failed jobs error: %v", err)
+		logger.OrDefault(failedJobs.Logger).Errorf("Error to create InsertQuery of failed jobs error: %v", err)
 		return err
 	}
 
 	_, err = stmt.Exec(params...)
 	if err != nil {
-		log.Printf("Error to execute insert query in FaileJobsRepository error: %v", err)
+		logger.OrDefault(failedJobs.Logger).Errorf("Error to execute insert query in FaileJobsRepository error: %v", err)
 		return err
 	}
 
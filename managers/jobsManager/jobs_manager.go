@@ -1,14 +1,30 @@
 package jobsManager
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"go-queue/batch"
+	"go-queue/breaker"
+	"go-queue/cancellation"
+	"go-queue/chain"
+	"go-queue/claimcheck"
+	"go-queue/compression"
+	"go-queue/concurrency"
+	"go-queue/deadletter"
+	"go-queue/delayed"
+	"go-queue/dispatcher"
+	"go-queue/events"
 	"go-queue/interfaces"
+	"go-queue/lease"
+	"go-queue/logger"
 	connectionsmanager "go-queue/managers/connectionsManager"
 	"go-queue/providers"
-	"log"
+	"go-queue/ratelimit"
+	"go-queue/results"
+	"go-queue/security"
 	"strings"
 	"time"
 
@@ -22,6 +38,19 @@ type Manager struct {
 	Job         providers.JobsConfigs
 	ConnManager *connectionsmanager.Manager
 	QueueData   interface{}
+	Reservation string
+	Middlewares []providers.Middleware
+	Events      *events.Bus
+	Logger      logger.Logger
+	Breakers    *breaker.Breakers
+	Results     results.Store
+	Sealer      *security.Sealer
+	ClaimCheck  *claimcheck.Offloader
+	current     *providers.JobContext
+	duration    time.Duration
+	wireData    string
+	claim       string
+	keepClaim   bool
 }
 
 //SetConnManager sets connection manager
@@ -59,34 +88,347 @@ func (jobsManager *Manager) GetQueueData() interface{} {
 	return jobsManager.QueueData
 }
 
+//SetReservation sets the token of the reservation of the job data, empty for the jobs not reserved
+func (jobsManager *Manager) SetReservation(token string) {
+	jobsManager.Reservation = token
+}
+
+//GetReservation return the token of the reservation of the job data
+func (jobsManager *Manager) GetReservation() string {
+	return jobsManager.Reservation
+}
+
+//SetMiddlewares sets the global middlewares
+func (jobsManager *Manager) SetMiddlewares(middlewares []providers.Middleware) {
+	jobsManager.Middlewares = middlewares
+}
+
+//GetMiddlewares return the global middlewares
+func (jobsManager *Manager) GetMiddlewares() []providers.Middleware {
+	return jobsManager.Middlewares
+}
+
+//SetEvents sets the events bus
+func (jobsManager *Manager) SetEvents(bus *events.Bus) {
+	jobsManager.Events = bus
+}
+
+//GetEvents return the events bus
+func (jobsManager *Manager) GetEvents() *events.Bus {
+	return jobsManager.Events
+}
+
+//SetLogger sets the logger
+func (jobsManager *Manager) SetLogger(log logger.Logger) {
+	jobsManager.Logger = log
+}
+
+//GetLogger return the logger
+func (jobsManager *Manager) GetLogger() logger.Logger {
+	return jobsManager.Logger
+}
+
+//SetBreakers sets the circuit breakers shared by the listeners
+func (jobsManager *Manager) SetBreakers(breakers *breaker.Breakers) {
+	jobsManager.Breakers = breakers
+}
+
+//GetBreakers return the circuit breakers
+func (jobsManager *Manager) GetBreakers() *breaker.Breakers {
+	return jobsManager.Breakers
+}
+
+//SetResults sets the store of the results of the jobs
+func (jobsManager *Manager) SetResults(store results.Store) {
+	jobsManager.Results = store
+}
+
+//GetResults return the store of the results
+func (jobsManager *Manager) GetResults() results.Store {
+	return jobsManager.Results
+}
+
+//SetSealer sets the sealer that opens the jobs popped and seals the jobs pushed
+func (jobsManager *Manager) SetSealer(sealer *security.Sealer) {
+	jobsManager.Sealer = sealer
+}
+
+//GetSealer return the sealer of the jobs
+func (jobsManager *Manager) GetSealer() *security.Sealer {
+	return jobsManager.Sealer
+}
+
+//SetClaimCheck sets the offloader that fetches the payloads of the jobs popped and offloads the jobs pushed
+func (jobsManager *Manager) SetClaimCheck(offloader *claimcheck.Offloader) {
+	jobsManager.ClaimCheck = offloader
+}
+
+//GetClaimCheck return the offloader of the payloads
+func (jobsManager *Manager) GetClaimCheck() *claimcheck.Offloader {
+	return jobsManager.ClaimCheck
+}
+
+//WorkerStopping emits that the listener of the job queue stopped
+func (jobsManager *Manager) WorkerStopping(err error) {
+	jobsManager.Events.Emit(events.WorkerStopping{QueueName: jobsManager.Job.QueueName, Err: err})
+}
+
 //CallDynamically call the jobs functions by name
 func (jobsManager *Manager) CallDynamically() error {
-	jobConnections := jobsManager.ConnManager.GetJobDatabaseManagers(jobsManager.GetJob().Connections)
-	err := jobsManager.Job.Handle.(func(interface{}, map[string]interface{}) error)(jobsManager.QueueData, jobConnections)
+	defer func() {
+		jobsManager.releaseReservation()
+		jobsManager.current, jobsManager.duration, jobsManager.wireData, jobsManager.Reservation = nil, 0, "", ""
+		jobsManager.claim, jobsManager.keepClaim = "", false
+	}()
+
+	if err := jobsManager.unwrap(); claimcheck.Transient(err) {
+		return jobsManager.retryFetch(err)
+	} else if err != nil {
+		return jobsManager.reject(err)
+	}
+
+	err := jobsManager.process()
+	if err == nil && !jobsManager.keepClaim {
+		jobsManager.deleteClaim()
+	}
+
+	return err
+}
+
+//process runs the job data popped through the gates, the middlewares and the handler of the job
+func (jobsManager *Manager) process() error {
+	middlewares := append([]providers.Middleware{}, jobsManager.Middlewares...)
+	middlewares = append(middlewares, jobsManager.Job.Middlewares...)
+
+	jobContext := jobsManager.newJobContext()
+	jobsManager.current = jobContext
+
+	jobsManager.Events.Emit(events.JobReserved{Job: jobsManager.eventJob(jobContext.Payload)})
+
+	if cancelled, err := jobsManager.batchCancelled(jobContext.Payload); err != nil || cancelled {
+		return err
+	}
+
+	if cancelled, err := jobsManager.jobCancelled(jobContext); err != nil || cancelled {
+		return err
+	}
+
+	if _, ok := jobsManager.handler(jobContext); !ok {
+		return jobsManager.unrouted(jobContext)
+	}
+
+	heartbeatInterval := jobsManager.Job.Lease.HeartbeatInterval()
+	var extensions []func() error
+
+	if concurrencyConfigs := jobsManager.Job.Concurrency; concurrencyConfigs.Limited() {
+		slot := concurrency.Key(jobsManager.Job.QueueName, providers.FieldsKey(jobContext.Payload, concurrencyConfigs.Fields))
+		token := dispatcher.NewJobID()
+		semaphore := concurrency.Semaphore{Redis: jobsManager.GetClient().(interfaces.RedisInterface)}
+
+		acquired, err := semaphore.Acquire(slot, token, concurrencyConfigs.Limit, concurrencyConfigs.LeaseDuration())
+		if err != nil {
+			jobsManager.jobLogger().Errorf("Error to acquire concurrency slot: %v", err)
+			return err
+		}
+
+		if !acquired {
+			return jobsManager.release(jobContext, concurrencyConfigs.Delay(), "concurrency")
+		}
+
+		defer func() {
+			if err := semaphore.Release(slot, token); err != nil {
+				jobsManager.jobLogger().Errorf("Error to release concurrency slot: %v", err)
+			}
+		}()
+
+		extensions = append(extensions, func() error {
+			return semaphore.Extend(slot, token, concurrencyConfigs.LeaseDuration())
+		})
+		if interval := concurrencyConfigs.LeaseDuration() / 3; interval < heartbeatInterval {
+			heartbeatInterval = interval
+		}
+	}
+
+	//the rate limit is checked last so the jobs released by the other gates do not take its executions,
+	//the probe of a half open breaker is allowed after it so a throttled job does not hold the probe
+	if wait := jobsManager.Breakers.Wait(jobsManager.Job, jobContext.JobType); wait > 0 {
+		return jobsManager.release(jobContext, wait, "circuit_open")
+	}
+
+	if throttled, err := jobsManager.throttled(jobContext); err != nil || throttled {
+		return err
+	}
+
+	if allowed, wait := jobsManager.Breakers.Allow(jobsManager.Job, jobContext.JobType); !allowed {
+		return jobsManager.release(jobContext, wait, "circuit_open")
+	}
+
+	stopHeartbeat := jobsManager.heartbeat(jobContext, heartbeatInterval, extensions)
+	defer stopHeartbeat()
+
+	jobsManager.Events.Emit(events.JobProcessing{Job: jobsManager.eventJob(jobContext.Payload)})
+
+	watched, stopWatch := jobsManager.watchCancellation(jobContext)
+	jobContext.Context = watched
+
+	startTime := time.Now()
+	handler := providers.Chain(jobsManager.callHandler, middlewares...)
+	err := handler(jobContext)
+	duration := time.Since(startTime)
+	jobsManager.duration = duration
+
+	cancelled := err != nil && watched.Err() != nil
+	stopWatch()
+	if !cancelled {
+		jobsManager.Breakers.Done(jobsManager.Job, jobContext.JobType, err)
+	}
+
+	eventJob := jobsManager.eventJob(jobContext.Payload)
+	if err == nil {
+		jobsManager.Events.Emit(events.JobProcessed{Job: eventJob, Duration: duration})
+	} else {
+		jobsManager.Events.Emit(events.JobExceptionOccurred{Job: eventJob, Duration: duration, Err: err})
+	}
+
+	if cancelled {
+		return jobsManager.cancel(jobContext, true)
+	}
+
 	return jobsManager.ValidateIfJobWasProcessed(err, jobsManager.Job.QueueName)
 }
 
+func (jobsManager *Manager) callHandler(job *providers.JobContext) error {
+	handler, _ := jobsManager.handler(job)
+
+	var err error
+	switch handle := handler.(type) {
+	case func(context.Context, interface{}, map[string]interface{}) error:
+		return handle(job.Context, job.QueueData, job.Connections)
+	case func(interface{}, map[string]interface{}) (interface{}, error):
+		job.Result, err = handle(job.QueueData, job.Connections)
+		return err
+	case func(context.Context, interface{}, map[string]interface{}) (interface{}, error):
+		job.Result, err = handle(job.Context, job.QueueData, job.Connections)
+		return err
+	default:
+		return handle.(func(interface{}, map[string]interface{}) error)(job.QueueData, job.Connections)
+	}
+}
+
+//handler return the handler of the job type when the jobs of the queue are routed, or the job handler
+func (jobsManager *Manager) handler(job *providers.JobContext) (interface{}, bool) {
+	if !jobsManager.Job.Routes.Routed() {
+		return jobsManager.Job.Handle, true
+	}
+
+	return jobsManager.Job.Routes.Handler(job.JobType)
+}
+
+//unrouted applies the fallback of the routes to a job without handler for its type
+func (jobsManager *Manager) unrouted(job *providers.JobContext) error {
+	routes := jobsManager.Job.Routes
+	eventJob := jobsManager.eventJob(job.Payload)
+	log := jobsManager.jobLogger()
+
+	switch routes.Fallback {
+	case providers.FallbackDiscard:
+		log.Warnf("Discarding job without handler for its type")
+		jobsManager.Events.Emit(events.JobUnrouted{Job: eventJob, Fallback: routes.Fallback})
+		return nil
+	case providers.FallbackRequeue:
+		convertedQueueData := jobsManager.GetQueueData().([]string)
+		err := jobsManager.pushDataToQueue(routes.FallbackQueue, convertedQueueData[1])
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Job without handler for its type requeued to %v", routes.FallbackQueue)
+		jobsManager.Events.Emit(events.JobUnrouted{Job: eventJob, Fallback: routes.Fallback})
+		return nil
+	default:
+		jobError := fmt.Errorf("no handler for the job type %q", job.JobType)
+		log.WithFields(logger.Fields{"error": jobError.Error()}).Warnf("Job failed")
+		jobsManager.Events.Emit(events.JobUnrouted{Job: eventJob, Fallback: providers.FallbackFail})
+		jobsManager.Events.Emit(events.JobFailed{Job: eventJob, Err: jobError})
+
+		return jobsManager.saveFailedJob(jobsManager.Job.QueueName, job.Payload, eventJob.Attempt, jobError)
+	}
+}
+
+//release pushes the job back to the delayed jobs of the queue without consuming an attempt
+func (jobsManager *Manager) release(job *providers.JobContext, delay time.Duration, reason string) error {
+	redisClient := jobsManager.GetClient().(interfaces.RedisInterface)
+
+	err := delayed.Push(redisClient, jobsManager.Job.QueueName, jobsManager.popped(), delay)
+	if err != nil {
+		jobsManager.jobLogger().Errorf("Error to release job: %v", err)
+		return err
+	}
+
+	jobsManager.keepClaim = true
+	jobsManager.jobLogger().WithFields(logger.Fields{"reason": reason, "delay": delay.Seconds()}).Infof("Job released back to the queue")
+	jobsManager.Events.Emit(events.JobReleased{Job: jobsManager.eventJob(job.Payload), Delay: delay, Reason: reason})
+
+	return nil
+}
+
+func (jobsManager *Manager) newJobContext() *providers.JobContext {
+	job := &providers.JobContext{
+		Context:     context.Background(),
+		QueueName:   jobsManager.Job.QueueName,
+		QueueData:   jobsManager.QueueData,
+		Payload:     make(map[string]interface{}),
+		Connections: jobsManager.ConnManager.GetJobDatabaseManagers(jobsManager.GetJob().Connections),
+	}
+
+	if convertedQueueData, ok := jobsManager.QueueData.([]string); ok && len(convertedQueueData) > 1 {
+		job.Payload = jobsManager.unMarshalJobdata(convertedQueueData[1])
+	}
+
+	job.Attempt = attemptNumber(job.Payload)
+	job.JobType = jobsManager.Job.Routes.JobType(job.Payload)
+
+	return job
+}
+
 //ValidateIfJobWasProcessed check if job was successfuly
 func (jobsManager *Manager) ValidateIfJobWasProcessed(jobError error, queueName string) error {
 	if jobError == nil {
-		fmt.Printf("%v... [Processed]\n", queueName)
-		return nil
+		jobsManager.jobLogger().Infof("Job processed")
+		if jobsManager.current != nil {
+			jobsManager.saveResult(jobsManager.current.Payload, jobsManager.current.Result, nil)
+			jobsManager.recordBatch(jobsManager.current.Payload, batch.Processed)
+		}
+
+		return jobsManager.dispatchNextStep()
 	}
 
-	fmt.Printf("%v... [Failed]\n", queueName)
-	log.Printf("error: %v", jobError)
+	jobsManager.jobLogger().WithFields(logger.Fields{"error": jobError.Error()}).Warnf("Job failed")
 
 	convertedQueueData := jobsManager.GetQueueData().([]string)
-	queueData := unMarshalJobdata(convertedQueueData[1])
+	queueData := jobsManager.unMarshalJobdata(convertedQueueData[1])
+	eventJob := jobsManager.eventJob(jobsManager.unMarshalJobdata(convertedQueueData[1]))
 
 	err := jobsManager.ReenqueueJob(queueName, queueData)
 	if err == nil {
+		jobsManager.Events.Emit(events.JobRetrying{Job: eventJob, Err: jobError})
 		return err
 	}
 
-	err = jobsManager.SaveFailedJobInMysql(queueName, jobError.Error())
+	jobsManager.Events.Emit(events.JobFailed{Job: eventJob, Err: jobError})
+
+	jobsManager.saveResult(queueData, nil, jobError)
+
+	jobsManager.recordBatch(queueData, batch.Failed)
+
+	err = jobsManager.dispatchChainCatch(queueData, jobError)
 	if err != nil {
-		log.Printf("Failed to save failed job %v", err)
+		return err
+	}
+
+	err = jobsManager.saveFailedJob(queueName, queueData, eventJob.Attempt, jobError)
+	if err != nil {
+		jobsManager.jobLogger().Errorf("Failed to save failed job: %v", err)
 		return err
 	}
 
@@ -98,27 +440,274 @@ func (jobsManager *Manager) ReenqueueJob(queueKey string, queueData map[string]i
 	var requeue bool
 	queueData["attempts"], requeue = jobsManager.CheckAttempts(queueData)
 	if requeue {
-		fmt.Printf("Requeueing job...\n")
+		jobsManager.jobLogger().Infof("Requeueing job")
 		convertedQueueData := jobsManager.GetQueueData().([]string)
 		marsheledData, _ := json.Marshal(queueData)
 		convertedQueueData[1] = string(marsheledData)
 
-		err := jobsManager.pushDataToQueue(convertedQueueData[1])
+		err := jobsManager.retryDataToQueue(jobsManager.Job.QueueName, convertedQueueData[1])
 		if err != nil {
-			log.Printf("error to requeue job: %v", err)
+			jobsManager.jobLogger().Errorf("Error to requeue job: %v", err)
 			return err
 		}
 
 		return nil
 	}
 
-	log.Printf("\nThe job with ID:%v failed more than %v times",
+	jobsManager.jobLogger().Errorf("The job with ID:%v failed more than %v times",
 		queueData["id"].(string),
 		jobsManager.GetJob().Attempts)
 
 	return errors.New("Job failed many times")
 }
 
+//saveResult stores the result of the job finished when the results of the job are stored,
+//a result not stored is logged without failing the job
+func (jobsManager *Manager) saveResult(payload map[string]interface{}, value interface{}, jobError error) {
+	configs := jobsManager.Job.Result
+	jobID, _ := payload["id"].(string)
+	if !configs.Store || jobID == "" {
+		return
+	}
+
+	if jobsManager.Results == nil {
+		jobsManager.jobLogger().Warnf("Result not stored without results store")
+		return
+	}
+
+	result, err := results.New(jobID, value, jobError)
+	if err == nil {
+		err = jobsManager.Results.Save(result, configs.TTLDuration())
+	}
+
+	if err != nil {
+		jobsManager.jobLogger().Errorf("Error to store result: %v", err)
+	}
+}
+
+//heartbeat passes the heartbeat of the job to the handler to report its progress. The jobs of leased queues keep
+//their lease and their concurrency slot extended while the handler runs, the returned function stops the extensions
+func (jobsManager *Manager) heartbeat(job *providers.JobContext, interval time.Duration, extensions []func() error) func() {
+	client, ok := jobsManager.GetClient().(interfaces.RedisInterface)
+	if !ok {
+		return func() {}
+	}
+
+	jobID, _ := job.Payload["id"].(string)
+	heartbeat := &lease.Heartbeat{
+		Redis:     client,
+		QueueName: jobsManager.Job.QueueName,
+		JobID:     jobID,
+		Timeout:   jobsManager.Job.Lease.TimeoutDuration(),
+		OnProgress: func(progress lease.Progress) {
+			jobsManager.Events.Emit(events.JobProgress{Job: jobsManager.eventJob(job.Payload), Percent: progress.Percent, Message: progress.Message})
+		},
+	}
+	job.Context = lease.NewContext(job.Context, heartbeat)
+
+	if !jobsManager.Job.Lease.Enabled {
+		return func() {}
+	}
+
+	heartbeat.Token = jobsManager.Reservation
+	heartbeat.Extensions = extensions
+
+	log := jobsManager.jobLogger()
+	return heartbeat.Start(interval, func(err error) {
+		log.Errorf("Error to extend job lease: %v", err)
+	})
+}
+
+//releaseReservation removes the reservation of the job of a leased queue, reserved when it was popped
+func (jobsManager *Manager) releaseReservation() {
+	client, ok := jobsManager.GetClient().(interfaces.RedisInterface)
+	if !ok || jobsManager.Reservation == "" {
+		return
+	}
+
+	if err := lease.Release(client, jobsManager.Job.QueueName, jobsManager.Reservation); err != nil {
+		jobsManager.jobLogger().Errorf("Error to release job lease: %v", err)
+	}
+}
+
+//jobCancelled skips the job cancelled before it was popped
+func (jobsManager *Manager) jobCancelled(job *providers.JobContext) (bool, error) {
+	client, ok := jobsManager.GetClient().(interfaces.RedisInterface)
+	jobID, _ := job.Payload["id"].(string)
+	if !ok || jobID == "" {
+		return false, nil
+	}
+
+	cancelled, err := cancellation.IsCancelled(client, jobID)
+	if err != nil {
+		jobsManager.jobLogger().Errorf("Error to check job cancellation: %v", err)
+		return false, err
+	}
+
+	if !cancelled {
+		return false, nil
+	}
+
+	return true, jobsManager.cancel(job, false)
+}
+
+//throttled return if the job was released back to the queue by its rate limit
+func (jobsManager *Manager) throttled(job *providers.JobContext) (bool, error) {
+	rateLimitConfigs := jobsManager.Job.RateLimit
+	if !rateLimitConfigs.Limited() {
+		return false, nil
+	}
+
+	key := ratelimit.Key(jobsManager.Job.QueueName, providers.FieldsKey(job.Payload, rateLimitConfigs.Fields))
+	limiter := ratelimit.Limiter{Redis: jobsManager.GetClient().(interfaces.RedisInterface)}
+
+	allowed, retryAfter, err := limiter.Allow(key, rateLimitConfigs, dispatcher.NewJobID())
+	if err != nil {
+		jobsManager.jobLogger().Errorf("Error to check rate limit: %v", err)
+		return true, err
+	}
+
+	if !allowed {
+		return true, jobsManager.release(job, retryAfter, "rate_limit")
+	}
+
+	return false, nil
+}
+
+//watchCancellation return the context of the handler, cancelled when the job is cancelled while running
+func (jobsManager *Manager) watchCancellation(job *providers.JobContext) (context.Context, func()) {
+	client, ok := jobsManager.GetClient().(interfaces.RedisInterface)
+	jobID, _ := job.Payload["id"].(string)
+	if !ok || jobID == "" {
+		return context.WithCancel(job.Context)
+	}
+
+	return cancellation.Watch(job.Context, client, jobID, cancellation.DefaultCheckInterval)
+}
+
+//cancel finishes the cancelled job without retrying it
+func (jobsManager *Manager) cancel(job *providers.JobContext, running bool) error {
+	message := "Cancelled job skipped"
+	if running {
+		message = "Running job cancelled"
+	}
+
+	jobsManager.jobLogger().Infof(message)
+	jobsManager.Events.Emit(events.JobCancelled{Job: jobsManager.eventJob(job.Payload), Running: running})
+	jobsManager.saveResult(job.Payload, nil, cancellation.ErrCancelled)
+
+	jobsManager.recordBatch(job.Payload, batch.Cancelled)
+	return nil
+}
+
+//batchCancelled skips the job of a cancelled batch counting it as cancelled
+func (jobsManager *Manager) batchCancelled(payload map[string]interface{}) (bool, error) {
+	id, callback, ok := batch.FromPayload(payload)
+	if !ok || callback != "" {
+		return false, nil
+	}
+
+	cancelled, err := batch.IsCancelled(jobsManager.GetClient().(interfaces.RedisInterface), id)
+	if err != nil {
+		jobsManager.jobLogger().WithFields(logger.Fields{"batch_id": id}).Errorf("Error to check batch: %v", err)
+		return false, err
+	}
+
+	if !cancelled {
+		return false, nil
+	}
+
+	jobsManager.jobLogger().WithFields(logger.Fields{"batch_id": id}).Infof("Job of cancelled batch skipped")
+	jobsManager.recordBatch(payload, batch.Cancelled)
+	return true, nil
+}
+
+//recordBatch counts the outcome of the job in its batch and push the callbacks of the batch. The errors are
+//logged without failing the job, it is finished, stored and its chain continued even when its batch is not counted
+func (jobsManager *Manager) recordBatch(payload map[string]interface{}, outcome string) {
+	id, callback, ok := batch.FromPayload(payload)
+	if !ok || callback != "" {
+		return
+	}
+
+	log := jobsManager.jobLogger().WithFields(logger.Fields{"batch_id": id})
+
+	callbacks, err := batch.Record(jobsManager.GetClient().(interfaces.RedisInterface), id, outcome)
+	if err == batch.ErrNotFound {
+		log.Warnf("Batch of the job not found")
+		return
+	}
+
+	if err != nil {
+		log.Errorf("Error to record job in the batch: %v", err)
+		return
+	}
+
+	for _, callback := range callbacks {
+		marshaledData, err := json.Marshal(callback.Envelope)
+		if err == nil {
+			err = jobsManager.pushDataToQueue(callback.QueueName, string(marshaledData))
+		}
+
+		if err != nil {
+			log.Errorf("Error to dispatch callback of the batch: %v", err)
+		}
+	}
+}
+
+//dispatchNextStep push the next step of the chain of the job processed with its result
+func (jobsManager *Manager) dispatchNextStep() error {
+	if jobsManager.current == nil {
+		return nil
+	}
+
+	state, ok := chain.FromPayload(jobsManager.current.Payload)
+	if !ok {
+		return nil
+	}
+
+	queueName, envelope, ok := chain.Next(state, jobsManager.current.Result)
+	if !ok {
+		jobsManager.jobLogger().WithFields(logger.Fields{"chain_id": state.ID}).Infof("Chain completed")
+		return nil
+	}
+
+	return jobsManager.dispatchChainJob(queueName, envelope, state, "Error to dispatch next step of the chain")
+}
+
+//dispatchChainCatch abandons the rest of the chain of the failed job and push its catch job
+func (jobsManager *Manager) dispatchChainCatch(payload map[string]interface{}, jobError error) error {
+	state, ok := chain.FromPayload(payload)
+	if !ok {
+		return nil
+	}
+
+	jobID, _ := payload["id"].(string)
+	failure := chain.Failure{QueueName: jobsManager.Job.QueueName, JobID: jobID, Error: jobError.Error()}
+
+	queueName, envelope, ok := chain.Catch(state, failure)
+	if !ok {
+		jobsManager.jobLogger().WithFields(logger.Fields{"chain_id": state.ID}).Warnf("Chain abandoned")
+		return nil
+	}
+
+	return jobsManager.dispatchChainJob(queueName, envelope, state, "Error to dispatch catch job of the chain")
+}
+
+func (jobsManager *Manager) dispatchChainJob(queueName string, envelope map[string]interface{}, state chain.State, message string) error {
+	marshaledData, err := json.Marshal(envelope)
+	if err == nil {
+		err = jobsManager.pushDataToQueue(queueName, string(marshaledData))
+	}
+
+	if err != nil {
+		jobsManager.jobLogger().WithFields(logger.Fields{"chain_id": state.ID}).Errorf("%v: %v", message, err)
+		return err
+	}
+
+	return nil
+}
+
 //CheckAttempts the attempts of the job
 func (jobsManager *Manager) CheckAttempts(queueData map[string]interface{}) (interface{}, bool) {
 	requeue := false
@@ -135,17 +724,54 @@ func (jobsManager *Manager) CheckAttempts(queueData map[string]interface{}) (int
 	return queueData["attempts"], requeue
 }
 
-//SaveFailedJobInMysql save the data off job failed in database
+//saveFailedJob moves the job that ran out of attempts to the dead-letter queue of the job when it is enabled,
+//or to the failed_jobs table, attempts is the number of attempts made. The job is stored as it was popped,
+//so the sealed jobs stay encrypted and signed in the failure stores, the offloaded jobs keep only their
+//claim check, and they are redriven as they are
+func (jobsManager *Manager) saveFailedJob(queueName string, payload map[string]interface{}, attempts float64, jobError error) error {
+	jobsManager.keepClaim = true
+
+	configs := jobsManager.Job.DeadLetter
+	if !configs.Enabled {
+		return jobsManager.SaveFailedJobInMysql(queueName, jobError.Error())
+	}
+
+	client, ok := jobsManager.GetClient().(deadletter.PushInterface)
+	if !ok {
+		return errors.New("Dead-letter queue without redis client")
+	}
+
+	jobID, _ := payload["id"].(string)
+	entry := deadletter.Entry{
+		QueueName: jobsManager.Job.QueueName,
+		JobID:     jobID,
+		JobType:   jobsManager.Job.Routes.JobType(payload),
+		Payload:   jobsManager.popped(),
+		Error:     jobError.Error(),
+		Attempts:  attempts,
+		FailedAt:  time.Now(),
+	}
+
+	deadLetterQueue := configs.QueueName(jobsManager.Job.QueueName)
+	if err := deadletter.Push(client, deadLetterQueue, entry); err != nil {
+		return err
+	}
+
+	jobsManager.jobLogger().WithFields(logger.Fields{"dead_letter_queue": deadLetterQueue}).Infof("Job moved to the dead-letter queue")
+	return nil
+}
+
+//SaveFailedJobInMysql save the data off job failed in database, as it was popped
 func (jobsManager *Manager) SaveFailedJobInMysql(queue string, jobError string) error {
 	db := jobsManager.ConnManager.DBClients["mysql"].(*sql.DB)
 
-	failedJobsRepository := FailedJobsRepository{DB: db}
+	failedJobsRepository := FailedJobsRepository{DB: db, Logger: jobsManager.Logger}
 	convertedQueueData := jobsManager.GetQueueData().([]string)
 
 	queueName := strings.Split(convertedQueueData[0], ":")
 	nowTime := time.Now()
 
-	err := failedJobsRepository.InsertQuery(queueName[1], queueName[1], convertedQueueData[1], jobError, nowTime.Format("20060102150405"))
+	err := failedJobsRepository.InsertQuery(queueName[1], queueName[1], jobsManager.popped(), jobError, nowTime.Format("20060102150405"))
 	if err != nil {
 		return err
 	}
@@ -153,23 +779,162 @@ func (jobsManager *Manager) SaveFailedJobInMysql(queue string, jobError string)
 	return nil
 }
 
-func unMarshalJobdata(jobData string) map[string]interface{} {
+func (jobsManager *Manager) eventJob(payload map[string]interface{}) events.Job {
+	job := events.Job{
+		Context:   context.Background(),
+		QueueName: jobsManager.Job.QueueName,
+		JobType:   jobsManager.Job.Routes.JobType(payload),
+		Payload:   payload,
+		Attempt:   attemptNumber(payload),
+	}
+
+	if jobsManager.current != nil {
+		job.Context = jobsManager.current.Context
+	}
+
+	if id, ok := payload["id"].(string); ok {
+		job.JobID = id
+	}
+
+	return job
+}
+
+//attemptNumber return the number of the current attempt, the payload holds the failed ones
+func attemptNumber(payload map[string]interface{}) float64 {
+	if attempts, ok := payload["attempts"].(float64); ok {
+		return attempts + 1
+	}
+
+	return 1
+}
+
+//jobLogger return the logger with the fields of the job in processing
+func (jobsManager *Manager) jobLogger() logger.Logger {
+	fields := logger.Fields{
+		"queue":  jobsManager.Job.QueueName,
+		"driver": jobsManager.Job.Driver,
+	}
+
+	if convertedQueueData, ok := jobsManager.QueueData.([]string); ok && len(convertedQueueData) > 1 {
+		payload := make(map[string]interface{})
+		if json.Unmarshal([]byte(convertedQueueData[1]), &payload) == nil {
+			fields["job_id"] = payload["id"]
+			fields["attempt"] = attemptNumber(payload)
+			if jobsManager.Job.Routes.Routed() {
+				fields["job_type"] = jobsManager.Job.Routes.JobType(payload)
+			}
+		}
+	}
+
+	if jobsManager.duration > 0 {
+		fields["duration"] = jobsManager.duration.Seconds()
+	}
+
+	return logger.OrDefault(jobsManager.Logger).WithFields(fields)
+}
+
+//unwrap fetches, verifies, decrypts and decompresses the job data, keeping the data as it was popped
+func (jobsManager *Manager) unwrap() error {
+	convertedQueueData, ok := jobsManager.QueueData.([]string)
+	if !ok || len(convertedQueueData) < 2 {
+		return nil
+	}
+
+	data, claim, err := jobsManager.ClaimCheck.Fetch(context.Background(), convertedQueueData[1])
+	if claimcheck.Transient(err) {
+		return err
+	}
+
+	if err != nil {
+		return fmt.Errorf("job data can not be fetched: %v", err)
+	}
+	jobsManager.claim = claim
+
+	data, err = jobsManager.Sealer.Open(data)
+	if err != nil {
+		return fmt.Errorf("job data can not be opened: %v", err)
+	}
+
+	data, err = compression.Decompress(data)
+	if err != nil {
+		return fmt.Errorf("job data can not be decompressed: %v", err)
+	}
+
+	if data != convertedQueueData[1] {
+		jobsManager.wireData = convertedQueueData[1]
+		jobsManager.QueueData = []string{convertedQueueData[0], data}
+	}
+
+	return nil
+}
+
+//deleteClaim removes the payload of the job done from the claim check store, the jobs released back
+//to the queue and the jobs in the failure stores keep their payload until they are redriven or purged.
+//A payload not removed is logged without failing the job
+func (jobsManager *Manager) deleteClaim() {
+	if err := jobsManager.ClaimCheck.Delete(context.Background(), jobsManager.claim); err != nil {
+		jobsManager.jobLogger().WithFields(logger.Fields{"claim_check": jobsManager.claim}).Errorf("Error to delete job payload: %v", err)
+	}
+}
+
+//popped return the job data as it was popped from the queue, sealed and compressed as it was pushed
+func (jobsManager *Manager) popped() string {
+	if jobsManager.wireData != "" {
+		return jobsManager.wireData
+	}
+
+	return jobsManager.GetQueueData().([]string)[1]
+}
+
+//retryFetch releases the job whose payload can not be fetched from the claim check store now, the store
+//is down or not configured, back to the queue without consuming an attempt
+func (jobsManager *Manager) retryFetch(fetchError error) error {
+	convertedQueueData := jobsManager.GetQueueData().([]string)
+	job := &providers.JobContext{Payload: jobsManager.unMarshalJobdata(convertedQueueData[1])}
+
+	jobsManager.jobLogger().WithFields(logger.Fields{"error": fetchError.Error()}).Warnf("Job data can not be fetched")
+	return jobsManager.release(job, providers.DefaultReleaseDelay, "claim_check")
+}
+
+//reject moves the job that can not be read to the failure store without calling its handler
+func (jobsManager *Manager) reject(jobError error) error {
+	convertedQueueData := jobsManager.GetQueueData().([]string)
+	payload := jobsManager.unMarshalJobdata(convertedQueueData[1])
+	eventJob := jobsManager.eventJob(payload)
+
+	jobsManager.jobLogger().WithFields(logger.Fields{"error": jobError.Error()}).Errorf("Job rejected")
+	jobsManager.Events.Emit(events.JobFailed{Job: eventJob, Err: jobError})
+
+	return jobsManager.saveFailedJob(jobsManager.Job.QueueName, payload, eventJob.Attempt, jobError)
+}
+
+func (jobsManager *Manager) unMarshalJobdata(jobData string) map[string]interface{} {
 	queueData := make(map[string]interface{})
 	errNew := json.Unmarshal([]byte(jobData), &queueData)
 
 	if errNew != nil {
-		log.Printf("Erro: %v\n", errNew)
+		logger.OrDefault(jobsManager.Logger).Errorf("Error to decode job data: %v", errNew)
 	}
 
 	return queueData
 }
 
-func (jobsManager *Manager) pushDataToQueue(data string) error {
+//pushDataToQueue pushes a new job to the tail of the queue, it is popped after the jobs already in the queue
+func (jobsManager *Manager) pushDataToQueue(queueName string, data string) error {
+	return jobsManager.pushData(queueName, data, false)
+}
+
+//retryDataToQueue pushes the retry of the job to the head of the queue, it is popped before the jobs already in the queue
+func (jobsManager *Manager) retryDataToQueue(queueName string, data string) error {
+	return jobsManager.pushData(queueName, data, true)
+}
+
+func (jobsManager *Manager) pushData(queueName string, data string, retry bool) error {
 	var err error
 
 	switch driver := jobsManager.GetJob().Driver; driver {
 	case "redis":
-		err = jobsManager.pushRedis(data)
+		err = jobsManager.pushRedis(queueName, data, retry)
 	default:
 		err = errors.New("Job type connection invalid")
 	}
@@ -177,11 +942,34 @@ func (jobsManager *Manager) pushDataToQueue(data string) error {
 	return err
 }
 
-func (jobsManager *Manager) pushRedis(data string) error {
+func (jobsManager *Manager) pushRedis(queueName string, data string, retry bool) error {
+	data, err := compression.Compress(data, jobsManager.Job.Compression)
+	if err != nil {
+		jobsManager.jobLogger().Errorf("Error to compress job: %v", err)
+		return err
+	}
+
+	data, err = jobsManager.Sealer.Seal(data)
+	if err != nil {
+		jobsManager.jobLogger().Errorf("Error to seal job: %v", err)
+		return err
+	}
+
+	data, err = jobsManager.ClaimCheck.Offload(context.Background(), data)
+	if err != nil {
+		jobsManager.jobLogger().Errorf("Error to offload job: %v", err)
+		return err
+	}
+
 	redisClient := jobsManager.GetClient().(interfaces.RedisInterface)
-	err := redisClient.LPush(jobsManager.Job.QueueName, data).Err()
+	if retry {
+		err = redisClient.LPush(queueName, data).Err()
+	} else {
+		err = redisClient.RPush(queueName, data).Err()
+	}
+
 	if err != nil {
-		log.Printf("Error to reenqueue job: %v", err)
+		jobsManager.jobLogger().Errorf("Error to reenqueue job: %v", err)
 		return err
 	}
 
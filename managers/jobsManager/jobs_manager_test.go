@@ -1,16 +1,36 @@
 package jobsManager
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"go-queue/batch"
+	"go-queue/breaker"
+	"go-queue/cancellation"
+	"go-queue/chain"
+	"go-queue/claimcheck"
+	"go-queue/compression"
+	"go-queue/concurrency"
+	"go-queue/deadletter"
+	"go-queue/dispatcher"
+	"go-queue/events"
+	"go-queue/lease"
+	"go-queue/logger"
 	connectionsmanager "go-queue/managers/connectionsManager"
 	"go-queue/providers"
+	"go-queue/results"
+	"go-queue/security"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis"
 	"github.com/go-redis/redis"
+	"github.com/sirupsen/logrus"
 )
 
 /************************ Mocks ******************/
@@ -29,6 +49,10 @@ func (r *RedisMock) LPush(key string, values ...interface{}) *redis.IntCmd {
 	return redis.NewIntResult(0, errors.New("Test"))
 }
 
+func (r *RedisMock) RPush(key string, values ...interface{}) *redis.IntCmd {
+	return r.LPush(key, values...)
+}
+
 func (r *RedisMock) LLen(queueName string) *redis.IntCmd {
 	return redis.NewIntResult(1, nil)
 }
@@ -37,6 +61,162 @@ func (r *RedisMock) BLPop(timeVar time.Duration, args ...string) *redis.StringSl
 	return redis.NewStringSliceResult([]string{"teste"}, nil)
 }
 
+func (r *RedisMock) ZAdd(key string, members ...redis.Z) *redis.IntCmd {
+	return redis.NewIntResult(1, nil)
+}
+
+func (r *RedisMock) Eval(script string, keys []string, args ...interface{}) *redis.Cmd {
+	return redis.NewCmdResult(int64(1), nil)
+}
+
+func (r *RedisMock) Exists(keys ...string) *redis.IntCmd {
+	return redis.NewIntResult(0, nil)
+}
+
+//SemaphoreRedisMock has no free concurrency slot and records the delayed jobs
+type SemaphoreRedisMock struct {
+	RedisMock
+	delayed []string
+	evals   int
+}
+
+func (r *SemaphoreRedisMock) ZAdd(key string, members ...redis.Z) *redis.IntCmd {
+	r.delayed = append(r.delayed, key)
+	return redis.NewIntResult(1, nil)
+}
+
+func (r *SemaphoreRedisMock) Eval(script string, keys []string, args ...interface{}) *redis.Cmd {
+	r.evals++
+	return redis.NewCmdResult(int64(0), nil)
+}
+
+//RateLimitRedisMock throttles the jobs for 2 seconds unless allowed and records the delayed jobs
+type RateLimitRedisMock struct {
+	SemaphoreRedisMock
+	allowed bool
+	keys    []string
+}
+
+func (r *RateLimitRedisMock) Eval(script string, keys []string, args ...interface{}) *redis.Cmd {
+	r.keys = append(r.keys, keys...)
+	if r.allowed {
+		return redis.NewCmdResult([]interface{}{int64(1), int64(0)}, nil)
+	}
+
+	return redis.NewCmdResult([]interface{}{int64(0), int64(2000)}, nil)
+}
+
+//PushRedisMock records the jobs pushed by queue
+type PushRedisMock struct {
+	RedisMock
+	pushed map[string][]string
+}
+
+func (r *PushRedisMock) LPush(key string, values ...interface{}) *redis.IntCmd {
+	if r.pushed == nil {
+		r.pushed = make(map[string][]string)
+	}
+
+	r.pushed[key] = append(r.pushed[key], values[0].(string))
+	return redis.NewIntResult(1, nil)
+}
+
+func (r *PushRedisMock) RPush(key string, values ...interface{}) *redis.IntCmd {
+	return r.LPush(key, values...)
+}
+
+//BatchRedisMock answers if the batch is cancelled and the counts of the batch, or the error of the counts,
+//recording the jobs pushed
+type BatchRedisMock struct {
+	PushRedisMock
+	cancelled string
+	record    []interface{}
+	outcomes  []interface{}
+	err       error
+}
+
+func (r *BatchRedisMock) Eval(script string, keys []string, args ...interface{}) *redis.Cmd {
+	if !strings.Contains(script, "HINCRBY") {
+		return redis.NewCmdResult(r.cancelled, nil)
+	}
+
+	r.outcomes = append(r.outcomes, args[0])
+	return redis.NewCmdResult(r.record, r.err)
+}
+
+//ClaimCheckStoreMock keeps the payloads offloaded in memory, failing the reads with err when it is set
+type ClaimCheckStoreMock struct {
+	payloads map[string][]byte
+	err      error
+}
+
+func (s *ClaimCheckStoreMock) Put(ctx context.Context, key string, data []byte) error {
+	s.payloads[key] = data
+	return nil
+}
+
+func (s *ClaimCheckStoreMock) Get(ctx context.Context, key string) ([]byte, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	data, ok := s.payloads[key]
+	if !ok {
+		return nil, claimcheck.ErrNotFound
+	}
+
+	return data, nil
+}
+
+func (s *ClaimCheckStoreMock) Delete(ctx context.Context, key string) error {
+	delete(s.payloads, key)
+	return nil
+}
+
+//ResultsStoreMock records the results saved
+type ResultsStoreMock struct {
+	saved []results.Result
+	ttl   time.Duration
+}
+
+func (s *ResultsStoreMock) Save(result results.Result, ttl time.Duration) error {
+	s.saved = append(s.saved, result)
+	s.ttl = ttl
+	return nil
+}
+
+func (s *ResultsStoreMock) Get(jobID string) (results.Result, error) {
+	return results.Result{}, results.ErrNotFound
+}
+
+//CancelledRedisMock has all jobs cancelled
+type CancelledRedisMock struct {
+	RedisMock
+}
+
+func (r *CancelledRedisMock) Exists(keys ...string) *redis.IntCmd {
+	return redis.NewIntResult(1, nil)
+}
+
+//LeaseRedisMock records the lease calls of the reserved jobs and the extensions of their concurrency slots
+type LeaseRedisMock struct {
+	RedisMock
+	leases []string
+}
+
+func (r *LeaseRedisMock) Eval(script string, keys []string, args ...interface{}) *redis.Cmd {
+	switch {
+	case strings.HasSuffix(keys[0], ":reserved") && strings.Contains(script, "HDEL"):
+		r.leases = append(r.leases, "release")
+	case strings.HasSuffix(keys[0], ":reserved"):
+		r.leases = append(r.leases, "extend")
+	case strings.HasPrefix(keys[0], "concurrency:") && strings.Contains(script, "ZSCORE"):
+		r.leases = append(r.leases, "extend slot")
+	}
+
+	return redis.NewCmdResult(int64(1), nil)
+}
+
 /*********************** TESTS ******************/
 func TestGetAndSetClient(t *testing.T) {
 	jobManager := Manager{}
@@ -81,7 +261,7 @@ func TestGetAndSetQueueData(t *testing.T) {
 func TestCallDynamically(t *testing.T) {
 	var job = Manager{}
 	job.Client = "teste"
-	job.Job = providers.JobsConfigs{"test", "test", HandlerTest, float64(1), []string{"teste"}}
+	job.Job = providers.JobsConfigs{QueueName: "test", Driver: "test", Handle: HandlerTest, Attempts: float64(1), Connections: []string{"teste"}}
 	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
 	job.QueueData = "teste"
 
@@ -92,10 +272,91 @@ func TestCallDynamically(t *testing.T) {
 	}
 }
 
+func TestCallDynamicallyRunMiddlewares(t *testing.T) {
+	var calls []string
+	var received *providers.JobContext
+
+	recorder := func(name string) providers.Middleware {
+		return func(next providers.HandlerFunc) providers.HandlerFunc {
+			return func(job *providers.JobContext) error {
+				calls = append(calls, name)
+				received = job
+				return next(job)
+			}
+		}
+	}
+
+	dbClients := make(map[string]interface{})
+	dbClients["mongo"] = "teste"
+
+	var job = Manager{}
+	job.Middlewares = []providers.Middleware{recorder("global")}
+	job.Job = providers.JobsConfigs{
+		QueueName:   "test",
+		Handle:      HandlerTest,
+		Attempts:    float64(3),
+		Connections: []string{"mongo"},
+		Middlewares: []providers.Middleware{recorder("job")},
+	}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: dbClients}
+	job.QueueData = []string{"queues:test", `{"id": "test", "attempts": 1}`}
+
+	returned := job.CallDynamically()
+
+	if returned != nil {
+		t.Errorf("Expected error is nil but got %v", returned)
+	}
+
+	if !reflect.DeepEqual(calls, []string{"global", "job"}) {
+		t.Errorf("Expected global middleware before job middleware but got %v", calls)
+	}
+
+	if received.Payload["id"] != "test" {
+		t.Errorf("Expected decoded payload with id 'test' but got %v", received.Payload)
+	}
+
+	if received.Attempt != 2 {
+		t.Errorf("Expected attempt number 2 but got %v", received.Attempt)
+	}
+
+	if received.Connections["mongo"] != "teste" {
+		t.Errorf("Expected job connections in the context but got %v", received.Connections)
+	}
+}
+
+func TestCallDynamicallyMiddlewareShortCircuit(t *testing.T) {
+	handlerCalled := false
+	handler := func(paramTest interface{}, paramTestConn map[string]interface{}) error {
+		handlerCalled = true
+		return nil
+	}
+
+	skip := func(next providers.HandlerFunc) providers.HandlerFunc {
+		return func(job *providers.JobContext) error {
+			return nil
+		}
+	}
+
+	var job = Manager{}
+	job.Job = providers.JobsConfigs{QueueName: "test", Handle: handler, Middlewares: []providers.Middleware{skip}}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = "teste"
+
+	returned := job.CallDynamically()
+
+	if returned != nil {
+		t.Errorf("Expected error is nil but got %v", returned)
+	}
+
+	if handlerCalled {
+		t.Errorf("Expected handler not to be called")
+	}
+}
+
 func TestCheckAttemptsWithoutAttempts(t *testing.T) {
 	var job = Manager{}
 	job.Client = "teste"
-	job.Job = providers.JobsConfigs{"test", "test", HandlerTest, float64(1), []string{"teste"}}
+	job.Job = providers.JobsConfigs{QueueName: "test", Driver: "test", Handle: HandlerTest, Attempts: float64(1), Connections: []string{"teste"}}
 	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
 	job.QueueData = "teste"
 
@@ -113,7 +374,7 @@ func TestCheckAttemptsWithoutAttempts(t *testing.T) {
 func TestCheckAttemptsWithAttempts(t *testing.T) {
 	var job = Manager{}
 	job.Client = "teste"
-	job.Job = providers.JobsConfigs{"test", "test", HandlerTest, float64(4), []string{"teste"}}
+	job.Job = providers.JobsConfigs{QueueName: "test", Driver: "test", Handle: HandlerTest, Attempts: float64(4), Connections: []string{"teste"}}
 	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
 	job.QueueData = "teste"
 
@@ -344,14 +605,1316 @@ func TestRequeueJobDoNotEnterInRequeueIfReturnError(t *testing.T) {
 }
 
 func TestUnMarshalJobDataReturnError(t *testing.T) {
-	mapTest := unMarshalJobdata("")
+	jobManager := Manager{}
+	mapTest := jobManager.unMarshalJobdata("")
 	fmt.Println(mapTest)
 }
 
 func TestPushDataToQueueReturnWrongData(t *testing.T) {
 	jobManager := Manager{}
-	err := jobManager.pushDataToQueue("test")
+	err := jobManager.pushDataToQueue("queue:test", "test")
 	if err == nil {
 		t.Error("Expected an error but got nil")
 	}
 }
+
+func recordEvents(bus *events.Bus) *[]string {
+	var names []string
+	bus.Subscribe(events.AllEvents, func(event events.Event) {
+		names = append(names, event.Name())
+	})
+
+	return &names
+}
+
+func TestCallDynamicallyEmitProcessedEvents(t *testing.T) {
+	bus := events.NewBus()
+	names := recordEvents(bus)
+
+	var processed events.JobProcessed
+	bus.Subscribe(events.JobProcessedEvent, func(event events.Event) {
+		processed = event.(events.JobProcessed)
+	})
+
+	var job = Manager{Events: bus}
+	job.Job = providers.JobsConfigs{QueueName: "test", Handle: HandlerTest}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = []string{"queues:test", `{"id": "test", "attempts": 2}`}
+
+	job.CallDynamically()
+
+	expected := []string{events.JobReservedEvent, events.JobProcessingEvent, events.JobProcessedEvent}
+	if !reflect.DeepEqual(*names, expected) {
+		t.Errorf("Expected events %v but got %v", expected, *names)
+	}
+
+	if processed.JobID != "test" || processed.Attempt != 3 || processed.QueueName != "test" {
+		t.Errorf("Event job data is different from expected %v", processed.Job)
+	}
+}
+
+func TestCallDynamicallyEmitRetryingEvents(t *testing.T) {
+	bus := events.NewBus()
+	names := recordEvents(bus)
+
+	handler := func(paramTest interface{}, paramTestConn map[string]interface{}) error {
+		return errors.New("test")
+	}
+
+	var job = Manager{Events: bus, Client: &RedisMock{}}
+	job.Job = providers.JobsConfigs{QueueName: "test1", Driver: "redis", Handle: handler, Attempts: float64(3)}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = []string{"queues:test1", `{"id": "test", "attempts": 0}`}
+
+	job.CallDynamically()
+
+	expected := []string{events.JobReservedEvent, events.JobProcessingEvent, events.JobExceptionOccurredEvent, events.JobRetryingEvent}
+	if !reflect.DeepEqual(*names, expected) {
+		t.Errorf("Expected events %v but got %v", expected, *names)
+	}
+}
+
+func TestValidateIfWasProcessedEmitFailedEvent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	defer db.Close()
+
+	mock.ExpectPrepare("INSERT failed_jobs SET connection=\\?, queue=\\?, payload=\\?, exception=\\?, failed_at=\\?")
+	mock.ExpectExec("INSERT failed_jobs SET connection=\\?, queue=\\?, payload=\\?, exception=\\?, failed_at=\\?").WillReturnResult(sqlmock.NewResult(1, 1))
+
+	dbClientsMock := make(map[string]interface{})
+	dbClientsMock["mysql"] = db
+
+	bus := events.NewBus()
+	var failed events.JobFailed
+	bus.Subscribe(events.JobFailedEvent, func(event events.Event) {
+		failed = event.(events.JobFailed)
+	})
+
+	jobManager := &Manager{Events: bus, Client: &RedisMock{}}
+	jobManager.Job = providers.JobsConfigs{QueueName: "test1", Driver: "redis", Attempts: float64(0)}
+	jobManager.ConnManager = &connectionsmanager.Manager{DBClients: dbClientsMock}
+	jobManager.QueueData = []string{"queue:test", `{"id": "test", "attempts":0}`}
+
+	jobManager.ValidateIfJobWasProcessed(errors.New("test"), "test")
+
+	if failed.JobID != "test" || failed.Err == nil || failed.Err.Error() != "test" {
+		t.Errorf("Expected JobFailed event with the job error but got %v", failed)
+	}
+}
+
+func TestWorkerStoppingEmitEvent(t *testing.T) {
+	bus := events.NewBus()
+
+	var stopping events.WorkerStopping
+	bus.Subscribe(events.WorkerStoppingEvent, func(event events.Event) {
+		stopping = event.(events.WorkerStopping)
+	})
+
+	jobManager := &Manager{}
+	jobManager.SetEvents(bus)
+	jobManager.SetJob(providers.JobsConfigs{QueueName: "test"})
+
+	if jobManager.GetEvents() != bus {
+		t.Errorf("Events bus returned is different from setted")
+	}
+
+	jobManager.WorkerStopping(errors.New("test"))
+
+	if stopping.QueueName != "test" || stopping.Err.Error() != "test" {
+		t.Errorf("Expected WorkerStopping event of queue test but got %v", stopping)
+	}
+}
+
+type contextKey string
+
+func TestCallDynamicallyPassContextToHandler(t *testing.T) {
+	var received context.Context
+	handler := func(ctx context.Context, paramTest interface{}, paramTestConn map[string]interface{}) error {
+		received = ctx
+		return nil
+	}
+
+	setValue := func(next providers.HandlerFunc) providers.HandlerFunc {
+		return func(job *providers.JobContext) error {
+			job.Context = context.WithValue(job.Context, contextKey("test"), "value")
+			return next(job)
+		}
+	}
+
+	bus := events.NewBus()
+	var processed events.JobProcessed
+	bus.Subscribe(events.JobProcessedEvent, func(event events.Event) {
+		processed = event.(events.JobProcessed)
+	})
+
+	var job = Manager{Events: bus, Middlewares: []providers.Middleware{setValue}}
+	job.Job = providers.JobsConfigs{QueueName: "test", Handle: handler}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = "teste"
+
+	job.CallDynamically()
+
+	if received == nil || received.Value(contextKey("test")) != "value" {
+		t.Errorf("Expected handler to receive the context of the middlewares")
+	}
+
+	if processed.Context.Value(contextKey("test")) != "value" {
+		t.Errorf("Expected event to carry the context of the middlewares")
+	}
+}
+
+func TestCallDynamicallyLogJobFields(t *testing.T) {
+	out := &bytes.Buffer{}
+	log := logrus.New()
+	log.Out = out
+	log.Formatter = &logrus.JSONFormatter{}
+
+	var job = Manager{Logger: logger.New(log)}
+	job.Job = providers.JobsConfigs{QueueName: "test", Driver: "redis", Handle: HandlerTest}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = []string{"queues:test", `{"id": "test", "attempts": 1}`}
+
+	job.CallDynamically()
+
+	line := make(map[string]interface{})
+	json.Unmarshal(out.Bytes(), &line)
+
+	if line["msg"] != "Job processed" || line["queue"] != "test" || line["driver"] != "redis" {
+		t.Errorf("Log line is different from expected %v", line)
+	}
+
+	if line["job_id"] != "test" || line["attempt"] != float64(2) {
+		t.Errorf("Expected job id and attempt in the log line but got %v", line)
+	}
+
+	if _, ok := line["duration"]; !ok {
+		t.Errorf("Expected duration in the log line but got %v", line)
+	}
+}
+
+func routedJob(fallback string) providers.JobsConfigs {
+	return providers.JobsConfigs{QueueName: "queues:default", Driver: "redis", Attempts: float64(1), Routes: providers.RoutesConfigs{
+		Field: "displayName",
+		Handlers: map[string]interface{}{
+			"SendEmail": HandlerTest,
+			"SendSms": func(paramTest interface{}, paramTestConn map[string]interface{}) error {
+				return errors.New("test")
+			},
+		},
+		Fallback:      fallback,
+		FallbackQueue: "test1",
+	}}
+}
+
+func TestCallDynamicallyRouteByJobType(t *testing.T) {
+	bus := events.NewBus()
+	var processed events.JobProcessed
+	bus.Subscribe(events.JobProcessedEvent, func(event events.Event) {
+		processed = event.(events.JobProcessed)
+	})
+
+	job := Manager{Events: bus, Job: routedJob("")}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = []string{"queues:default", `{"id": "test", "displayName": "SendEmail"}`}
+
+	err := job.CallDynamically()
+	if err != nil {
+		t.Errorf("Expected no error but got %v", err)
+	}
+
+	if processed.JobType != "SendEmail" {
+		t.Errorf("Expected SendEmail processed but got %v", processed.Job)
+	}
+}
+
+func TestCallDynamicallyDiscardUnknownJobType(t *testing.T) {
+	bus := events.NewBus()
+	names := recordEvents(bus)
+
+	job := Manager{Events: bus, Job: routedJob(providers.FallbackDiscard)}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = []string{"queues:default", `{"id": "test", "displayName": "Unknown"}`}
+
+	err := job.CallDynamically()
+	if err != nil {
+		t.Errorf("Expected no error but got %v", err)
+	}
+
+	expected := []string{events.JobReservedEvent, events.JobUnroutedEvent}
+	if !reflect.DeepEqual(*names, expected) {
+		t.Errorf("Expected events %v but got %v", expected, *names)
+	}
+}
+
+func TestCallDynamicallyRequeueUnknownJobType(t *testing.T) {
+	bus := events.NewBus()
+	var unrouted events.JobUnrouted
+	bus.Subscribe(events.JobUnroutedEvent, func(event events.Event) {
+		unrouted = event.(events.JobUnrouted)
+	})
+
+	job := Manager{Events: bus, Client: &RedisMock{}, Job: routedJob(providers.FallbackRequeue)}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = []string{"queues:default", `{"id": "test", "displayName": "Unknown"}`}
+
+	err := job.CallDynamically()
+	if err != nil {
+		t.Errorf("Expected no error but got %v", err)
+	}
+
+	if unrouted.Fallback != providers.FallbackRequeue || unrouted.JobType != "Unknown" {
+		t.Errorf("Expected unknown job requeued but got %v", unrouted)
+	}
+
+	job.Job.Routes.FallbackQueue = "error"
+	if err := job.CallDynamically(); err == nil {
+		t.Errorf("Expected an error when requeue fails")
+	}
+}
+
+func TestCallDynamicallyFailUnknownJobType(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	defer db.Close()
+
+	mock.ExpectPrepare("INSERT failed_jobs SET connection=\\?, queue=\\?, payload=\\?, exception=\\?, failed_at=\\?")
+	mock.ExpectExec("INSERT failed_jobs SET connection=\\?, queue=\\?, payload=\\?, exception=\\?, failed_at=\\?").WillReturnResult(sqlmock.NewResult(1, 1))
+
+	bus := events.NewBus()
+	names := recordEvents(bus)
+
+	job := Manager{Events: bus, Client: &RedisMock{}, Job: routedJob("")}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: map[string]interface{}{"mysql": db}}
+	job.QueueData = []string{"queues:default", `{"id": "test", "displayName": "Unknown"}`}
+
+	err = job.CallDynamically()
+	if err != nil {
+		t.Errorf("Expected no error but got %v", err)
+	}
+
+	expected := []string{events.JobReservedEvent, events.JobUnroutedEvent, events.JobFailedEvent}
+	if !reflect.DeepEqual(*names, expected) {
+		t.Errorf("Expected events %v but got %v", expected, *names)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Expected failed job saved but got %v", err)
+	}
+}
+
+func TestCallDynamicallyReleaseJobWithoutConcurrencySlot(t *testing.T) {
+	bus := events.NewBus()
+	names := recordEvents(bus)
+
+	var released events.JobReleased
+	bus.Subscribe(events.JobReleasedEvent, func(event events.Event) {
+		released = event.(events.JobReleased)
+	})
+
+	called := false
+	handler := func(paramTest interface{}, paramTestConn map[string]interface{}) error {
+		called = true
+		return nil
+	}
+
+	redisMock := &SemaphoreRedisMock{}
+	job := Manager{Events: bus, Client: redisMock}
+	job.Job = providers.JobsConfigs{QueueName: "queues:test", Driver: "redis", Handle: handler, Attempts: float64(1),
+		Concurrency: providers.ConcurrencyConfigs{Fields: []string{"account"}, Limit: 1, ReleaseDelay: time.Minute}}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = []string{"queues:test", `{"id": "test", "attempts": 0, "account": 1}`}
+
+	err := job.CallDynamically()
+	if err != nil {
+		t.Errorf("Expected no error but got %v", err)
+	}
+
+	if called {
+		t.Errorf("Expected handler not called without concurrency slot")
+	}
+
+	if len(redisMock.delayed) != 1 || redisMock.delayed[0] != "queues:test:delayed" {
+		t.Errorf("Expected job released to the delayed jobs but got %v", redisMock.delayed)
+	}
+
+	expected := []string{events.JobReservedEvent, events.JobReleasedEvent}
+	if !reflect.DeepEqual(*names, expected) || released.Delay != time.Minute || released.Reason != "concurrency" {
+		t.Errorf("Expected released event but got %v %v", *names, released)
+	}
+}
+
+func TestCallDynamicallyRunJobWithConcurrencySlot(t *testing.T) {
+	called := false
+	handler := func(paramTest interface{}, paramTestConn map[string]interface{}) error {
+		called = true
+		return nil
+	}
+
+	job := Manager{Client: &RedisMock{}}
+	job.Job = providers.JobsConfigs{QueueName: "queues:test", Driver: "redis", Handle: handler, Attempts: float64(1),
+		Concurrency: providers.ConcurrencyConfigs{Fields: []string{"account"}, Limit: 1}}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = []string{"queues:test", `{"id": "test", "attempts": 0, "account": 1}`}
+
+	err := job.CallDynamically()
+	if err != nil || !called {
+		t.Errorf("Expected handler called with concurrency slot but got %v", err)
+	}
+}
+
+func TestCallDynamicallyReleaseThrottledJob(t *testing.T) {
+	bus := events.NewBus()
+	names := recordEvents(bus)
+
+	var released events.JobReleased
+	bus.Subscribe(events.JobReleasedEvent, func(event events.Event) {
+		released = event.(events.JobReleased)
+	})
+
+	called := false
+	handler := func(paramTest interface{}, paramTestConn map[string]interface{}) error {
+		called = true
+		return nil
+	}
+
+	redisMock := &RateLimitRedisMock{}
+	job := Manager{Events: bus, Client: redisMock}
+	job.Job = providers.JobsConfigs{QueueName: "queues:test", Driver: "redis", Handle: handler, Attempts: float64(1),
+		RateLimit: providers.RateLimitConfigs{Limit: 100, Per: time.Minute, Fields: []string{"account"}}}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = []string{"queues:test", `{"id": "test", "attempts": 0, "account": 1}`}
+
+	err := job.CallDynamically()
+	if err != nil {
+		t.Errorf("Expected no error but got %v", err)
+	}
+
+	if called {
+		t.Errorf("Expected throttled handler not called")
+	}
+
+	if !reflect.DeepEqual(redisMock.keys, []string{"ratelimit:queues:test:1"}) {
+		t.Errorf("Expected rate limit keyed by account but got %v", redisMock.keys)
+	}
+
+	if len(redisMock.delayed) != 1 || redisMock.delayed[0] != "queues:test:delayed" {
+		t.Errorf("Expected job released to the delayed jobs but got %v", redisMock.delayed)
+	}
+
+	expected := []string{events.JobReservedEvent, events.JobReleasedEvent}
+	if !reflect.DeepEqual(*names, expected) || released.Delay != 2*time.Second || released.Reason != "rate_limit" {
+		t.Errorf("Expected released event but got %v %v", *names, released)
+	}
+}
+
+func TestCallDynamicallyRunAllowedJob(t *testing.T) {
+	called := false
+	handler := func(paramTest interface{}, paramTestConn map[string]interface{}) error {
+		called = true
+		return nil
+	}
+
+	job := Manager{Client: &RateLimitRedisMock{allowed: true}}
+	job.Job = providers.JobsConfigs{QueueName: "queues:test", Driver: "redis", Handle: handler, Attempts: float64(1),
+		RateLimit: providers.RateLimitConfigs{Limit: 100, Per: time.Minute}}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = []string{"queues:test", `{"id": "test", "attempts": 0}`}
+
+	err := job.CallDynamically()
+	if err != nil || !called {
+		t.Errorf("Expected allowed handler called but got %v", err)
+	}
+}
+
+func TestCallDynamicallyOpenBreakerAndReleaseJobs(t *testing.T) {
+	bus := events.NewBus()
+
+	var released events.JobReleased
+	bus.Subscribe(events.JobReleasedEvent, func(event events.Event) {
+		released = event.(events.JobReleased)
+	})
+
+	calls := 0
+	handler := func(paramTest interface{}, paramTestConn map[string]interface{}) error {
+		calls++
+		return errors.New("mysql is down")
+	}
+
+	redisMock := &SemaphoreRedisMock{}
+	job := Manager{Events: bus, Client: redisMock, Breakers: breaker.NewBreakers()}
+	job.Job = providers.JobsConfigs{QueueName: "test1", Driver: "redis", Handle: handler, Attempts: float64(3),
+		Breaker: providers.BreakerConfigs{Threshold: 1, Cooldown: time.Minute}}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+
+	job.QueueData = []string{"test1", `{"id": "test", "attempts": 0}`}
+	job.CallDynamically()
+
+	job.QueueData = []string{"test1", `{"id": "test", "attempts": 1}`}
+	err := job.CallDynamically()
+	if err != nil {
+		t.Errorf("Expected no error but got %v", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("Expected handler not called with the breaker open but got %v calls", calls)
+	}
+
+	if len(redisMock.delayed) != 1 || released.Reason != "circuit_open" || released.Delay <= 0 || released.Payload["attempts"] != float64(1) {
+		t.Errorf("Expected job released until the cooldown without consuming an attempt but got %v %v", redisMock.delayed, released)
+	}
+}
+
+func TestCallDynamicallyOpenBreakerNotTakeRateLimit(t *testing.T) {
+	handler := func(paramTest interface{}, paramTestConn map[string]interface{}) error {
+		return errors.New("mysql is down")
+	}
+
+	redisMock := &RateLimitRedisMock{allowed: true}
+	job := Manager{Client: redisMock, Breakers: breaker.NewBreakers()}
+	job.Job = providers.JobsConfigs{QueueName: "test1", Driver: "redis", Handle: handler, Attempts: float64(3),
+		RateLimit: providers.RateLimitConfigs{Limit: 100, Per: time.Minute},
+		Breaker:   providers.BreakerConfigs{Threshold: 1, Cooldown: time.Minute}}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+
+	for attempts := 0; attempts < 2; attempts++ {
+		job.QueueData = []string{"test1", fmt.Sprintf(`{"id": "test", "attempts": %v}`, attempts)}
+		job.CallDynamically()
+	}
+
+	taken := 0
+	for _, key := range redisMock.keys {
+		if key == "ratelimit:test1" {
+			taken++
+		}
+	}
+
+	if taken != 1 {
+		t.Errorf("Expected rate limit taken only by the job run before the breaker opened but got %v", taken)
+	}
+}
+
+func TestCallDynamicallyDispatchNextStepWithResult(t *testing.T) {
+	handler := func(paramTest interface{}, paramTestConn map[string]interface{}) (interface{}, error) {
+		return "/tmp/file", nil
+	}
+
+	redisMock := &PushRedisMock{}
+	job := Manager{Client: redisMock}
+	job.Job = providers.JobsConfigs{QueueName: "queues:download", Driver: "redis", Handle: handler, Attempts: float64(1)}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = []string{"queues:download", `{"id": "test", "attempts": 0, "chain": {"id": "chain", "position": 1, "total": 2,
+		"steps": [{"queue": "queues:download"}, {"queue": "queues:upload", "payload": {"bucket": "files"}}]}}`}
+
+	err := job.CallDynamically()
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	if len(redisMock.pushed["queues:upload"]) != 1 {
+		t.Fatalf("Expected next step pushed but got %v", redisMock.pushed)
+	}
+
+	next := job.unMarshalJobdata(redisMock.pushed["queues:upload"][0])
+	if next["bucket"] != "files" || next["id"] == "test" || chain.Previous(next) != "/tmp/file" {
+		t.Errorf("Expected next step with the result but got %v", next)
+	}
+}
+
+func TestValidateIfWasProcessedDispatchChainCatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	defer db.Close()
+
+	mock.ExpectPrepare("INSERT failed_jobs SET connection=\\?, queue=\\?, payload=\\?, exception=\\?, failed_at=\\?")
+	mock.ExpectExec("INSERT failed_jobs SET connection=\\?, queue=\\?, payload=\\?, exception=\\?, failed_at=\\?").WillReturnResult(sqlmock.NewResult(1, 1))
+
+	redisMock := &PushRedisMock{}
+	job := Manager{Client: redisMock}
+	job.Job = providers.JobsConfigs{QueueName: "queues:download", Driver: "redis", Attempts: float64(0)}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: map[string]interface{}{"mysql": db}}
+	job.QueueData = []string{"queues:download", `{"id": "test", "attempts": 0, "chain": {"id": "chain", "position": 1, "total": 2,
+		"steps": [{"queue": "queues:download"}, {"queue": "queues:upload"}], "catch": {"queue": "queues:cleanup"}}}`}
+
+	err = job.ValidateIfJobWasProcessed(errors.New("not found"), "queues:download")
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	if len(redisMock.pushed["queues:upload"]) != 0 || len(redisMock.pushed["queues:cleanup"]) != 1 {
+		t.Fatalf("Expected chain abandoned and catch job pushed but got %v", redisMock.pushed)
+	}
+
+	state, _ := chain.FromPayload(job.unMarshalJobdata(redisMock.pushed["queues:cleanup"][0]))
+	if state.ID != "chain" || state.Failure == nil || state.Failure.JobID != "test" || state.Failure.Error != "not found" {
+		t.Errorf("Expected catch job with the failure but got %v", state)
+	}
+}
+
+func TestCallDynamicallyRecordBatchAndDispatchCallbacks(t *testing.T) {
+	redisMock := &BatchRedisMock{cancelled: "0", record: []interface{}{int64(0), int64(0), int64(0), "0", `{"queue": "queues:notify"}`, "", ""}}
+	job := Manager{Client: redisMock}
+	job.Job = providers.JobsConfigs{QueueName: "queues:import", Driver: "redis", Handle: HandlerTest, Attempts: float64(1)}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = []string{"queues:import", `{"id": "test", "attempts": 0, "batch": {"id": "import"}}`}
+
+	err := job.CallDynamically()
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	if !reflect.DeepEqual(redisMock.outcomes, []interface{}{"processed"}) || len(redisMock.pushed["queues:notify"]) != 1 {
+		t.Errorf("Expected job counted and then callback pushed when the batch finished but got %v %v", redisMock.outcomes, redisMock.pushed)
+	}
+}
+
+func TestCallDynamicallyContinueChainWhenBatchIsNotRecorded(t *testing.T) {
+	redisMock := &BatchRedisMock{cancelled: "0", err: errors.New("connection refused")}
+	job := Manager{Events: events.NewBus(), Client: redisMock}
+	job.Job = providers.JobsConfigs{QueueName: "queues:download", Driver: "redis", Handle: HandlerTest, Attempts: float64(1)}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = []string{"queues:download", `{"id": "test", "attempts": 0, "batch": {"id": "import"}, "chain": {"id": "chain",
+		"position": 1, "total": 2, "steps": [{"queue": "queues:download"}, {"queue": "queues:upload"}]}}`}
+
+	if err := job.CallDynamically(); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	if len(redisMock.outcomes) != 1 || len(redisMock.pushed["queues:upload"]) != 1 {
+		t.Errorf("Expected next step pushed after the batch error but got %v %v", redisMock.outcomes, redisMock.pushed)
+	}
+}
+
+func TestCallDynamicallyStoreFailedJobWhenBatchIsNotRecorded(t *testing.T) {
+	handler := func(paramTest interface{}, paramTestConn map[string]interface{}) error {
+		return errors.New("api is down")
+	}
+
+	redisMock := &BatchRedisMock{cancelled: "0", err: errors.New("connection refused")}
+	job := Manager{Events: events.NewBus(), Client: redisMock}
+	job.Job = providers.JobsConfigs{QueueName: "queues:download", Driver: "redis", Handle: handler, Attempts: float64(1),
+		DeadLetter: providers.DeadLetterConfigs{Enabled: true}}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = []string{"queues:download", `{"id": "test", "attempts": 1, "batch": {"id": "import"}, "chain": {"id": "chain",
+		"position": 1, "total": 2, "steps": [{"queue": "queues:download"}, {"queue": "queues:upload"}], "catch": {"queue": "queues:cleanup"}}}`}
+
+	if err := job.CallDynamically(); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	if len(redisMock.pushed["queues:cleanup"]) != 1 || len(redisMock.pushed["queues:download:dead"]) != 1 {
+		t.Errorf("Expected catch job pushed and job moved to the failure store after the batch error but got %v", redisMock.pushed)
+	}
+}
+
+func TestCallDynamicallySkipJobOfCancelledBatch(t *testing.T) {
+	called := false
+	handler := func(paramTest interface{}, paramTestConn map[string]interface{}) error {
+		called = true
+		return nil
+	}
+
+	redisMock := &BatchRedisMock{cancelled: "1", record: []interface{}{int64(3), int64(0), int64(0), "1", "", "", ""}}
+	job := Manager{Client: redisMock}
+	job.Job = providers.JobsConfigs{QueueName: "queues:import", Driver: "redis", Handle: handler, Attempts: float64(1)}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = []string{"queues:import", `{"id": "test", "attempts": 0, "batch": {"id": "import"}}`}
+
+	err := job.CallDynamically()
+	if err != nil || called {
+		t.Errorf("Expected job of cancelled batch skipped but got %v %v", called, err)
+	}
+
+	if !reflect.DeepEqual(redisMock.outcomes, []interface{}{"cancelled_jobs"}) {
+		t.Errorf("Expected job counted as cancelled but got %v", redisMock.outcomes)
+	}
+}
+
+func TestCallDynamicallyCancelJobOfBatchKeepOtherJobs(t *testing.T) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	batches := &batch.Client{Dispatcher: &dispatcher.Dispatcher{Client: redisClient}, Redis: redisClient}
+	batchID, err := batches.Dispatch(context.Background(), batch.Batch{
+		Jobs: []chain.Step{chain.Step{QueueName: "queues:import"}, chain.Step{QueueName: "queues:import"}, chain.Step{QueueName: "queues:import"}},
+		Then: &chain.Step{QueueName: "queues:notify"},
+	})
+	if err != nil {
+		t.Fatalf("Expected batch dispatched but got %v", err)
+	}
+
+	jobs, _ := redisClient.LRange("queues:import", 0, -1).Result()
+	redisClient.Del("queues:import")
+	cancelled := make(map[string]interface{})
+	json.Unmarshal([]byte(jobs[0]), &cancelled)
+	if err := cancellation.Cancel(redisClient, cancelled["id"].(string), time.Minute); err != nil {
+		t.Fatalf("Expected job cancelled but got %v", err)
+	}
+
+	called := 0
+	handler := func(paramTest interface{}, paramTestConn map[string]interface{}) error {
+		called++
+		return nil
+	}
+
+	for _, data := range jobs {
+		job := Manager{Events: events.NewBus(), Client: redisClient}
+		job.Job = providers.JobsConfigs{QueueName: "queues:import", Driver: "redis", Handle: handler, Attempts: float64(1)}
+		job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+		job.QueueData = []string{"queues:import", data}
+
+		if err := job.CallDynamically(); err != nil {
+			t.Fatalf("Expected no error but got %v", err)
+		}
+	}
+
+	progress, _ := batches.Progress(batchID)
+	if called != 2 || progress.Cancelled || progress.Processed != 2 || progress.Skipped != 1 || !progress.Finished() {
+		t.Errorf("Expected other jobs of the batch processed but got %v calls %v", called, progress)
+	}
+
+	if notified := redisClient.LLen("queues:notify").Val(); notified != 0 {
+		t.Errorf("Expected no then callback of the batch with a job cancelled but got %v", notified)
+	}
+}
+
+func TestCallDynamicallyStoreResult(t *testing.T) {
+	handler := func(paramTest interface{}, paramTestConn map[string]interface{}) (interface{}, error) {
+		return map[string]interface{}{"total": 10}, nil
+	}
+
+	store := &ResultsStoreMock{}
+	job := Manager{Client: &RedisMock{}, Results: store}
+	job.Job = providers.JobsConfigs{QueueName: "queues:report", Driver: "redis", Handle: handler, Attempts: float64(1),
+		Result: providers.ResultConfigs{Store: true, TTL: time.Hour}}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = []string{"queues:report", `{"id": "test", "attempts": 0}`}
+
+	err := job.CallDynamically()
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	if len(store.saved) != 1 || store.saved[0].JobID != "test" || string(store.saved[0].Value) != `{"total":10}` || store.ttl != time.Hour {
+		t.Errorf("Expected result of the handler stored but got %v", store.saved)
+	}
+}
+
+func TestValidateIfWasProcessedStoreFailedResult(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	defer db.Close()
+
+	mock.ExpectPrepare("INSERT failed_jobs SET connection=\\?, queue=\\?, payload=\\?, exception=\\?, failed_at=\\?")
+	mock.ExpectExec("INSERT failed_jobs SET connection=\\?, queue=\\?, payload=\\?, exception=\\?, failed_at=\\?").WillReturnResult(sqlmock.NewResult(1, 1))
+
+	store := &ResultsStoreMock{}
+	job := Manager{Client: &RedisMock{}, Results: store}
+	job.Job = providers.JobsConfigs{QueueName: "queues:report", Driver: "redis", Attempts: float64(0), Result: providers.ResultConfigs{Store: true}}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: map[string]interface{}{"mysql": db}}
+	job.QueueData = []string{"queues:report", `{"id": "test", "attempts": 0}`}
+
+	job.ValidateIfJobWasProcessed(errors.New("timeout"), "queues:report")
+
+	if len(store.saved) != 1 || store.saved[0].Status != results.StatusFailed || store.saved[0].Error != "timeout" || store.ttl != providers.DefaultResultTTL {
+		t.Errorf("Expected failed result stored but got %v", store.saved)
+	}
+}
+
+func TestCallDynamicallySkipCancelledJob(t *testing.T) {
+	bus := events.NewBus()
+	names := recordEvents(bus)
+
+	called := false
+	handler := func(paramTest interface{}, paramTestConn map[string]interface{}) error {
+		called = true
+		return nil
+	}
+
+	store := &ResultsStoreMock{}
+	job := Manager{Events: bus, Client: &CancelledRedisMock{}, Results: store}
+	job.Job = providers.JobsConfigs{QueueName: "queues:report", Driver: "redis", Handle: handler, Attempts: float64(1),
+		Result: providers.ResultConfigs{Store: true}}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = []string{"queues:report", `{"id": "test", "attempts": 0}`}
+
+	err := job.CallDynamically()
+	if err != nil || called {
+		t.Errorf("Expected cancelled job skipped but got %v %v", called, err)
+	}
+
+	expected := []string{events.JobReservedEvent, events.JobCancelledEvent}
+	if !reflect.DeepEqual(*names, expected) {
+		t.Errorf("Expected cancelled event but got %v", *names)
+	}
+
+	if len(store.saved) != 1 || store.saved[0].Error != cancellation.ErrCancelled.Error() {
+		t.Errorf("Expected cancelled result stored but got %v", store.saved)
+	}
+}
+
+func TestCallDynamicallyLeaseRunningJob(t *testing.T) {
+	handler := func(ctx context.Context, paramTest interface{}, paramTestConn map[string]interface{}) error {
+		return lease.Report(ctx, 50, "halfway")
+	}
+
+	bus := events.NewBus()
+	var progress events.JobProgress
+	bus.Subscribe(events.JobProgressEvent, func(event events.Event) {
+		progress = event.(events.JobProgress)
+	})
+
+	redisMock := &LeaseRedisMock{}
+	job := Manager{Events: bus, Client: redisMock}
+	job.Job = providers.JobsConfigs{QueueName: "queues:import", Driver: "redis", Handle: handler, Attempts: float64(1),
+		Lease: providers.LeaseConfigs{Enabled: true}, Concurrency: providers.ConcurrencyConfigs{Limit: 1}}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = []string{"queues:import", `{"id": "test", "attempts": 0}`}
+	job.Reservation = "test-token"
+
+	err := job.CallDynamically()
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	if !reflect.DeepEqual(redisMock.leases, []string{"extend", "extend slot", "release"}) {
+		t.Errorf("Expected lease and concurrency slot extended by the heartbeat and lease released but got %v", redisMock.leases)
+	}
+
+	if progress.JobID != "test" || progress.Percent != 50 || progress.Message != "halfway" {
+		t.Errorf("Expected progress event but got %v", progress)
+	}
+}
+
+func TestCallDynamicallyReportProgressOfJobNotLeased(t *testing.T) {
+	handler := func(ctx context.Context, paramTest interface{}, paramTestConn map[string]interface{}) error {
+		return lease.Report(ctx, 50, "halfway")
+	}
+
+	bus := events.NewBus()
+	var progress events.JobProgress
+	bus.Subscribe(events.JobProgressEvent, func(event events.Event) {
+		progress = event.(events.JobProgress)
+	})
+
+	redisMock := &LeaseRedisMock{}
+	job := Manager{Events: bus, Client: redisMock}
+	job.Job = providers.JobsConfigs{QueueName: "queues:import", Driver: "redis", Handle: handler, Attempts: float64(1)}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = []string{"queues:import", `{"id": "test", "attempts": 0}`}
+
+	if err := job.CallDynamically(); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	if len(redisMock.leases) != 0 || progress.Percent != 50 {
+		t.Errorf("Expected progress reported without lease but got %v %v", redisMock.leases, progress)
+	}
+}
+
+func TestCallDynamicallyReleaseReservationOfJobReleased(t *testing.T) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	token, err := lease.Reserve(redisClient, "queues:import", `{"id":"test","attempts":0}`, time.Minute)
+	if err != nil {
+		t.Fatalf("Expected job reserved but got %v", err)
+	}
+
+	slot := concurrency.Key("queues:import", providers.FieldsKey(map[string]interface{}{}, nil))
+	concurrency.Semaphore{Redis: redisClient}.Acquire(slot, "other", 1, time.Minute)
+
+	job := Manager{Events: events.NewBus(), Client: redisClient}
+	job.Job = providers.JobsConfigs{QueueName: "queues:import", Driver: "redis", Handle: HandlerTest, Attempts: float64(1),
+		Lease:       providers.LeaseConfigs{Enabled: true},
+		Concurrency: providers.ConcurrencyConfigs{Limit: 1}}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = []string{"queues:import", `{"id":"test","attempts":0}`}
+	job.Reservation = token
+
+	if err := job.CallDynamically(); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	if redisClient.ZCard("queues:import:delayed").Val() != 1 {
+		t.Fatalf("Expected job released without free concurrency slot")
+	}
+
+	if redisClient.ZCard(lease.Key("queues:import")).Val() != 0 || redisClient.HLen(lease.JobsKey("queues:import")).Val() != 0 {
+		t.Errorf("Expected reservation of the job released")
+	}
+}
+
+func TestCallDynamicallyMoveFailedJobToDeadLetterQueue(t *testing.T) {
+	handler := func(paramTest interface{}, paramTestConn map[string]interface{}) error {
+		return errors.New("api is down")
+	}
+
+	redisMock := &PushRedisMock{}
+	job := Manager{Events: events.NewBus(), Client: redisMock}
+	job.Job = providers.JobsConfigs{QueueName: "queues:api", Driver: "redis", Handle: handler, Attempts: float64(2),
+		DeadLetter: providers.DeadLetterConfigs{Enabled: true}}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = []string{"queues:api", `{"id": "test", "attempts": 2}`}
+
+	err := job.CallDynamically()
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	if len(redisMock.pushed["queues:api:dead"]) != 1 {
+		t.Fatalf("Expected job pushed to the dead-letter queue but got %v", redisMock.pushed)
+	}
+
+	var entry deadletter.Entry
+	json.Unmarshal([]byte(redisMock.pushed["queues:api:dead"][0]), &entry)
+	if entry.QueueName != "queues:api" || entry.JobID != "test" || entry.Error != "api is down" || entry.Attempts != 3 ||
+		entry.Payload != `{"id": "test", "attempts": 2}` {
+		t.Errorf("Expected envelope with the failure in the dead-letter queue but got %v", entry)
+	}
+}
+
+func TestCallDynamicallyDecompressJobAndCompressRetry(t *testing.T) {
+	rows := strings.Repeat("row,", 100)
+	var received string
+	handler := func(paramTest interface{}, paramTestConn map[string]interface{}) error {
+		received = paramTest.([]string)[1]
+		return errors.New("database is locked")
+	}
+
+	configs := providers.CompressionConfigs{Codec: providers.CompressionGzip, Threshold: 100}
+	compressed, _ := compression.Compress(`{"id": "test", "attempts": 0, "rows": "`+rows+`"}`, configs)
+
+	redisMock := &PushRedisMock{}
+	job := Manager{Events: events.NewBus(), Client: redisMock}
+	job.Job = providers.JobsConfigs{QueueName: "queues:import", Driver: "redis", Handle: handler, Attempts: float64(2), Compression: configs}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = []string{"queues:import", compressed}
+
+	err := job.CallDynamically()
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	if !strings.Contains(received, rows) {
+		t.Errorf("Expected handler called with the job decompressed but got %v", received)
+	}
+
+	retried := redisMock.pushed["queues:import"]
+	if len(retried) != 1 || strings.Contains(retried[0], rows) {
+		t.Fatalf("Expected job retried compressed but got %v", retried)
+	}
+
+	decompressed, _ := compression.Decompress(retried[0])
+	payload := job.unMarshalJobdata(decompressed)
+	if payload["attempts"] != float64(1) || payload["rows"] != rows {
+		t.Errorf("Expected retry with the attempts increased but got %v", payload)
+	}
+}
+
+func TestCallDynamicallyRejectJobNotDecompressed(t *testing.T) {
+	called := false
+	handler := func(paramTest interface{}, paramTestConn map[string]interface{}) error {
+		called = true
+		return nil
+	}
+
+	redisMock := &PushRedisMock{}
+	job := Manager{Events: events.NewBus(), Client: redisMock}
+	job.Job = providers.JobsConfigs{QueueName: "queues:import", Driver: "redis", Handle: handler, Attempts: float64(2),
+		DeadLetter: providers.DeadLetterConfigs{Enabled: true}}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = []string{"queues:import", `{"id": "test", "attempts": 0, "headers": {"content-encoding": "gzip"}, "compressed": "bm90IGd6aXA="}`}
+
+	err := job.CallDynamically()
+	if err != nil || called {
+		t.Fatalf("Expected job rejected without calling the handler but got %v %v", called, err)
+	}
+
+	dead := redisMock.pushed["queues:import:dead"]
+	if len(dead) != 1 || !strings.Contains(dead[0], "can not be decompressed") {
+		t.Errorf("Expected job moved to the failure store with the error but got %v", dead)
+	}
+}
+
+func TestCallDynamicallyOpenSealedJobAndSealRetry(t *testing.T) {
+	var received string
+	handler := func(paramTest interface{}, paramTestConn map[string]interface{}) error {
+		received = paramTest.([]string)[1]
+		return errors.New("api is down")
+	}
+
+	sealer := &security.Sealer{
+		Encryption: security.Keyring{Keys: map[string][]byte{"key": []byte(strings.Repeat("k", 32))}, Active: "key"},
+		Signing:    security.Keyring{Keys: map[string][]byte{"key": []byte(strings.Repeat("s", 32))}, Active: "key"},
+	}
+	sealed, _ := sealer.Seal(`{"id": "test", "attempts": 0, "document": "123.456.789-00"}`)
+
+	redisMock := &PushRedisMock{}
+	job := Manager{Events: events.NewBus(), Client: redisMock, Sealer: sealer}
+	job.Job = providers.JobsConfigs{QueueName: "queues:customer", Driver: "redis", Handle: handler, Attempts: float64(2)}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = []string{"queues:customer", sealed}
+
+	err := job.CallDynamically()
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	if !strings.Contains(received, "123.456.789-00") {
+		t.Errorf("Expected handler called with the job decrypted but got %v", received)
+	}
+
+	retried := redisMock.pushed["queues:customer"]
+	if len(retried) != 1 || strings.Contains(retried[0], "123.456.789-00") {
+		t.Fatalf("Expected job retried encrypted but got %v", retried)
+	}
+
+	opened, err := sealer.Open(retried[0])
+	if err != nil || job.unMarshalJobdata(opened)["attempts"] != float64(1) {
+		t.Errorf("Expected retry signed with the attempts increased but got %v %v", opened, err)
+	}
+}
+
+func TestCallDynamicallyRejectTamperedJob(t *testing.T) {
+	called := false
+	handler := func(paramTest interface{}, paramTestConn map[string]interface{}) error {
+		called = true
+		return nil
+	}
+
+	sealer := &security.Sealer{Signing: security.Keyring{Keys: map[string][]byte{"key": []byte(strings.Repeat("s", 32))}, Active: "key"}}
+	signed, _ := sealer.Seal(`{"id": "test", "attempts": 0, "amount": 10}`)
+
+	for _, data := range []string{strings.Replace(signed, "10", "10000", 1), `{"id": "test", "attempts": 0, "amount": 10}`} {
+		redisMock := &PushRedisMock{}
+		job := Manager{Events: events.NewBus(), Client: redisMock, Sealer: sealer}
+		job.Job = providers.JobsConfigs{QueueName: "queues:payment", Driver: "redis", Handle: handler, Attempts: float64(2),
+			DeadLetter: providers.DeadLetterConfigs{Enabled: true}}
+		job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+		job.QueueData = []string{"queues:payment", data}
+
+		err := job.CallDynamically()
+		if err != nil || called {
+			t.Fatalf("Expected job rejected without calling the handler but got %v %v", called, err)
+		}
+
+		dead := redisMock.pushed["queues:payment:dead"]
+		if len(dead) != 1 || !strings.Contains(dead[0], "can not be opened") {
+			t.Errorf("Expected job moved to the failure store with the error but got %v", dead)
+		}
+	}
+}
+
+func TestCallDynamicallyFetchClaimCheckAndDeleteAfterSuccess(t *testing.T) {
+	rows := strings.Repeat("row,", 100)
+	var received string
+	handler := func(paramTest interface{}, paramTestConn map[string]interface{}) error {
+		received = paramTest.([]string)[1]
+		return nil
+	}
+
+	store := &ClaimCheckStoreMock{payloads: make(map[string][]byte)}
+	offloader := &claimcheck.Offloader{Store: store, Threshold: 100}
+	claim, _ := offloader.Offload(context.Background(), `{"id": "test", "attempts": 0, "rows": "`+rows+`"}`)
+
+	job := Manager{Events: events.NewBus(), Client: &PushRedisMock{}, ClaimCheck: offloader}
+	job.Job = providers.JobsConfigs{QueueName: "queues:import", Driver: "redis", Handle: handler, Attempts: float64(2)}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = []string{"queues:import", claim}
+
+	err := job.CallDynamically()
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	if !strings.Contains(received, rows) {
+		t.Errorf("Expected handler called with the payload fetched but got %v", received)
+	}
+
+	if len(store.payloads) != 0 {
+		t.Errorf("Expected payload deleted after the job processed but got %v", store.payloads)
+	}
+}
+
+func TestCallDynamicallyOffloadRetryAndDeletePreviousPayload(t *testing.T) {
+	rows := strings.Repeat("row,", 100)
+	handler := func(paramTest interface{}, paramTestConn map[string]interface{}) error {
+		return errors.New("database is locked")
+	}
+
+	store := &ClaimCheckStoreMock{payloads: make(map[string][]byte)}
+	offloader := &claimcheck.Offloader{Store: store, Threshold: 100}
+	claim, _ := offloader.Offload(context.Background(), `{"id": "test", "attempts": 0, "rows": "`+rows+`"}`)
+
+	redisMock := &PushRedisMock{}
+	job := Manager{Events: events.NewBus(), Client: redisMock, ClaimCheck: offloader}
+	job.Job = providers.JobsConfigs{QueueName: "queues:import", Driver: "redis", Handle: handler, Attempts: float64(2)}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = []string{"queues:import", claim}
+
+	err := job.CallDynamically()
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	retried := redisMock.pushed["queues:import"]
+	if len(retried) != 1 || strings.Contains(retried[0], rows) {
+		t.Fatalf("Expected job retried with a claim check but got %v", retried)
+	}
+
+	fetched, key, err := offloader.Fetch(context.Background(), retried[0])
+	payload := job.unMarshalJobdata(fetched)
+	if err != nil || payload["attempts"] != float64(1) || payload["rows"] != rows {
+		t.Errorf("Expected retry offloaded with the attempts increased but got %v %v", payload, err)
+	}
+
+	if _, ok := store.payloads[key]; !ok || len(store.payloads) != 1 {
+		t.Errorf("Expected only the payload of the retry kept but got %v", store.payloads)
+	}
+}
+
+func TestCallDynamicallyRejectJobWithoutPayload(t *testing.T) {
+	called := false
+	handler := func(paramTest interface{}, paramTestConn map[string]interface{}) error {
+		called = true
+		return nil
+	}
+
+	redisMock := &PushRedisMock{}
+	offloader := &claimcheck.Offloader{Store: &ClaimCheckStoreMock{payloads: make(map[string][]byte)}}
+	job := Manager{Events: events.NewBus(), Client: redisMock, ClaimCheck: offloader}
+	job.Job = providers.JobsConfigs{QueueName: "queues:import", Driver: "redis", Handle: handler, Attempts: float64(2),
+		DeadLetter: providers.DeadLetterConfigs{Enabled: true}}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = []string{"queues:import", `{"id": "test", "attempts": 0, "headers": {"claim-check": "test-1"}}`}
+
+	err := job.CallDynamically()
+	if err != nil || called {
+		t.Fatalf("Expected job rejected without calling the handler but got %v %v", called, err)
+	}
+
+	dead := redisMock.pushed["queues:import:dead"]
+	if len(dead) != 1 || !strings.Contains(dead[0], "can not be fetched") {
+		t.Errorf("Expected job moved to the failure store with the error but got %v", dead)
+	}
+}
+
+func TestCallDynamicallyReleaseJobWhenClaimCheckStoreIsDown(t *testing.T) {
+	called := false
+	handler := func(paramTest interface{}, paramTestConn map[string]interface{}) error {
+		called = true
+		return nil
+	}
+
+	bus := events.NewBus()
+	var released events.JobReleased
+	bus.Subscribe(events.JobReleasedEvent, func(event events.Event) {
+		released = event.(events.JobReleased)
+	})
+
+	redisMock := &SemaphoreRedisMock{}
+	store := &ClaimCheckStoreMock{payloads: map[string][]byte{"test-1": []byte(`{"id": "test", "attempts": 0}`)}, err: errors.New("connection refused")}
+	job := Manager{Events: bus, Client: redisMock, ClaimCheck: &claimcheck.Offloader{Store: store}}
+	job.Job = providers.JobsConfigs{QueueName: "queues:import", Driver: "redis", Handle: handler, Attempts: float64(1),
+		DeadLetter: providers.DeadLetterConfigs{Enabled: true}}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = []string{"queues:import", `{"id": "test", "attempts": 0, "headers": {"claim-check": "test-1"}}`}
+
+	if err := job.CallDynamically(); err != nil || called {
+		t.Fatalf("Expected job released without calling the handler but got %v %v", called, err)
+	}
+
+	if len(redisMock.delayed) != 1 || redisMock.delayed[0] != "queues:import:delayed" {
+		t.Errorf("Expected job pushed to the delayed jobs but got %v", redisMock.delayed)
+	}
+
+	if released.Reason != "claim_check" || released.Job.JobID != "test" {
+		t.Errorf("Expected job released for the claim check store but got %v", released)
+	}
+
+	if _, ok := store.payloads["test-1"]; !ok {
+		t.Errorf("Expected payload of the job released kept")
+	}
+}
+
+func TestCallDynamicallyRetryJobBeforeTheJobsDispatched(t *testing.T) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	handler := func(paramTest interface{}, paramTestConn map[string]interface{}) error {
+		return errors.New("api is down")
+	}
+
+	redisClient := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	jobsDispatcher := &dispatcher.Dispatcher{Client: redisClient}
+	for _, id := range []string{"first", "second", "third"} {
+		jobsDispatcher.Dispatch(context.Background(), "queues:import", map[string]interface{}{"id": id})
+	}
+
+	data := redisClient.LPop("queues:import").Val()
+	if !strings.Contains(data, `"first"`) {
+		t.Fatalf("Expected jobs popped in the order they were dispatched but got %v", data)
+	}
+
+	job := Manager{Events: events.NewBus(), Client: redisClient}
+	job.Job = providers.JobsConfigs{QueueName: "queues:import", Driver: "redis", Handle: handler, Attempts: float64(2)}
+	job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+	job.QueueData = []string{"queues:import", data}
+
+	if err := job.CallDynamically(); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	var order []string
+	for _, queued := range redisClient.LRange("queues:import", 0, -1).Val() {
+		order = append(order, job.unMarshalJobdata(queued)["id"].(string))
+	}
+
+	if !reflect.DeepEqual(order, []string{"first", "second", "third"}) {
+		t.Errorf("Expected retry pushed to the head of the queue but got %v", order)
+	}
+}
+
+func TestCallDynamicallyRedriveSealedJobFromDeadLetterQueue(t *testing.T) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	sealer := &security.Sealer{
+		Encryption: security.Keyring{Keys: map[string][]byte{"key": []byte(strings.Repeat("k", 32))}, Active: "key"},
+		Signing:    security.Keyring{Keys: map[string][]byte{"key": []byte(strings.Repeat("s", 32))}, Active: "key"},
+	}
+	jobsDispatcher := &dispatcher.Dispatcher{Client: redisClient, Sealer: sealer}
+	if _, err := jobsDispatcher.Dispatch(context.Background(), "queues:customer", map[string]interface{}{"document": "123.456.789-00"}); err != nil {
+		t.Fatalf("Expected job dispatched but got %v", err)
+	}
+
+	var jobError error
+	var received string
+	handler := func(paramTest interface{}, paramTestConn map[string]interface{}) error {
+		received = paramTest.([]string)[1]
+		return jobError
+	}
+
+	consume := func() {
+		data, err := redisClient.RPop("queues:customer").Result()
+		if err != nil {
+			t.Fatalf("Expected job in the queue but got %v", err)
+		}
+
+		job := Manager{Events: events.NewBus(), Client: redisClient, Sealer: sealer}
+		job.Job = providers.JobsConfigs{QueueName: "queues:customer", Driver: "redis", Handle: handler, Attempts: float64(0),
+			DeadLetter: providers.DeadLetterConfigs{Enabled: true}}
+		job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+		job.QueueData = []string{"queues:customer", data}
+
+		if err := job.CallDynamically(); err != nil {
+			t.Fatalf("Expected no error but got %v", err)
+		}
+	}
+
+	jobError = errors.New("api is down")
+	consume()
+
+	dead, _ := redisClient.LRange("queues:customer:dead", 0, -1).Result()
+	if len(dead) != 1 || strings.Contains(dead[0], "123.456.789-00") {
+		t.Fatalf("Expected job in the dead-letter queue encrypted but got %v", dead)
+	}
+
+	client := &deadletter.Client{Redis: redisClient}
+	if redriven, err := client.Redrive(context.Background(), "queues:customer:dead", deadletter.RedriveOptions{}); err != nil || redriven != 1 {
+		t.Fatalf("Expected job redriven but got %v %v", redriven, err)
+	}
+
+	jobError, received = nil, ""
+	consume()
+
+	if !strings.Contains(received, "123.456.789-00") {
+		t.Errorf("Expected redriven job opened and processed but got %v", received)
+	}
+
+	if depth, _ := client.Depth("queues:customer:dead"); depth != 0 {
+		t.Errorf("Expected dead-letter queue empty after the redrive but got %v", depth)
+	}
+}
+
+func TestCallDynamicallyKeepPayloadOfFailedJobUntilRedriven(t *testing.T) {
+	rows := strings.Repeat("row,", 100)
+	var jobError error
+	var received string
+	handler := func(paramTest interface{}, paramTestConn map[string]interface{}) error {
+		received = paramTest.([]string)[1]
+		return jobError
+	}
+
+	store := &ClaimCheckStoreMock{payloads: make(map[string][]byte)}
+	offloader := &claimcheck.Offloader{Store: store, Threshold: 100}
+	claim, _ := offloader.Offload(context.Background(), `{"id": "test", "attempts": 0, "rows": "`+rows+`"}`)
+
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	consume := func(data string) {
+		job := Manager{Events: events.NewBus(), Client: redisClient, ClaimCheck: offloader}
+		job.Job = providers.JobsConfigs{QueueName: "queues:import", Driver: "redis", Handle: handler, Attempts: float64(0),
+			DeadLetter: providers.DeadLetterConfigs{Enabled: true}}
+		job.ConnManager = &connectionsmanager.Manager{DBClients: make(map[string]interface{})}
+		job.QueueData = []string{"queues:import", data}
+
+		if err := job.CallDynamically(); err != nil {
+			t.Fatalf("Expected no error but got %v", err)
+		}
+	}
+
+	jobError = errors.New("database is locked")
+	consume(claim)
+
+	dead, _ := redisClient.LRange("queues:import:dead", 0, -1).Result()
+	if len(dead) != 1 || strings.Contains(dead[0], "row,") || len(store.payloads) != 1 {
+		t.Fatalf("Expected claim check in the dead-letter queue and the payload kept but got %v %v", dead, store.payloads)
+	}
+
+	client := &deadletter.Client{Redis: redisClient}
+	if redriven, err := client.Redrive(context.Background(), "queues:import:dead", deadletter.RedriveOptions{}); err != nil || redriven != 1 {
+		t.Fatalf("Expected job redriven but got %v %v", redriven, err)
+	}
+
+	jobError = nil
+	consume(redisClient.RPop("queues:import").Val())
+
+	if !strings.Contains(received, rows) || len(store.payloads) != 0 {
+		t.Errorf("Expected redriven job processed with its payload deleted but got %v %v", received, store.payloads)
+	}
+}
@@ -2,58 +2,109 @@ package listenersManager
 
 import (
 	"go-queue/interfaces"
+	"go-queue/logger"
 	connectionsmanager "go-queue/managers/connectionsManager"
 	"go-queue/managers/jobsManager"
 	"go-queue/providers"
-	"log"
+	"sync"
 )
 
 //ListenerInterface is a interface to mock listeners methods
 type ListenerInterface interface {
 	ListenRedis(interfaces.JobsManagerInterface) error
+	ListenRedisQueues(interfaces.JobsManagerInterface, []providers.JobsConfigs, []int) error
 }
 
 //ListenerManager is a struct with camps to manage listeners
 type ListenerManager struct {
 	Providers   []providers.JobsConfigs
+	Workers     []providers.WorkersConfigs
 	Listeners   ListenerInterface
 	ConnManager *connectionsmanager.Manager
 	JobsManager interfaces.JobsManagerInterface
-}
+	Logger      logger.Logger
+	Status      *StatusRegistry
+	Supervisor  SupervisorConfigs
 
-func printOnError(err error, msg string) {
-	if err != nil {
-		log.Printf("%s: %s", msg, err)
-	}
+	stopInit sync.Once
+	stopOnce sync.Once
+	stop     chan struct{}
 }
 
-//RunListeners process listeners
+//RunListeners process listeners, each one supervised, until all of them stop.
+//The queues of the workers are listened only by their workers
 func (l *ListenerManager) RunListeners() {
-	qttJobs := len(l.Providers)
+	var wg sync.WaitGroup
+
+	var workers []providers.WorkersConfigs
+	for _, worker := range l.Workers {
+		jobs, err := providers.WorkerJobs(worker, l.Providers)
+		if err != nil {
+			logger.OrDefault(l.Logger).WithFields(logger.Fields{"worker": worker.Name}).Errorf("Invalid worker configuration: %s", err)
+			continue
+		}
+
+		workers = append(workers, worker)
 
-	for key, job := range l.Providers {
-		if key == qttJobs-1 {
-			err := l.LaunchListener(job)
-			printOnError(err, "Fail on listener execution")
+		wg.Add(1)
+		go func(worker providers.WorkersConfigs, jobs []providers.JobsConfigs) {
+			defer wg.Done()
+			l.SuperviseWorker(worker, jobs)
+		}(worker, jobs)
+	}
+
+	workersQueues := providers.WorkersQueues(workers)
+	for _, job := range l.Providers {
+		if workersQueues[job.QueueName] {
+			continue
 		}
 
+		wg.Add(1)
 		go func(job providers.JobsConfigs) {
-			err := l.LaunchListener(job)
-			printOnError(err, "Fail on listener execution")
+			defer wg.Done()
+			l.Supervise(job)
 		}(job)
 	}
+
+	wg.Wait()
 }
 
 //LaunchListener launch the respective listener for the jobs
 func (l *ListenerManager) LaunchListener(job providers.JobsConfigs) error {
 	var err error
-	l.JobsManager.SetJob(job)
 
-	log.Printf("Launching listener for queue: '%v' in: '%v'\n", job.QueueName, job.Driver)
+	logger.OrDefault(l.Logger).WithFields(logger.Fields{"queue": job.QueueName, "driver": job.Driver}).Infof("Launching listener")
 	if job.Driver == "redis" {
-		l.JobsManager.SetClient(l.ConnManager.DBClients["redis"].(interfaces.RedisInterface))
 		cloneJ := l.cloneJobManager(l.JobsManager)
+		cloneJ.SetJob(job)
+		cloneJ.SetClient(l.ConnManager.DBClients["redis"].(interfaces.RedisInterface))
+		l.Status.Started(job.QueueName, job.Driver)
 		err = l.Listeners.ListenRedis(cloneJ)
+		l.Status.Stopped(job.QueueName, err)
+		cloneJ.WorkerStopping(err)
+	}
+
+	return err
+}
+
+//LaunchWorker launch the listener of the queues of the worker
+func (l *ListenerManager) LaunchWorker(worker providers.WorkersConfigs, jobs []providers.JobsConfigs) error {
+	var err error
+
+	logger.OrDefault(l.Logger).WithFields(logger.Fields{"worker": worker.Name, "driver": worker.Driver}).Infof("Launching worker")
+	if worker.Driver == "redis" {
+		cloneJ := l.cloneJobManager(l.JobsManager)
+		cloneJ.SetJob(jobs[0])
+		cloneJ.SetClient(l.ConnManager.DBClients["redis"].(interfaces.RedisInterface))
+		for _, job := range jobs {
+			l.Status.Started(job.QueueName, job.Driver)
+		}
+
+		err = l.Listeners.ListenRedisQueues(cloneJ, jobs, worker.Weights)
+		for _, job := range jobs {
+			l.Status.Stopped(job.QueueName, err)
+		}
+		cloneJ.WorkerStopping(err)
 	}
 
 	return err
@@ -65,6 +116,13 @@ func (l *ListenerManager) cloneJobManager(jobManager interfaces.JobsManagerInter
 	clonedJobManager.SetJob(jobManager.GetJob())
 	clonedJobManager.SetConnManager(l.ConnManager)
 	clonedJobManager.SetQueueData(jobManager.GetQueueData())
+	clonedJobManager.SetMiddlewares(jobManager.GetMiddlewares())
+	clonedJobManager.SetEvents(jobManager.GetEvents())
+	clonedJobManager.SetLogger(jobManager.GetLogger())
+	clonedJobManager.SetBreakers(jobManager.GetBreakers())
+	clonedJobManager.SetResults(jobManager.GetResults())
+	clonedJobManager.SetSealer(jobManager.GetSealer())
+	clonedJobManager.SetClaimCheck(jobManager.GetClaimCheck())
 
 	return &clonedJobManager
 }
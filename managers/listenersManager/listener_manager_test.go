@@ -6,6 +6,9 @@ import (
 	connectionsmanager "go-queue/managers/connectionsManager"
 	"go-queue/managers/jobsManager"
 	"go-queue/providers"
+	"reflect"
+	"sort"
+	"sync"
 	"testing"
 	"time"
 
@@ -30,6 +33,22 @@ func (r *redisClientMock) LPush(key string, values ...interface{}) *redis.IntCmd
 	return nil
 }
 
+func (r *redisClientMock) RPush(key string, values ...interface{}) *redis.IntCmd {
+	return nil
+}
+
+func (r *redisClientMock) ZAdd(key string, members ...redis.Z) *redis.IntCmd {
+	return redis.NewIntResult(1, nil)
+}
+
+func (r *redisClientMock) Eval(script string, keys []string, args ...interface{}) *redis.Cmd {
+	return redis.NewCmdResult(int64(-1), nil)
+}
+
+func (r *redisClientMock) Exists(keys ...string) *redis.IntCmd {
+	return redis.NewIntResult(0, nil)
+}
+
 /***************** Listener Mock ***************/
 type ListenerDoNotReturnErrorMock struct{}
 
@@ -37,12 +56,71 @@ func (l ListenerDoNotReturnErrorMock) ListenRedis(jobManager interfaces.JobsMana
 	return nil
 }
 
+func (l ListenerDoNotReturnErrorMock) ListenRedisQueues(jobManager interfaces.JobsManagerInterface, jobs []providers.JobsConfigs, weights []int) error {
+	return l.ListenRedis(jobManager)
+}
+
 type ListenerRedisReturnErrorMock struct{}
 
 func (l ListenerRedisReturnErrorMock) ListenRedis(jobManager interfaces.JobsManagerInterface) error {
 	return errors.New("Test")
 }
 
+func (l ListenerRedisReturnErrorMock) ListenRedisQueues(jobManager interfaces.JobsManagerInterface, jobs []providers.JobsConfigs, weights []int) error {
+	return l.ListenRedis(jobManager)
+}
+
+type ListenerFailTimesMock struct {
+	mutex sync.Mutex
+	fails int
+	calls int
+}
+
+func (l *ListenerFailTimesMock) ListenRedis(jobManager interfaces.JobsManagerInterface) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.calls++
+	if l.calls <= l.fails {
+		return errors.New("Test")
+	}
+
+	return nil
+}
+
+func (l *ListenerFailTimesMock) ListenRedisQueues(jobManager interfaces.JobsManagerInterface, jobs []providers.JobsConfigs, weights []int) error {
+	return l.ListenRedis(jobManager)
+}
+
+type ListenerQueuesMock struct {
+	mutex   sync.Mutex
+	queues  []string
+	workers [][]string
+	weights []int
+}
+
+func (l *ListenerQueuesMock) ListenRedis(jobManager interfaces.JobsManagerInterface) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.queues = append(l.queues, jobManager.GetJob().QueueName)
+	return nil
+}
+
+func (l *ListenerQueuesMock) ListenRedisQueues(jobManager interfaces.JobsManagerInterface, jobs []providers.JobsConfigs, weights []int) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	var queues []string
+	for _, job := range jobs {
+		queues = append(queues, job.QueueName)
+	}
+
+	l.workers = append(l.workers, queues)
+	l.weights = weights
+	return nil
+}
+
 //--------------------------- TEST FUNCTIONS ------------------------//
 func TestLaunchListenerDoNotEnterInIf(t *testing.T) {
 	dbConnection := make(map[string]interface{})
@@ -54,7 +132,7 @@ func TestLaunchListenerDoNotEnterInIf(t *testing.T) {
 		JobsManager: &jobsManager.Manager{},
 	}
 
-	jbc := providers.JobsConfigs{"test", "test", "test", 3, []string{"test"}}
+	jbc := providers.JobsConfigs{QueueName: "test", Driver: "test", Handle: "test", Attempts: 3, Connections: []string{"test"}}
 	err := lm.LaunchListener(jbc)
 
 	if err != nil {
@@ -75,7 +153,7 @@ func TestLaunchListenerEnterInRedisIf(t *testing.T) {
 		JobsManager: &jobsManager.Manager{},
 	}
 
-	jbc := providers.JobsConfigs{"test", "redis", "test", 3, []string{"test"}}
+	jbc := providers.JobsConfigs{QueueName: "test", Driver: "redis", Handle: "test", Attempts: 3, Connections: []string{"test"}}
 
 	err := lm.LaunchListener(jbc)
 
@@ -94,9 +172,9 @@ func TestRunListersSuccessfull(t *testing.T) {
 	connManager := connectionsmanager.Manager{DBClients: dbConnection}
 
 	jProviders := []providers.JobsConfigs{
-		providers.JobsConfigs{"test", "redis", "test", 3, []string{"test"}},
-		providers.JobsConfigs{"test", "redis", "test", 3, []string{"test"}},
-		providers.JobsConfigs{"test", "redis", "test", 3, []string{"test"}},
+		providers.JobsConfigs{QueueName: "test", Driver: "redis", Handle: "test", Attempts: 3, Connections: []string{"test"}},
+		providers.JobsConfigs{QueueName: "test", Driver: "redis", Handle: "test", Attempts: 3, Connections: []string{"test"}},
+		providers.JobsConfigs{QueueName: "test", Driver: "redis", Handle: "test", Attempts: 3, Connections: []string{"test"}},
 	}
 
 	lm := ListenerManager{
@@ -110,7 +188,7 @@ func TestRunListersSuccessfull(t *testing.T) {
 }
 
 func TestRunListersReturnError(t *testing.T) {
-	lMock := ListenerRedisReturnErrorMock{}
+	lMock := &ListenerFailTimesMock{fails: 2}
 
 	dbConnection := make(map[string]interface{})
 	redisMock := redisClientMock{}
@@ -119,7 +197,7 @@ func TestRunListersReturnError(t *testing.T) {
 	connManager := connectionsmanager.Manager{DBClients: dbConnection}
 
 	jProviders := []providers.JobsConfigs{
-		providers.JobsConfigs{"test", "redis", "test", 3, []string{"test"}},
+		providers.JobsConfigs{QueueName: "test", Driver: "redis", Handle: "test", Attempts: 3, Connections: []string{"test"}},
 	}
 
 	lm := ListenerManager{
@@ -127,7 +205,77 @@ func TestRunListersReturnError(t *testing.T) {
 		Listeners:   lMock,
 		ConnManager: &connManager,
 		JobsManager: &jobsManager.Manager{},
+		Status:      NewStatusRegistry(),
+		Supervisor:  SupervisorConfigs{InitialBackoff: time.Millisecond},
 	}
 
 	lm.RunListeners()
+
+	if lMock.calls != 3 {
+		t.Errorf("Expected listener restarted until it stops without error but got %v calls", lMock.calls)
+	}
+
+	if restarts := lm.Status.Statuses()[0].Restarts; restarts != 2 {
+		t.Errorf("Expected 2 restarts but got %v", restarts)
+	}
+}
+
+func TestLaunchListenerUpdateStatus(t *testing.T) {
+	dbConnection := make(map[string]interface{})
+	dbConnection["redis"] = &redisClientMock{}
+
+	connManager := connectionsmanager.Manager{DBClients: dbConnection}
+
+	lm := ListenerManager{
+		Listeners:   ListenerRedisReturnErrorMock{},
+		ConnManager: &connManager,
+		JobsManager: &jobsManager.Manager{},
+		Status:      NewStatusRegistry(),
+	}
+
+	lm.LaunchListener(providers.JobsConfigs{QueueName: "test", Driver: "redis"})
+
+	statuses := lm.Status.Statuses()
+	if len(statuses) != 1 || statuses[0].Running || statuses[0].LastError != "Test" {
+		t.Errorf("Expected listener stopped with error but got %v", statuses)
+	}
+}
+
+func TestRunListenersWithWorkers(t *testing.T) {
+	lMock := &ListenerQueuesMock{}
+
+	dbConnection := make(map[string]interface{})
+	dbConnection["redis"] = &redisClientMock{}
+
+	lm := ListenerManager{
+		Providers: []providers.JobsConfigs{
+			providers.JobsConfigs{QueueName: "queues:low", Driver: "redis"},
+			providers.JobsConfigs{QueueName: "queues:high", Driver: "redis"},
+			providers.JobsConfigs{QueueName: "queues:other", Driver: "redis"},
+			providers.JobsConfigs{QueueName: "queues:invalid", Driver: "redis"},
+		},
+		Workers: []providers.WorkersConfigs{
+			providers.WorkersConfigs{Name: "priority", Driver: "redis", Queues: []string{"queues:high", "queues:low"}, Weights: []int{3, 1}},
+			providers.WorkersConfigs{Name: "invalid", Driver: "redis", Queues: []string{"queues:invalid", "queues:unknown"}},
+		},
+		Listeners:   lMock,
+		ConnManager: &connectionsmanager.Manager{DBClients: dbConnection},
+		JobsManager: &jobsManager.Manager{},
+		Status:      NewStatusRegistry(),
+	}
+
+	lm.RunListeners()
+
+	if len(lMock.workers) != 1 || !reflect.DeepEqual(lMock.workers[0], []string{"queues:high", "queues:low"}) {
+		t.Errorf("Expected worker listening queues in priority order but got %v", lMock.workers)
+	}
+
+	if !reflect.DeepEqual(lMock.weights, []int{3, 1}) {
+		t.Errorf("Expected weights of the worker but got %v", lMock.weights)
+	}
+
+	sort.Strings(lMock.queues)
+	if !reflect.DeepEqual(lMock.queues, []string{"queues:invalid", "queues:other"}) {
+		t.Errorf("Expected only queues out of valid workers listened alone but got %v", lMock.queues)
+	}
 }
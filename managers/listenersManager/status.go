@@ -0,0 +1,167 @@
+package listenersManager
+
+import (
+	"go-queue/events"
+	"sort"
+	"sync"
+	"time"
+)
+
+//ListenerStatus is the state of the listener of a queue
+type ListenerStatus struct {
+	QueueName string      `json:"queue"`
+	Driver    string      `json:"driver"`
+	Running   bool        `json:"running"`
+	LastFetch time.Time   `json:"last_fetch"`
+	LastError string      `json:"last_error,omitempty"`
+	Restarts  int         `json:"restarts"`
+	Degraded  bool        `json:"degraded"`
+	Jobs      []JobStatus `json:"jobs,omitempty"`
+}
+
+//JobStatus is the state of a job running in the listener with the last progress reported by its handler
+type JobStatus struct {
+	JobID     string    `json:"job_id"`
+	JobType   string    `json:"job_type,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	Percent   float64   `json:"percent"`
+	Message   string    `json:"message,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+//StatusRegistry keeps the state of the listeners
+type StatusRegistry struct {
+	mutex    sync.RWMutex
+	statuses map[string]*ListenerStatus
+}
+
+//NewStatusRegistry return an empty status registry
+func NewStatusRegistry() *StatusRegistry {
+	return &StatusRegistry{statuses: make(map[string]*ListenerStatus)}
+}
+
+//Started marks the listener of the queue as running
+func (registry *StatusRegistry) Started(queueName string, driver string) {
+	registry.update(queueName, func(status *ListenerStatus) {
+		status.Driver = driver
+		status.Running = true
+	})
+}
+
+//Stopped marks the listener of the queue as stopped with the error that stopped it
+func (registry *StatusRegistry) Stopped(queueName string, err error) {
+	registry.update(queueName, func(status *ListenerStatus) {
+		status.Running = false
+		if err != nil {
+			status.LastError = err.Error()
+		}
+	})
+}
+
+//Restarting counts a restart of the listener of the queue and marks if it is degraded
+func (registry *StatusRegistry) Restarting(queueName string, degraded bool) {
+	registry.update(queueName, func(status *ListenerStatus) {
+		status.Restarts++
+		status.Degraded = status.Degraded || degraded
+	})
+}
+
+//Recovered marks that the listener of the queue is running stable again
+func (registry *StatusRegistry) Recovered(queueName string) {
+	registry.update(queueName, func(status *ListenerStatus) {
+		status.Degraded = false
+	})
+}
+
+//HandleEvent updates the last fetch, the last error and the running jobs of the queues with the jobs events
+func (registry *StatusRegistry) HandleEvent(event events.Event) {
+	switch e := event.(type) {
+	case events.JobReserved:
+		registry.update(e.QueueName, func(status *ListenerStatus) {
+			status.LastFetch = time.Now()
+		})
+	case events.JobProcessing:
+		registry.update(e.QueueName, func(status *ListenerStatus) {
+			status.Jobs = append(status.Jobs, JobStatus{JobID: e.JobID, JobType: e.JobType, StartedAt: time.Now()})
+		})
+	case events.JobProgress:
+		registry.update(e.QueueName, func(status *ListenerStatus) {
+			if i := runningJob(status.Jobs, e.JobID); i >= 0 {
+				status.Jobs[i].Percent, status.Jobs[i].Message, status.Jobs[i].UpdatedAt = e.Percent, e.Message, time.Now()
+			}
+		})
+	case events.JobProcessed:
+		registry.update(e.QueueName, func(status *ListenerStatus) {
+			status.Jobs = finishJob(status.Jobs, e.JobID)
+		})
+	case events.JobExceptionOccurred:
+		registry.update(e.QueueName, func(status *ListenerStatus) {
+			status.LastError = e.Err.Error()
+			status.Jobs = finishJob(status.Jobs, e.JobID)
+		})
+	}
+}
+
+//Subscribe updates the registry with the events of the bus
+func (registry *StatusRegistry) Subscribe(bus *events.Bus) {
+	bus.Subscribe(events.AllEvents, registry.HandleEvent)
+}
+
+//Statuses return the state of all listeners ordered by queue name
+func (registry *StatusRegistry) Statuses() []ListenerStatus {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	statuses := []ListenerStatus{}
+	for _, status := range registry.statuses {
+		copied := *status
+		copied.Jobs = append([]JobStatus(nil), status.Jobs...)
+		statuses = append(statuses, copied)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].QueueName < statuses[j].QueueName
+	})
+
+	return statuses
+}
+
+func (registry *StatusRegistry) update(queueName string, change func(*ListenerStatus)) {
+	if registry == nil {
+		return
+	}
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	if registry.statuses == nil {
+		registry.statuses = make(map[string]*ListenerStatus)
+	}
+
+	status, ok := registry.statuses[queueName]
+	if !ok {
+		status = &ListenerStatus{QueueName: queueName}
+		registry.statuses[queueName] = status
+	}
+
+	change(status)
+}
+
+func runningJob(jobs []JobStatus, jobID string) int {
+	for i, job := range jobs {
+		if job.JobID == jobID {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func finishJob(jobs []JobStatus, jobID string) []JobStatus {
+	i := runningJob(jobs, jobID)
+	if i < 0 {
+		return jobs
+	}
+
+	return append(jobs[:i], jobs[i+1:]...)
+}
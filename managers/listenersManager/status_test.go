@@ -0,0 +1,70 @@
+package listenersManager
+
+import (
+	"errors"
+	"go-queue/events"
+	"testing"
+)
+
+func TestStatusRegistryTrackListeners(t *testing.T) {
+	registry := NewStatusRegistry()
+
+	registry.Started("queues:b", "redis")
+	registry.Started("queues:a", "redis")
+	registry.Stopped("queues:a", errors.New("BLPop"))
+
+	statuses := registry.Statuses()
+
+	if len(statuses) != 2 || statuses[0].QueueName != "queues:a" || statuses[1].QueueName != "queues:b" {
+		t.Fatalf("Expected statuses ordered by queue but got %v", statuses)
+	}
+
+	if statuses[0].Running || statuses[0].LastError != "BLPop" {
+		t.Errorf("Expected queues:a stopped with error but got %v", statuses[0])
+	}
+
+	if !statuses[1].Running || statuses[1].Driver != "redis" {
+		t.Errorf("Expected queues:b running but got %v", statuses[1])
+	}
+}
+
+func TestStatusRegistryHandleEvents(t *testing.T) {
+	registry := NewStatusRegistry()
+	bus := events.NewBus()
+	registry.Subscribe(bus)
+
+	bus.Emit(events.JobReserved{Job: events.Job{QueueName: "queues:test"}})
+	bus.Emit(events.JobExceptionOccurred{Job: events.Job{QueueName: "queues:test"}, Err: errors.New("Test")})
+
+	status := registry.Statuses()[0]
+	if status.LastFetch.IsZero() || status.LastError != "Test" {
+		t.Errorf("Expected last fetch and last error updated but got %v", status)
+	}
+}
+
+func TestStatusRegistryTrackRunningJobsProgress(t *testing.T) {
+	registry := NewStatusRegistry()
+	bus := events.NewBus()
+	registry.Subscribe(bus)
+
+	job := events.Job{QueueName: "queues:test", JobID: "job"}
+	bus.Emit(events.JobProcessing{Job: job})
+	bus.Emit(events.JobProgress{Job: job, Percent: 40, Message: "rows imported"})
+
+	jobs := registry.Statuses()[0].Jobs
+	if len(jobs) != 1 || jobs[0].JobID != "job" || jobs[0].Percent != 40 || jobs[0].Message != "rows imported" {
+		t.Fatalf("Expected running job with its progress but got %v", jobs)
+	}
+
+	bus.Emit(events.JobProcessed{Job: job})
+	if jobs := registry.Statuses()[0].Jobs; len(jobs) != 0 {
+		t.Errorf("Expected finished job removed but got %v", jobs)
+	}
+}
+
+func TestNilStatusRegistryIgnoreUpdates(t *testing.T) {
+	var registry *StatusRegistry
+
+	registry.Started("queues:test", "redis")
+	registry.Stopped("queues:test", nil)
+}
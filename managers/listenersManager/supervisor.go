@@ -0,0 +1,191 @@
+package listenersManager
+
+import (
+	"go-queue/logger"
+	"go-queue/providers"
+	"strings"
+	"time"
+)
+
+//SupervisorConfigs configurations of the restarts of the crashed listeners
+type SupervisorConfigs struct {
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	MaxRestarts    int
+	RestartWindow  time.Duration
+	DegradedAfter  int
+	StableAfter    time.Duration
+}
+
+//DefaultSupervisorConfigs are used for the configs not setted
+var DefaultSupervisorConfigs = SupervisorConfigs{
+	InitialBackoff: time.Second,
+	MaxBackoff:     time.Minute,
+	MaxRestarts:    10,
+	RestartWindow:  10 * time.Minute,
+	DegradedAfter:  5,
+	StableAfter:    time.Minute,
+}
+
+func (configs SupervisorConfigs) withDefaults() SupervisorConfigs {
+	if configs.InitialBackoff <= 0 {
+		configs.InitialBackoff = DefaultSupervisorConfigs.InitialBackoff
+	}
+
+	if configs.MaxBackoff <= 0 {
+		configs.MaxBackoff = DefaultSupervisorConfigs.MaxBackoff
+	}
+
+	if configs.MaxRestarts <= 0 {
+		configs.MaxRestarts = DefaultSupervisorConfigs.MaxRestarts
+	}
+
+	if configs.RestartWindow <= 0 {
+		configs.RestartWindow = DefaultSupervisorConfigs.RestartWindow
+	}
+
+	if configs.DegradedAfter <= 0 {
+		configs.DegradedAfter = DefaultSupervisorConfigs.DegradedAfter
+	}
+
+	if configs.StableAfter <= 0 {
+		configs.StableAfter = DefaultSupervisorConfigs.StableAfter
+	}
+
+	return configs
+}
+
+//Supervise runs the listener of the job and restarts it with backoff when it fails,
+//until it stops without error or the manager is stopped
+func (l *ListenerManager) Supervise(job providers.JobsConfigs) {
+	l.supervise([]string{job.QueueName}, job.Driver, func() error {
+		return l.LaunchListener(job)
+	})
+}
+
+//SuperviseWorker runs the listener of the worker and restarts it like Supervise
+func (l *ListenerManager) SuperviseWorker(worker providers.WorkersConfigs, jobs []providers.JobsConfigs) {
+	var queues []string
+	for _, job := range jobs {
+		queues = append(queues, job.QueueName)
+	}
+
+	l.supervise(queues, worker.Driver, func() error {
+		return l.LaunchWorker(worker, jobs)
+	})
+}
+
+func (l *ListenerManager) supervise(queues []string, driver string, launch func() error) {
+	configs := l.Supervisor.withDefaults()
+	log := logger.OrDefault(l.Logger).WithFields(logger.Fields{"queue": strings.Join(queues, ","), "driver": driver})
+
+	backoff := configs.InitialBackoff
+	crashes := 0
+	var restarts []time.Time
+
+	for {
+		startTime := time.Now()
+		stable := time.AfterFunc(configs.StableAfter, func() {
+			for _, queueName := range queues {
+				l.Status.Recovered(queueName)
+			}
+		})
+
+		err := launch()
+		stable.Stop()
+
+		if err == nil || l.stopped() {
+			return
+		}
+
+		log.Errorf("Fail on listener execution: %s", err)
+
+		if time.Since(startTime) >= configs.StableAfter {
+			crashes = 0
+			backoff = configs.InitialBackoff
+		}
+
+		crashes++
+		degraded := crashes >= configs.DegradedAfter
+
+		wait := backoff
+		backoff *= 2
+		if backoff > configs.MaxBackoff {
+			backoff = configs.MaxBackoff
+		}
+
+		restarts = restartsInWindow(restarts, configs.RestartWindow)
+		if len(restarts) >= configs.MaxRestarts {
+			untilWindow := restarts[0].Add(configs.RestartWindow).Sub(time.Now())
+			if untilWindow > wait {
+				wait = untilWindow
+			}
+		}
+
+		if degraded {
+			log.Errorf("Listener crashed %v times in a row, queue is degraded", crashes)
+		}
+
+		log.Warnf("Restarting listener in %v", wait)
+		if !l.wait(wait) {
+			return
+		}
+
+		restarts = append(restarts, time.Now())
+		for _, queueName := range queues {
+			l.Status.Restarting(queueName, degraded)
+		}
+	}
+}
+
+//Stop stops the supervisors, the listeners observing Done finish the job running and return
+func (l *ListenerManager) Stop() {
+	l.stopOnce.Do(func() {
+		close(l.stopChannel())
+	})
+}
+
+//Done return a channel closed when the manager is stopped, so the listeners can observe the shutdown
+func (l *ListenerManager) Done() <-chan struct{} {
+	return l.stopChannel()
+}
+
+func (l *ListenerManager) stopped() bool {
+	select {
+	case <-l.stopChannel():
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *ListenerManager) wait(duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-l.stopChannel():
+		return false
+	}
+}
+
+func (l *ListenerManager) stopChannel() chan struct{} {
+	l.stopInit.Do(func() {
+		l.stop = make(chan struct{})
+	})
+
+	return l.stop
+}
+
+func restartsInWindow(restarts []time.Time, window time.Duration) []time.Time {
+	var inWindow []time.Time
+	for _, restartedAt := range restarts {
+		if time.Since(restartedAt) < window {
+			inWindow = append(inWindow, restartedAt)
+		}
+	}
+
+	return inWindow
+}
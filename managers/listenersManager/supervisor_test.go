@@ -0,0 +1,105 @@
+package listenersManager
+
+import (
+	"go-queue/interfaces"
+	connectionsmanager "go-queue/managers/connectionsManager"
+	"go-queue/managers/jobsManager"
+	"go-queue/providers"
+	"testing"
+	"time"
+)
+
+func newSupervisedManager(listener ListenerInterface, configs SupervisorConfigs) *ListenerManager {
+	dbConnection := make(map[string]interface{})
+	dbConnection["redis"] = &redisClientMock{}
+
+	return &ListenerManager{
+		Listeners:   listener,
+		ConnManager: &connectionsmanager.Manager{DBClients: dbConnection},
+		JobsManager: &jobsManager.Manager{},
+		Status:      NewStatusRegistry(),
+		Supervisor:  configs,
+	}
+}
+
+func TestSuperviseMarkQueueDegraded(t *testing.T) {
+	lMock := &ListenerFailTimesMock{fails: 3}
+	lm := newSupervisedManager(lMock, SupervisorConfigs{
+		InitialBackoff: time.Millisecond,
+		DegradedAfter:  2,
+	})
+
+	lm.Supervise(providers.JobsConfigs{QueueName: "test", Driver: "redis"})
+
+	status := lm.Status.Statuses()[0]
+	if status.Restarts != 3 || !status.Degraded {
+		t.Errorf("Expected 3 restarts and queue degraded but got %v", status)
+	}
+}
+
+func TestSuperviseCapRestartRate(t *testing.T) {
+	lMock := &ListenerFailTimesMock{fails: 3}
+	lm := newSupervisedManager(lMock, SupervisorConfigs{
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+		MaxRestarts:    2,
+		RestartWindow:  50 * time.Millisecond,
+	})
+
+	startTime := time.Now()
+	lm.Supervise(providers.JobsConfigs{QueueName: "test", Driver: "redis"})
+
+	if elapsed := time.Since(startTime); elapsed < 40*time.Millisecond {
+		t.Errorf("Expected third restart to wait the restart window but took %v", elapsed)
+	}
+}
+
+func TestSuperviseStopDuringBackoff(t *testing.T) {
+	lm := newSupervisedManager(ListenerRedisReturnErrorMock{}, SupervisorConfigs{InitialBackoff: time.Hour})
+
+	done := make(chan struct{})
+	go func() {
+		lm.Supervise(providers.JobsConfigs{QueueName: "test", Driver: "redis"})
+		close(done)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	lm.Stop()
+	lm.Stop()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected supervisor to return after Stop")
+	}
+}
+
+type ListenerSlowMock struct{}
+
+func (l ListenerSlowMock) ListenRedis(jobManager interfaces.JobsManagerInterface) error {
+	time.Sleep(30 * time.Millisecond)
+	return nil
+}
+
+func (l ListenerSlowMock) ListenRedisQueues(jobManager interfaces.JobsManagerInterface, jobs []providers.JobsConfigs, weights []int) error {
+	return l.ListenRedis(jobManager)
+}
+
+func TestSuperviseRecoverStableListener(t *testing.T) {
+	lm := newSupervisedManager(ListenerSlowMock{}, SupervisorConfigs{StableAfter: 5 * time.Millisecond})
+	lm.Status.Restarting("test", true)
+
+	lm.Supervise(providers.JobsConfigs{QueueName: "test", Driver: "redis"})
+
+	if lm.Status.Statuses()[0].Degraded {
+		t.Errorf("Expected queue not degraded after running stable")
+	}
+}
+
+func TestSupervisorConfigsWithDefaults(t *testing.T) {
+	configs := SupervisorConfigs{MaxRestarts: 3}.withDefaults()
+
+	if configs.MaxRestarts != 3 || configs.InitialBackoff != DefaultSupervisorConfigs.InitialBackoff {
+		t.Errorf("Expected defaults only for the configs not setted but got %v", configs)
+	}
+}
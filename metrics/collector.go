@@ -0,0 +1,310 @@
+package metrics
+
+import (
+	"go-queue/breaker"
+	"go-queue/events"
+	"go-queue/logger"
+	"go-queue/managers/listenersManager"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "goqueue"
+
+//RedisInterface is the redis client used to read the queues sizes
+type RedisInterface interface {
+	LLen(string) *redis.IntCmd
+	ZCard(string) *redis.IntCmd
+}
+
+//ConnectionsChecker checks the health of the database clients
+type ConnectionsChecker interface {
+	CheckConnection(name string) error
+}
+
+//ListenersStatus return the state of the listeners
+type ListenersStatus interface {
+	Statuses() []listenersManager.ListenerStatus
+}
+
+//BreakersStatus return the state of the circuit breakers
+type BreakersStatus interface {
+	Statuses() []breaker.Status
+}
+
+//Collector keeps the workers metrics, updated by the jobs events and read on each scrape
+type Collector struct {
+	Queues      []string
+	Connections []string
+	Redis       RedisInterface
+	ConnManager ConnectionsChecker
+	Listeners   ListenersStatus
+	Breakers    BreakersStatus
+	Logger      logger.Logger
+	//DeadLetterQueues are the dead-letter queues of the queues that have them
+	DeadLetterQueues map[string]string
+
+	processed *prometheus.CounterVec
+	failed    *prometheus.CounterVec
+	retried   *prometheus.CounterVec
+	exception *prometheus.CounterVec
+	unrouted  *prometheus.CounterVec
+	released  *prometheus.CounterVec
+	cancelled *prometheus.CounterVec
+	duration  *prometheus.HistogramVec
+	inFlight  *prometheus.GaugeVec
+
+	queueSize      *prometheus.Desc
+	delayedSize    *prometheus.Desc
+	reservedSize   *prometheus.Desc
+	deadLetterSize *prometheus.Desc
+	connectionUp   *prometheus.Desc
+	sinceLastFetch *prometheus.Desc
+	restarts       *prometheus.Desc
+	degraded       *prometheus.Desc
+	breakerState   *prometheus.Desc
+
+	mutex     sync.Mutex
+	lastFetch map[string]time.Time
+}
+
+//NewCollector return a collector of the queues metrics
+func NewCollector(queues []string, connections []string, redisClient RedisInterface, connManager ConnectionsChecker) *Collector {
+	return &Collector{
+		Queues:      queues,
+		Connections: connections,
+		Redis:       redisClient,
+		ConnManager: connManager,
+		processed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "jobs_processed_total",
+			Help:      "Jobs processed successfully.",
+		}, []string{"queue", "job_type"}),
+		failed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "jobs_failed_total",
+			Help:      "Jobs that ran out of attempts.",
+		}, []string{"queue", "job_type"}),
+		retried: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "jobs_retried_total",
+			Help:      "Jobs requeued after an error.",
+		}, []string{"queue", "job_type"}),
+		exception: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "jobs_exceptions_total",
+			Help:      "Jobs handlers that returned an error.",
+		}, []string{"queue", "job_type"}),
+		unrouted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "jobs_unrouted_total",
+			Help:      "Jobs without handler for their type, by the fallback applied.",
+		}, []string{"queue", "job_type", "fallback"}),
+		released: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "jobs_released_total",
+			Help:      "Jobs released back to the queue with a delay, by the reason.",
+		}, []string{"queue", "job_type", "reason"}),
+		cancelled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "jobs_cancelled_total",
+			Help:      "Jobs cancelled, skipped or stopped while running.",
+		}, []string{"queue", "job_type"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "job_duration_seconds",
+			Help:      "Duration of the jobs handlers.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"queue", "job_type"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "jobs_in_flight",
+			Help:      "Jobs being processed.",
+		}, []string{"queue"}),
+		queueSize: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "queue_size"),
+			"Jobs waiting in the queue.", []string{"queue"}, nil),
+		delayedSize: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "queue_delayed_size"),
+			"Delayed jobs of the queue.", []string{"queue"}, nil),
+		reservedSize: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "queue_reserved_size"),
+			"Reserved jobs of the queue.", []string{"queue"}, nil),
+		deadLetterSize: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "queue_dead_letter_size"),
+			"Jobs of the queue in its dead-letter queue.", []string{"queue"}, nil),
+		connectionUp: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "connection_up"),
+			"Whether the database connection answers to ping.", []string{"connection"}, nil),
+		sinceLastFetch: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "listener_seconds_since_last_fetch"),
+			"Seconds since the listener of the queue took a job.", []string{"queue"}, nil),
+		restarts: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "listener_restarts_total"),
+			"Restarts of the crashed listener of the queue.", []string{"queue"}, nil),
+		degraded: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "listener_degraded"),
+			"Whether the listener of the queue crashed repeatedly.", []string{"queue"}, nil),
+		breakerState: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "circuit_breaker_state"),
+			"State of the circuit breaker, 1 for the current state.", []string{"breaker", "state"}, nil),
+		lastFetch: make(map[string]time.Time),
+	}
+}
+
+//Subscribe update the metrics with the events of the bus
+func (c *Collector) Subscribe(bus *events.Bus) {
+	bus.Subscribe(events.AllEvents, c.HandleEvent)
+}
+
+//HandleEvent update the metrics with the event
+func (c *Collector) HandleEvent(event events.Event) {
+	switch e := event.(type) {
+	case events.JobReserved:
+		c.mutex.Lock()
+		c.lastFetch[e.QueueName] = time.Now()
+		c.mutex.Unlock()
+	case events.JobProcessing:
+		c.inFlight.WithLabelValues(e.QueueName).Inc()
+	case events.JobProcessed:
+		c.inFlight.WithLabelValues(e.QueueName).Dec()
+		c.processed.WithLabelValues(e.QueueName, e.JobType).Inc()
+		c.duration.WithLabelValues(e.QueueName, e.JobType).Observe(e.Duration.Seconds())
+	case events.JobExceptionOccurred:
+		c.inFlight.WithLabelValues(e.QueueName).Dec()
+		c.exception.WithLabelValues(e.QueueName, e.JobType).Inc()
+		c.duration.WithLabelValues(e.QueueName, e.JobType).Observe(e.Duration.Seconds())
+	case events.JobRetrying:
+		c.retried.WithLabelValues(e.QueueName, e.JobType).Inc()
+	case events.JobFailed:
+		c.failed.WithLabelValues(e.QueueName, e.JobType).Inc()
+	case events.JobUnrouted:
+		c.unrouted.WithLabelValues(e.QueueName, e.JobType, e.Fallback).Inc()
+	case events.JobReleased:
+		c.released.WithLabelValues(e.QueueName, e.JobType, e.Reason).Inc()
+	case events.JobCancelled:
+		c.cancelled.WithLabelValues(e.QueueName, e.JobType).Inc()
+	}
+}
+
+//Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.processed.Describe(ch)
+	c.failed.Describe(ch)
+	c.retried.Describe(ch)
+	c.exception.Describe(ch)
+	c.unrouted.Describe(ch)
+	c.released.Describe(ch)
+	c.cancelled.Describe(ch)
+	c.duration.Describe(ch)
+	c.inFlight.Describe(ch)
+	ch <- c.queueSize
+	ch <- c.delayedSize
+	ch <- c.reservedSize
+	ch <- c.deadLetterSize
+	ch <- c.connectionUp
+	ch <- c.sinceLastFetch
+	ch <- c.restarts
+	ch <- c.degraded
+	ch <- c.breakerState
+}
+
+//Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.processed.Collect(ch)
+	c.failed.Collect(ch)
+	c.retried.Collect(ch)
+	c.exception.Collect(ch)
+	c.unrouted.Collect(ch)
+	c.released.Collect(ch)
+	c.cancelled.Collect(ch)
+	c.duration.Collect(ch)
+	c.inFlight.Collect(ch)
+
+	c.collectQueues(ch)
+	c.collectConnections(ch)
+	c.collectListeners(ch)
+	c.collectBreakers(ch)
+
+	c.mutex.Lock()
+	for queue, fetchedAt := range c.lastFetch {
+		ch <- prometheus.MustNewConstMetric(c.sinceLastFetch, prometheus.GaugeValue, time.Since(fetchedAt).Seconds(), queue)
+	}
+	c.mutex.Unlock()
+}
+
+func (c *Collector) collectQueues(ch chan<- prometheus.Metric) {
+	if c.Redis == nil {
+		return
+	}
+
+	for _, queue := range c.Queues {
+		c.collectSize(ch, c.queueSize, c.Redis.LLen(queue), queue)
+		c.collectSize(ch, c.delayedSize, c.Redis.ZCard(queue+":delayed"), queue)
+		c.collectSize(ch, c.reservedSize, c.Redis.ZCard(queue+":reserved"), queue)
+		if deadLetterQueue, ok := c.DeadLetterQueues[queue]; ok {
+			c.collectSize(ch, c.deadLetterSize, c.Redis.LLen(deadLetterQueue), queue)
+		}
+	}
+}
+
+func (c *Collector) collectSize(ch chan<- prometheus.Metric, desc *prometheus.Desc, cmd *redis.IntCmd, queue string) {
+	size, err := cmd.Result()
+	if err != nil {
+		logger.OrDefault(c.Logger).WithFields(logger.Fields{"queue": queue}).Errorf("Error to get size of the queue in redis: %v", err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(size), queue)
+}
+
+func (c *Collector) collectConnections(ch chan<- prometheus.Metric) {
+	if c.ConnManager == nil {
+		return
+	}
+
+	for _, name := range c.Connections {
+		up := float64(1)
+		if err := c.ConnManager.CheckConnection(name); err != nil {
+			up = 0
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.connectionUp, prometheus.GaugeValue, up, name)
+	}
+}
+
+func (c *Collector) collectListeners(ch chan<- prometheus.Metric) {
+	if c.Listeners == nil {
+		return
+	}
+
+	for _, status := range c.Listeners.Statuses() {
+		degraded := float64(0)
+		if status.Degraded {
+			degraded = 1
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.restarts, prometheus.CounterValue, float64(status.Restarts), status.QueueName)
+		ch <- prometheus.MustNewConstMetric(c.degraded, prometheus.GaugeValue, degraded, status.QueueName)
+	}
+}
+
+func (c *Collector) collectBreakers(ch chan<- prometheus.Metric) {
+	if c.Breakers == nil {
+		return
+	}
+
+	for _, status := range c.Breakers.Statuses() {
+		for _, state := range []string{breaker.StateClosed, breaker.StateOpen, breaker.StateHalfOpen} {
+			value := float64(0)
+			if status.State == state {
+				value = 1
+			}
+
+			ch <- prometheus.MustNewConstMetric(c.breakerState, prometheus.GaugeValue, value, status.Name, state)
+		}
+	}
+}
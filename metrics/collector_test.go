@@ -0,0 +1,255 @@
+package metrics
+
+import (
+	"errors"
+	"go-queue/breaker"
+	"go-queue/events"
+	"go-queue/managers/listenersManager"
+	"go-queue/providers"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+//------------------------- MOCK FUNCTIONS ---------------------
+type redisClientMock struct{}
+
+func (r *redisClientMock) LLen(queueName string) *redis.IntCmd {
+	if queueName == "error" {
+		return redis.NewIntResult(0, errors.New("LLen"))
+	}
+
+	if queueName == "queues:test:dead" {
+		return redis.NewIntResult(3, nil)
+	}
+
+	return redis.NewIntResult(5, nil)
+}
+
+func (r *redisClientMock) ZCard(key string) *redis.IntCmd {
+	if key == "queues:test:delayed" {
+		return redis.NewIntResult(2, nil)
+	}
+
+	return redis.NewIntResult(1, nil)
+}
+
+type connectionsCheckerMock struct{}
+
+func (c connectionsCheckerMock) CheckConnection(name string) error {
+	if name == "mysql" {
+		return errors.New("Test")
+	}
+
+	return nil
+}
+
+func gatherMetrics(t *testing.T, collector *Collector) map[string][]*dto.Metric {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Error to gather metrics %v", err)
+	}
+
+	gathered := make(map[string][]*dto.Metric)
+	for _, family := range families {
+		gathered[family.GetName()] = family.GetMetric()
+	}
+
+	return gathered
+}
+
+func findMetric(metrics []*dto.Metric, label string) *dto.Metric {
+	for _, metric := range metrics {
+		for _, pair := range metric.GetLabel() {
+			if pair.GetValue() == label {
+				return metric
+			}
+		}
+	}
+
+	return nil
+}
+
+//------------------------------ TESTS ---------------------------------
+func TestHandleEventUpdateJobsMetrics(t *testing.T) {
+	collector := NewCollector(nil, nil, nil, nil)
+	bus := events.NewBus()
+	collector.Subscribe(bus)
+
+	job := events.Job{QueueName: "queues:test"}
+	bus.Emit(events.JobReserved{Job: job})
+	bus.Emit(events.JobProcessing{Job: job})
+	bus.Emit(events.JobProcessed{Job: job, Duration: time.Second})
+	bus.Emit(events.JobProcessing{Job: job})
+	bus.Emit(events.JobExceptionOccurred{Job: job, Err: errors.New("Test")})
+	bus.Emit(events.JobRetrying{Job: job})
+	bus.Emit(events.JobFailed{Job: job})
+	bus.Emit(events.JobProcessing{Job: job})
+
+	gathered := gatherMetrics(t, collector)
+
+	counters := map[string]float64{
+		"goqueue_jobs_processed_total":  1,
+		"goqueue_jobs_exceptions_total": 1,
+		"goqueue_jobs_retried_total":    1,
+		"goqueue_jobs_failed_total":     1,
+	}
+
+	for name, expected := range counters {
+		metric := findMetric(gathered[name], "queues:test")
+		if metric == nil || metric.GetCounter().GetValue() != expected {
+			t.Errorf("Expected %v equal %v but got %v", name, expected, metric)
+		}
+	}
+
+	inFlight := findMetric(gathered["goqueue_jobs_in_flight"], "queues:test")
+	if inFlight == nil || inFlight.GetGauge().GetValue() != 1 {
+		t.Errorf("Expected 1 job in flight but got %v", inFlight)
+	}
+
+	duration := findMetric(gathered["goqueue_job_duration_seconds"], "queues:test")
+	if duration == nil || duration.GetHistogram().GetSampleCount() != 2 {
+		t.Errorf("Expected 2 durations observed but got %v", duration)
+	}
+
+	if findMetric(gathered["goqueue_listener_seconds_since_last_fetch"], "queues:test") == nil {
+		t.Errorf("Expected time since last fetch of the queue")
+	}
+}
+
+func TestCollectQueuesAndConnections(t *testing.T) {
+	collector := NewCollector(
+		[]string{"queues:test", "error"},
+		[]string{"redis", "mysql"},
+		&redisClientMock{},
+		connectionsCheckerMock{},
+	)
+	collector.DeadLetterQueues = map[string]string{"queues:test": "queues:test:dead"}
+
+	gathered := gatherMetrics(t, collector)
+
+	size := findMetric(gathered["goqueue_queue_size"], "queues:test")
+	if size == nil || size.GetGauge().GetValue() != 5 {
+		t.Errorf("Expected queue size equal 5 but got %v", size)
+	}
+
+	if findMetric(gathered["goqueue_queue_size"], "error") != nil {
+		t.Errorf("Expected queue size to be skipped when LLen fails")
+	}
+
+	delayed := findMetric(gathered["goqueue_queue_delayed_size"], "queues:test")
+	if delayed == nil || delayed.GetGauge().GetValue() != 2 {
+		t.Errorf("Expected delayed size equal 2 but got %v", delayed)
+	}
+
+	reserved := findMetric(gathered["goqueue_queue_reserved_size"], "queues:test")
+	if reserved == nil || reserved.GetGauge().GetValue() != 1 {
+		t.Errorf("Expected reserved size equal 1 but got %v", reserved)
+	}
+
+	deadLetter := findMetric(gathered["goqueue_queue_dead_letter_size"], "queues:test")
+	if deadLetter == nil || deadLetter.GetGauge().GetValue() != 3 {
+		t.Errorf("Expected dead-letter size equal 3 but got %v", deadLetter)
+	}
+
+	if findMetric(gathered["goqueue_queue_dead_letter_size"], "error") != nil {
+		t.Errorf("Expected dead-letter size only for the queues with dead-letter queue")
+	}
+
+	redisUp := findMetric(gathered["goqueue_connection_up"], "redis")
+	if redisUp == nil || redisUp.GetGauge().GetValue() != 1 {
+		t.Errorf("Expected redis connection up but got %v", redisUp)
+	}
+
+	mysqlUp := findMetric(gathered["goqueue_connection_up"], "mysql")
+	if mysqlUp == nil || mysqlUp.GetGauge().GetValue() != 0 {
+		t.Errorf("Expected mysql connection down but got %v", mysqlUp)
+	}
+}
+
+func TestCollectListenersRestarts(t *testing.T) {
+	status := listenersManager.NewStatusRegistry()
+	status.Started("queues:test", "redis")
+	status.Restarting("queues:test", false)
+	status.Restarting("queues:test", true)
+
+	collector := NewCollector(nil, nil, nil, nil)
+	collector.Listeners = status
+
+	gathered := gatherMetrics(t, collector)
+
+	restarts := findMetric(gathered["goqueue_listener_restarts_total"], "queues:test")
+	if restarts == nil || restarts.GetCounter().GetValue() != 2 {
+		t.Errorf("Expected 2 restarts but got %v", restarts)
+	}
+
+	degraded := findMetric(gathered["goqueue_listener_degraded"], "queues:test")
+	if degraded == nil || degraded.GetGauge().GetValue() != 1 {
+		t.Errorf("Expected listener degraded but got %v", degraded)
+	}
+}
+
+func TestCollectBreakersState(t *testing.T) {
+	job := providers.JobsConfigs{QueueName: "queues:test", Connections: []string{"mysql"}, Breaker: providers.BreakerConfigs{Threshold: 1, Cooldown: time.Hour}}
+	breakers := breaker.NewBreakers()
+	breakers.Done(job, "", errors.New("mysql is down"))
+
+	collector := NewCollector(nil, nil, nil, nil)
+	collector.Breakers = breakers
+
+	gathered := gatherMetrics(t, collector)
+
+	states := make(map[string]float64)
+	for _, metric := range gathered["goqueue_circuit_breaker_state"] {
+		labels := make(map[string]string)
+		for _, pair := range metric.GetLabel() {
+			labels[pair.GetName()] = pair.GetValue()
+		}
+
+		states[labels["breaker"]+" "+labels["state"]] = metric.GetGauge().GetValue()
+	}
+
+	if states["connection:mysql open"] != 1 || states["connection:mysql closed"] != 0 || states["job:queues:test open"] != 1 || len(states) != 6 {
+		t.Errorf("Expected breakers of the connection and the job open but got %v", states)
+	}
+}
+
+func TestHandleEventCountByJobType(t *testing.T) {
+	collector := NewCollector(nil, nil, nil, nil)
+
+	collector.HandleEvent(events.JobProcessed{Job: events.Job{QueueName: "queues:default", JobType: "SendEmail"}})
+	collector.HandleEvent(events.JobProcessed{Job: events.Job{QueueName: "queues:default", JobType: "SendSms"}})
+	collector.HandleEvent(events.JobUnrouted{Job: events.Job{QueueName: "queues:default", JobType: "Unknown"}, Fallback: "discard"})
+	collector.HandleEvent(events.JobReleased{Job: events.Job{QueueName: "queues:default", JobType: "SendSms"}, Reason: "concurrency"})
+	collector.HandleEvent(events.JobCancelled{Job: events.Job{QueueName: "queues:default", JobType: "SendReport"}})
+
+	gathered := gatherMetrics(t, collector)
+
+	for _, jobType := range []string{"SendEmail", "SendSms"} {
+		processed := findMetric(gathered["goqueue_jobs_processed_total"], jobType)
+		if processed == nil || processed.GetCounter().GetValue() != 1 {
+			t.Errorf("Expected 1 job %v processed but got %v", jobType, processed)
+		}
+	}
+
+	released := findMetric(gathered["goqueue_jobs_released_total"], "concurrency")
+	if released == nil || released.GetCounter().GetValue() != 1 {
+		t.Errorf("Expected 1 released job but got %v", released)
+	}
+
+	cancelled := findMetric(gathered["goqueue_jobs_cancelled_total"], "SendReport")
+	if cancelled == nil || cancelled.GetCounter().GetValue() != 1 {
+		t.Errorf("Expected 1 cancelled job but got %v", cancelled)
+	}
+
+	unrouted := findMetric(gathered["goqueue_jobs_unrouted_total"], "Unknown")
+	if unrouted == nil || unrouted.GetCounter().GetValue() != 1 {
+		t.Errorf("Expected 1 unrouted job but got %v", unrouted)
+	}
+}
@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+//Handler return the http handler that expose the collector metrics
+func Handler(collector prometheus.Collector) (http.Handler, error) {
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(collector); err != nil {
+		return nil, err
+	}
+
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{}), nil
+}
+
+//Serve expose the collector metrics in the /metrics path of the address
+func Serve(addr string, collector prometheus.Collector) error {
+	handler, err := Handler(collector)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", handler)
+
+	return http.ListenAndServe(addr, mux)
+}
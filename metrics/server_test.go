@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandlerExposeMetrics(t *testing.T) {
+	collector := NewCollector([]string{"queues:test"}, nil, &redisClientMock{}, nil)
+
+	handler, err := Handler(collector)
+	if err != nil {
+		t.Fatalf("Error to create handler %v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	body, _ := ioutil.ReadAll(recorder.Body)
+	if !strings.Contains(string(body), `goqueue_queue_size{queue="queues:test"} 5`) {
+		t.Errorf("Expected queue size in the response but got %s", body)
+	}
+}
+
+func TestServeReturnErrorOnInvalidAddress(t *testing.T) {
+	err := Serve("invalid", NewCollector(nil, nil, nil, nil))
+
+	if err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+}
@@ -0,0 +1,31 @@
+package providers
+
+//Codecs of the compressed payloads
+const (
+	CompressionGzip   = "gzip"
+	CompressionZstd   = "zstd"
+	CompressionSnappy = "snappy"
+)
+
+//DefaultCompressionThreshold is the size in bytes from which the payloads are compressed when no threshold is configured
+const DefaultCompressionThreshold = 32 * 1024
+
+//CompressionConfigs compresses the payloads pushed to the queue from the threshold size with the codec
+type CompressionConfigs struct {
+	Codec     string
+	Threshold int
+}
+
+//Enabled return if the payloads are compressed
+func (configs CompressionConfigs) Enabled() bool {
+	return configs.Codec != ""
+}
+
+//ThresholdBytes return the size in bytes from which the payloads are compressed
+func (configs CompressionConfigs) ThresholdBytes() int {
+	if configs.Threshold <= 0 {
+		return DefaultCompressionThreshold
+	}
+
+	return configs.Threshold
+}
@@ -0,0 +1,17 @@
+package providers
+
+//DeadLetterConfigs moves the jobs that ran out of attempts to a dead-letter queue, a redis list
+//with the original envelope and the failure, instead of the failed_jobs table
+type DeadLetterConfigs struct {
+	Enabled bool
+	Queue   string
+}
+
+//QueueName return the dead-letter queue of the jobs of the queue, the queue with the ":dead" suffix when not configured
+func (configs DeadLetterConfigs) QueueName(queueName string) string {
+	if configs.Queue == "" {
+		return queueName + ":dead"
+	}
+
+	return configs.Queue
+}
@@ -9,11 +9,29 @@ type JobsConfigs struct {
 	Handle      interface{}
 	Attempts    float64
 	Connections []string
+	Middlewares []Middleware
+	Routes      RoutesConfigs
+	Concurrency ConcurrencyConfigs
+	RateLimit   RateLimitConfigs
+	Breaker     BreakerConfigs
+	Result      ResultConfigs
+	Lease       LeaseConfigs
+	DeadLetter  DeadLetterConfigs
+	Compression CompressionConfigs
 }
 
 var providers = []JobsConfigs{
 	//Add your job configuration here
-	JobsConfigs{"queues:sample", "redis", sampleJob.Handle, 3, []string{"mongo"}},
+	JobsConfigs{QueueName: "queues:sample", Driver: "redis", Handle: sampleJob.Handle, Attempts: 3, Connections: []string{"mongo"}},
+	//Jobs of many types in one queue are routed by a field of the payload, e.g.
+	//JobsConfigs{QueueName: "queues:default", Driver: "redis", Attempts: 3, Routes: RoutesConfigs{
+	//	Field:    "displayName",
+	//	Handlers: map[string]interface{}{"App\\Jobs\\SendEmail": sendEmail.Handle},
+	//	Fallback: FallbackDiscard,
+	//}},
+	//Jobs calling an external API are rate limited across all workers, e.g.
+	//JobsConfigs{QueueName: "queues:api", Driver: "redis", Handle: callApi.Handle, Attempts: 3,
+	//	RateLimit: RateLimitConfigs{Limit: 100, Per: time.Minute, Fields: []string{"account_id"}}},
 }
 
 //GetAllJobs Return all jobs in funcMap
@@ -0,0 +1,28 @@
+package providers
+
+import "time"
+
+//DefaultLeaseTimeout is the time a running job is reserved without heartbeats when no timeout is configured
+const DefaultLeaseTimeout = 5 * time.Minute
+
+//LeaseConfigs is the reservation of the running jobs, disabled by default. The jobs are reserved
+//when they are popped and the worker extends the lease while the handler runs, a job whose lease
+//expired is considered abandoned and pushed back to the queue
+type LeaseConfigs struct {
+	Enabled bool
+	Timeout time.Duration
+}
+
+//TimeoutDuration return the time a running job is reserved without heartbeats
+func (configs LeaseConfigs) TimeoutDuration() time.Duration {
+	if configs.Timeout <= 0 {
+		return DefaultLeaseTimeout
+	}
+
+	return configs.Timeout
+}
+
+//HeartbeatInterval return the time between the automatic extensions of the lease
+func (configs LeaseConfigs) HeartbeatInterval() time.Duration {
+	return configs.TimeoutDuration() / 3
+}
@@ -0,0 +1,88 @@
+package providers
+
+import "time"
+
+//Defaults of the limits configs not setted
+const (
+	DefaultLease        = 5 * time.Minute
+	DefaultReleaseDelay = 5 * time.Second
+	DefaultCooldown     = 30 * time.Second
+)
+
+//ConcurrencyConfigs limits the concurrent executions of the jobs with the same key, derived
+//from the payload fields, across all workers. A job without free slot is released back to
+//the queue after the ReleaseDelay without consuming an attempt. The slot lease expires
+//after Lease, so the slots of crashed workers are freed
+type ConcurrencyConfigs struct {
+	Fields       []string
+	Limit        int
+	Lease        time.Duration
+	ReleaseDelay time.Duration
+}
+
+//Limited return if the concurrency of the jobs is limited
+func (configs ConcurrencyConfigs) Limited() bool {
+	return configs.Limit > 0
+}
+
+//LeaseDuration return the lease of the slots
+func (configs ConcurrencyConfigs) LeaseDuration() time.Duration {
+	if configs.Lease <= 0 {
+		return DefaultLease
+	}
+
+	return configs.Lease
+}
+
+//Delay return the delay of the jobs released back to the queue
+func (configs ConcurrencyConfigs) Delay() time.Duration {
+	if configs.ReleaseDelay <= 0 {
+		return DefaultReleaseDelay
+	}
+
+	return configs.ReleaseDelay
+}
+
+//Algorithms of the rate limits
+const (
+	RateLimitTokenBucket   = "token_bucket"
+	RateLimitSlidingWindow = "sliding_window"
+)
+
+//RateLimitConfigs limits the jobs executed to Limit each Per duration, e.g. 100 per minute, across
+//all workers, by queue or by the key derived from the payload fields. The token bucket, the default
+//algorithm, allows bursts up to the limit. A throttled job is released back to the queue until
+//it is allowed without consuming an attempt
+type RateLimitConfigs struct {
+	Algorithm string
+	Limit     int
+	Per       time.Duration
+	Fields    []string
+}
+
+//Limited return if the jobs are rate limited
+func (configs RateLimitConfigs) Limited() bool {
+	return configs.Limit > 0 && configs.Per > 0
+}
+
+//BreakerConfigs opens the circuit breakers of the connections and of the job type of the jobs
+//after Threshold failures in a row. While open the queue is not consumed, after the Cooldown
+//one job probes the circuit, closing it on success or opening it again on failure
+type BreakerConfigs struct {
+	Threshold int
+	Cooldown  time.Duration
+}
+
+//Enabled return if the jobs are guarded by circuit breakers
+func (configs BreakerConfigs) Enabled() bool {
+	return configs.Threshold > 0
+}
+
+//CooldownDuration return the time the circuit stays open before a probe
+func (configs BreakerConfigs) CooldownDuration() time.Duration {
+	if configs.Cooldown <= 0 {
+		return DefaultCooldown
+	}
+
+	return configs.Cooldown
+}
@@ -0,0 +1,40 @@
+package providers
+
+import "context"
+
+//JobContext is the data of the job in execution shared with the middlewares, with the result returned by the handler
+type JobContext struct {
+	Context     context.Context
+	QueueName   string
+	JobType     string
+	QueueData   interface{}
+	Payload     map[string]interface{}
+	Connections map[string]interface{}
+	Attempt     float64
+	Result      interface{}
+}
+
+//HandlerFunc is a job invocation that can be wrapped by middlewares
+type HandlerFunc func(job *JobContext) error
+
+//Middleware wraps the next handler invocation, the error returned by next is the result of the job.
+//A middleware that does not call next short-circuits the job
+type Middleware func(next HandlerFunc) HandlerFunc
+
+var middlewares = []Middleware{
+	//Add your global middlewares here
+}
+
+//GetAllMiddlewares Return the middlewares applied to all jobs
+func GetAllMiddlewares() []Middleware {
+	return middlewares
+}
+
+//Chain wraps the handler with the middlewares, the first middleware is the outermost
+func Chain(handler HandlerFunc, middlewares ...Middleware) HandlerFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	return handler
+}
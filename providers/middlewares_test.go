@@ -0,0 +1,85 @@
+package providers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestGetAllMiddlewares(t *testing.T) {
+	middlewares = []Middleware{}
+
+	returned := GetAllMiddlewares()
+
+	if len(returned) != 0 {
+		t.Errorf("Expected an empty list of middlewares but got %v", len(returned))
+	}
+}
+
+func TestChainRunMiddlewaresInOrder(t *testing.T) {
+	var calls []string
+
+	recorder := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(job *JobContext) error {
+				calls = append(calls, name+":before")
+				err := next(job)
+				calls = append(calls, name+":after")
+				return err
+			}
+		}
+	}
+
+	handler := func(job *JobContext) error {
+		calls = append(calls, "handler")
+		return nil
+	}
+
+	err := Chain(handler, recorder("first"), recorder("second"))(&JobContext{})
+
+	if err != nil {
+		t.Errorf("Expected error is nil but got %v", err)
+	}
+
+	expected := []string{"first:before", "second:before", "handler", "second:after", "first:after"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("Expected calls %v but got %v", expected, calls)
+	}
+}
+
+func TestChainShortCircuit(t *testing.T) {
+	handlerCalled := false
+
+	handler := func(job *JobContext) error {
+		handlerCalled = true
+		return nil
+	}
+
+	stop := func(next HandlerFunc) HandlerFunc {
+		return func(job *JobContext) error {
+			return errors.New("Test")
+		}
+	}
+
+	err := Chain(handler, stop)(&JobContext{})
+
+	if err == nil || err.Error() != "Test" {
+		t.Errorf("Expected error 'Test' but got %v", err)
+	}
+
+	if handlerCalled {
+		t.Errorf("Expected handler not to be called")
+	}
+}
+
+func TestChainWithoutMiddlewares(t *testing.T) {
+	handler := func(job *JobContext) error {
+		return errors.New("Test")
+	}
+
+	err := Chain(handler)(&JobContext{})
+
+	if err == nil || err.Error() != "Test" {
+		t.Errorf("Expected error 'Test' but got %v", err)
+	}
+}
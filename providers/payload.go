@@ -0,0 +1,36 @@
+package providers
+
+import (
+	"fmt"
+	"strings"
+)
+
+//FieldValue return the value of the payload field, nested fields are separated by dots, e.g. "data.user"
+func FieldValue(payload map[string]interface{}, field string) interface{} {
+	var value interface{} = payload
+	for _, key := range strings.Split(field, ".") {
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+
+		value = fields[key]
+	}
+
+	return value
+}
+
+//FieldsKey return the values of the payload fields joined by ":"
+func FieldsKey(payload map[string]interface{}, fields []string) string {
+	var values []string
+	for _, field := range fields {
+		value := FieldValue(payload, field)
+		if value == nil {
+			value = ""
+		}
+
+		values = append(values, fmt.Sprint(value))
+	}
+
+	return strings.Join(values, ":")
+}
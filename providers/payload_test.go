@@ -0,0 +1,23 @@
+package providers
+
+import "testing"
+
+func TestFieldValue(t *testing.T) {
+	payload := map[string]interface{}{"job": "reindex", "data": map[string]interface{}{"user": float64(42)}}
+
+	if value := FieldValue(payload, "data.user"); value != float64(42) {
+		t.Errorf("Expected nested field value but got %v", value)
+	}
+
+	if value := FieldValue(payload, "job.name"); value != nil {
+		t.Errorf("Expected nil for field of a value but got %v", value)
+	}
+}
+
+func TestFieldsKey(t *testing.T) {
+	payload := map[string]interface{}{"job": "reindex", "data": map[string]interface{}{"user": float64(42)}}
+
+	if key := FieldsKey(payload, []string{"job", "data.user", "missing"}); key != "reindex:42:" {
+		t.Errorf("Expected values joined but got %v", key)
+	}
+}
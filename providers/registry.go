@@ -0,0 +1,397 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+//JobOption configures a job registered in the registry
+type JobOption func(*JobsConfigs)
+
+//WithDriver sets the driver of the queue, redis by default
+func WithDriver(driver string) JobOption {
+	return func(job *JobsConfigs) {
+		job.Driver = driver
+	}
+}
+
+//WithAttempts sets the times the job is tried before failing, 1 by default
+func WithAttempts(attempts float64) JobOption {
+	return func(job *JobsConfigs) {
+		job.Attempts = attempts
+	}
+}
+
+//WithConnections sets the connections passed to the handler
+func WithConnections(connections ...string) JobOption {
+	return func(job *JobsConfigs) {
+		job.Connections = append(job.Connections, connections...)
+	}
+}
+
+//WithMiddlewares sets the middlewares of the job, run after the global ones
+func WithMiddlewares(middlewares ...Middleware) JobOption {
+	return func(job *JobsConfigs) {
+		job.Middlewares = append(job.Middlewares, middlewares...)
+	}
+}
+
+//WithRoutes routes the jobs of the queue to the handlers by type, the handler registered is not used
+func WithRoutes(routes RoutesConfigs) JobOption {
+	return func(job *JobsConfigs) {
+		job.Routes = routes
+	}
+}
+
+//WithConcurrency limits the concurrent executions of the jobs with the same key
+func WithConcurrency(concurrency ConcurrencyConfigs) JobOption {
+	return func(job *JobsConfigs) {
+		job.Concurrency = concurrency
+	}
+}
+
+//WithRateLimit limits the executions of the jobs with the same key by period
+func WithRateLimit(rateLimit RateLimitConfigs) JobOption {
+	return func(job *JobsConfigs) {
+		job.RateLimit = rateLimit
+	}
+}
+
+//WithBreaker guards the jobs by circuit breakers of their connections and job type
+func WithBreaker(breaker BreakerConfigs) JobOption {
+	return func(job *JobsConfigs) {
+		job.Breaker = breaker
+	}
+}
+
+//WithResult stores the results of the jobs
+func WithResult(result ResultConfigs) JobOption {
+	return func(job *JobsConfigs) {
+		job.Result = result
+	}
+}
+
+//WithLease reserves the running jobs, the jobs of workers that died are pushed back to the queue
+func WithLease(lease LeaseConfigs) JobOption {
+	return func(job *JobsConfigs) {
+		job.Lease = lease
+	}
+}
+
+//WithDeadLetter moves the jobs that ran out of attempts to a dead-letter queue
+func WithDeadLetter(deadLetter DeadLetterConfigs) JobOption {
+	return func(job *JobsConfigs) {
+		job.DeadLetter = deadLetter
+	}
+}
+
+//WithCompression compresses the large payloads the workers push back to the queue
+func WithCompression(compression CompressionConfigs) JobOption {
+	return func(job *JobsConfigs) {
+		job.Compression = compression
+	}
+}
+
+//Registry keeps the jobs, workers, schedules, middlewares and subscribers of the application
+type Registry struct {
+	mutex       sync.RWMutex
+	jobs        []JobsConfigs
+	workers     []WorkersConfigs
+	schedules   []ScheduleConfigs
+	middlewares []Middleware
+	subscribers []Subscriber
+}
+
+//NewRegistry return an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+//DefaultRegistry return a registry with the jobs, workers, schedules, middlewares and subscribers of the providers
+func DefaultRegistry() (*Registry, error) {
+	registry := NewRegistry()
+
+	for _, job := range GetAllJobs() {
+		if err := registry.RegisterJob(job); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, worker := range GetAllWorkers() {
+		if err := registry.RegisterWorker(worker); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, schedule := range GetAllSchedules() {
+		if err := registry.RegisterSchedule(schedule); err != nil {
+			return nil, err
+		}
+	}
+
+	registry.Use(GetAllMiddlewares()...)
+	registry.Subscribe(GetAllSubscribers()...)
+
+	return registry, nil
+}
+
+//Register registers the handler of the queue
+func (registry *Registry) Register(name string, handler interface{}, options ...JobOption) error {
+	job := JobsConfigs{QueueName: name, Driver: "redis", Handle: handler, Attempts: 1}
+	for _, option := range options {
+		option(&job)
+	}
+
+	return registry.RegisterJob(job)
+}
+
+//RegisterJob registers the job configuration
+func (registry *Registry) RegisterJob(job JobsConfigs) error {
+	if job.QueueName == "" {
+		return fmt.Errorf("job without queue name")
+	}
+
+	if err := validateHandlers(job); err != nil {
+		return err
+	}
+
+	if err := validateRateLimit(job); err != nil {
+		return err
+	}
+
+	if err := validateDeadLetter(job); err != nil {
+		return err
+	}
+
+	if err := ValidateCompression(job.Compression); err != nil {
+		return fmt.Errorf("%v of queue %v", err, job.QueueName)
+	}
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	if _, ok := findJob(job.QueueName, registry.jobs); ok {
+		return fmt.Errorf("queue %v already registered", job.QueueName)
+	}
+
+	registry.jobs = append(registry.jobs, job)
+	return nil
+}
+
+//RegisterWorker registers a worker listening many queues, the jobs of the queues are validated by Validate
+func (registry *Registry) RegisterWorker(worker WorkersConfigs) error {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	for _, registered := range registry.workers {
+		if registered.Name == worker.Name {
+			return fmt.Errorf("worker %v already registered", worker.Name)
+		}
+	}
+
+	queues := WorkersQueues(registry.workers)
+	for _, queueName := range worker.Queues {
+		if queues[queueName] {
+			return fmt.Errorf("queue %v of worker %v already listened by another worker", queueName, worker.Name)
+		}
+	}
+
+	registry.workers = append(registry.workers, worker)
+	return nil
+}
+
+//RegisterSchedule registers a recurring job, the spec is validated by the scheduler
+func (registry *Registry) RegisterSchedule(schedule ScheduleConfigs) error {
+	if schedule.Name == "" || schedule.QueueName == "" {
+		return fmt.Errorf("schedule without name or queue name")
+	}
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	for _, registered := range registry.schedules {
+		if registered.Name == schedule.Name {
+			return fmt.Errorf("schedule %v already registered", schedule.Name)
+		}
+	}
+
+	registry.schedules = append(registry.schedules, schedule)
+	return nil
+}
+
+//Use registers middlewares applied to all jobs
+func (registry *Registry) Use(middlewares ...Middleware) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	registry.middlewares = append(registry.middlewares, middlewares...)
+}
+
+//Subscribe registers subscribers of the jobs events
+func (registry *Registry) Subscribe(subscribers ...Subscriber) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	registry.subscribers = append(registry.subscribers, subscribers...)
+}
+
+//Jobs return the jobs registered
+func (registry *Registry) Jobs() []JobsConfigs {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	return append([]JobsConfigs{}, registry.jobs...)
+}
+
+//Workers return the workers registered
+func (registry *Registry) Workers() []WorkersConfigs {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	return append([]WorkersConfigs{}, registry.workers...)
+}
+
+//Schedules return the recurring jobs registered
+func (registry *Registry) Schedules() []ScheduleConfigs {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	return append([]ScheduleConfigs{}, registry.schedules...)
+}
+
+//Middlewares return the global middlewares registered
+func (registry *Registry) Middlewares() []Middleware {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	return append([]Middleware{}, registry.middlewares...)
+}
+
+//Subscribers return the subscribers registered
+func (registry *Registry) Subscribers() []Subscriber {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	return append([]Subscriber{}, registry.subscribers...)
+}
+
+//RequiredConnections return the connections used by the jobs, ordered by name
+func (registry *Registry) RequiredConnections() []string {
+	required := make(map[string]bool)
+	for _, job := range registry.Jobs() {
+		required[job.Driver] = true
+		for _, connection := range job.Connections {
+			required[connection] = true
+		}
+	}
+
+	var connections []string
+	for name := range required {
+		connections = append(connections, name)
+	}
+	sort.Strings(connections)
+
+	return connections
+}
+
+//Validate checks that the connections used by the jobs are available and the workers queues are registered
+func (registry *Registry) Validate(connections []string) error {
+	available := make(map[string]bool)
+	for _, connection := range connections {
+		available[connection] = true
+	}
+
+	for _, job := range registry.Jobs() {
+		if !available[job.Driver] {
+			return fmt.Errorf("queue %v uses the driver %v without connection configured", job.QueueName, job.Driver)
+		}
+
+		for _, connection := range job.Connections {
+			if !available[connection] {
+				return fmt.Errorf("queue %v uses the connection %v not configured", job.QueueName, connection)
+			}
+		}
+	}
+
+	for _, worker := range registry.Workers() {
+		if _, err := WorkerJobs(worker, registry.Jobs()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateHandlers(job JobsConfigs) error {
+	if job.Routes.Routed() {
+		for jobType, handler := range job.Routes.Handlers {
+			if !validHandler(handler) {
+				return fmt.Errorf("handler of the job type %v of queue %v has an unsupported signature", jobType, job.QueueName)
+			}
+		}
+
+		if job.Routes.Fallback == FallbackRequeue && job.Routes.FallbackQueue == "" {
+			return fmt.Errorf("fallback queue of queue %v is required to requeue the jobs without handler", job.QueueName)
+		}
+
+		if job.Routes.Fallback == FallbackRequeue && job.Routes.FallbackQueue == job.QueueName {
+			return fmt.Errorf("fallback queue of queue %v is the queue itself", job.QueueName)
+		}
+
+		return nil
+	}
+
+	if !validHandler(job.Handle) {
+		return fmt.Errorf("handler of queue %v has an unsupported signature", job.QueueName)
+	}
+
+	return nil
+}
+
+func validHandler(handler interface{}) bool {
+	switch handler.(type) {
+	case func(interface{}, map[string]interface{}) error:
+		return true
+	case func(context.Context, interface{}, map[string]interface{}) error:
+		return true
+	case func(interface{}, map[string]interface{}) (interface{}, error):
+		return true
+	case func(context.Context, interface{}, map[string]interface{}) (interface{}, error):
+		return true
+	default:
+		return false
+	}
+}
+
+func validateRateLimit(job JobsConfigs) error {
+	switch job.RateLimit.Algorithm {
+	case "", RateLimitTokenBucket, RateLimitSlidingWindow:
+		return nil
+	default:
+		return fmt.Errorf("rate limit algorithm %v of queue %v is unknown", job.RateLimit.Algorithm, job.QueueName)
+	}
+}
+
+func validateDeadLetter(job JobsConfigs) error {
+	if !job.DeadLetter.Enabled {
+		return nil
+	}
+
+	if job.DeadLetter.QueueName(job.QueueName) == job.QueueName {
+		return fmt.Errorf("dead-letter queue of queue %v is the queue itself", job.QueueName)
+	}
+
+	return nil
+}
+
+//ValidateCompression checks the codec of the compression
+func ValidateCompression(compression CompressionConfigs) error {
+	switch compression.Codec {
+	case "", CompressionGzip, CompressionZstd, CompressionSnappy:
+		return nil
+	default:
+		return fmt.Errorf("compression codec %v is unknown", compression.Codec)
+	}
+}
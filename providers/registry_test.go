@@ -0,0 +1,199 @@
+package providers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func registryHandler(data interface{}, connections map[string]interface{}) error {
+	return nil
+}
+
+func registryContextHandler(ctx context.Context, data interface{}, connections map[string]interface{}) error {
+	return nil
+}
+
+func TestRegistryRegisterWithOptions(t *testing.T) {
+	registry := NewRegistry()
+
+	err := registry.Register("queues:email", registryContextHandler, WithAttempts(3), WithConnections("mongo", "mysql"),
+		WithConcurrency(ConcurrencyConfigs{Fields: []string{"account"}, Limit: 1}),
+		WithRateLimit(RateLimitConfigs{Limit: 100, Per: time.Minute}))
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	jobs := registry.Jobs()
+	if len(jobs) != 1 {
+		t.Fatalf("Expected 1 job registered but got %v", len(jobs))
+	}
+
+	job := jobs[0]
+	if job.QueueName != "queues:email" || job.Driver != "redis" || job.Attempts != 3 || !reflect.DeepEqual(job.Connections, []string{"mongo", "mysql"}) || job.Concurrency.Limit != 1 || job.RateLimit.Limit != 100 {
+		t.Errorf("Expected job configured by the options but got %v", job)
+	}
+}
+
+func TestRegistryRegisterReturnError(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("queues:email", registryHandler)
+
+	if err := registry.Register("queues:email", registryHandler); err == nil {
+		t.Errorf("Expected an error for duplicated queue")
+	}
+
+	if err := registry.Register("queues:sms", func() {}); err == nil {
+		t.Errorf("Expected an error for unsupported handler")
+	}
+
+	if err := registry.Register("", registryHandler); err == nil {
+		t.Errorf("Expected an error for job without queue name")
+	}
+
+	routes := RoutesConfigs{Field: "job", Handlers: map[string]interface{}{"SendSms": "handler"}}
+	if err := registry.Register("queues:default", nil, WithRoutes(routes)); err == nil {
+		t.Errorf("Expected an error for unsupported route handler")
+	}
+
+	for _, fallbackQueue := range []string{"", "queues:routed"} {
+		routes = RoutesConfigs{Field: "job", Handlers: map[string]interface{}{"SendSms": registryHandler}, Fallback: FallbackRequeue, FallbackQueue: fallbackQueue}
+		if err := registry.Register("queues:routed", nil, WithRoutes(routes)); err == nil {
+			t.Errorf("Expected an error for requeue fallback to the queue %q", fallbackQueue)
+		}
+	}
+
+	if err := registry.Register("queues:api", registryHandler, WithRateLimit(RateLimitConfigs{Algorithm: "leaky_bucket", Limit: 1, Per: time.Second})); err == nil {
+		t.Errorf("Expected an error for unknown rate limit algorithm")
+	}
+
+	if err := registry.Register("queues:dead", registryHandler, WithDeadLetter(DeadLetterConfigs{Enabled: true, Queue: "queues:dead"})); err == nil {
+		t.Errorf("Expected an error for dead-letter queue equal to the queue")
+	}
+
+	if err := registry.Register("queues:large", registryHandler, WithCompression(CompressionConfigs{Codec: "lz4"})); err == nil {
+		t.Errorf("Expected an error for unknown compression codec")
+	}
+}
+
+func TestRegistryRegisterRoutedJob(t *testing.T) {
+	registry := NewRegistry()
+
+	routes := RoutesConfigs{Field: "job", Handlers: map[string]interface{}{"SendSms": registryHandler}}
+	if err := registry.Register("queues:default", nil, WithRoutes(routes)); err != nil {
+		t.Errorf("Expected no error but got %v", err)
+	}
+}
+
+func TestRegistryRegisterWorkerReturnError(t *testing.T) {
+	registry := NewRegistry()
+	registry.RegisterWorker(WorkersConfigs{Name: "default", Queues: []string{"queues:high"}})
+
+	if err := registry.RegisterWorker(WorkersConfigs{Name: "default", Queues: []string{"queues:low"}}); err == nil {
+		t.Errorf("Expected an error for duplicated worker")
+	}
+
+	if err := registry.RegisterWorker(WorkersConfigs{Name: "other", Queues: []string{"queues:high"}}); err == nil {
+		t.Errorf("Expected an error for queue listened by two workers")
+	}
+}
+
+func TestRegistryValidate(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("queues:email", registryHandler, WithConnections("mongo"))
+	registry.RegisterWorker(WorkersConfigs{Name: "default", Driver: "redis", Queues: []string{"queues:email"}})
+
+	if err := registry.Validate([]string{"redis", "mongo"}); err != nil {
+		t.Errorf("Expected no error but got %v", err)
+	}
+
+	if err := registry.Validate([]string{"redis"}); err == nil {
+		t.Errorf("Expected an error for missing connection")
+	}
+
+	if err := registry.Validate([]string{"mongo"}); err == nil {
+		t.Errorf("Expected an error for missing driver connection")
+	}
+
+	registry.RegisterWorker(WorkersConfigs{Name: "unknown", Driver: "redis", Queues: []string{"queues:unknown"}})
+	if err := registry.Validate([]string{"redis", "mongo"}); err == nil {
+		t.Errorf("Expected an error for worker queue not registered")
+	}
+}
+
+func TestRegistryRequiredConnections(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("queues:email", registryHandler, WithConnections("mysql", "mongo"))
+	registry.Register("queues:sms", registryHandler, WithConnections("mongo"))
+
+	expected := []string{"mongo", "mysql", "redis"}
+	if connections := registry.RequiredConnections(); !reflect.DeepEqual(connections, expected) {
+		t.Errorf("Expected connections %v but got %v", expected, connections)
+	}
+}
+
+func TestRegistryRegisterSchedule(t *testing.T) {
+	registry := NewRegistry()
+
+	if err := registry.RegisterSchedule(ScheduleConfigs{Name: "report", Spec: "@hourly", QueueName: "queues:report"}); err != nil {
+		t.Errorf("Expected no error but got %v", err)
+	}
+
+	if err := registry.RegisterSchedule(ScheduleConfigs{Name: "report", Spec: "@daily", QueueName: "queues:report"}); err == nil {
+		t.Errorf("Expected an error for duplicated schedule")
+	}
+
+	if err := registry.RegisterSchedule(ScheduleConfigs{Name: "other", Spec: "@daily"}); err == nil {
+		t.Errorf("Expected an error for schedule without queue")
+	}
+
+	if len(registry.Schedules()) != 1 {
+		t.Errorf("Expected 1 schedule registered but got %v", len(registry.Schedules()))
+	}
+}
+
+func TestRegistryMiddlewaresAndSubscribers(t *testing.T) {
+	registry := NewRegistry()
+	registry.Use(func(next HandlerFunc) HandlerFunc { return next })
+	registry.Subscribe(Subscriber{Event: "JobFailed"})
+
+	if len(registry.Middlewares()) != 1 || len(registry.Subscribers()) != 1 {
+		t.Errorf("Expected middleware and subscriber registered")
+	}
+}
+
+func TestDefaultRegistry(t *testing.T) {
+	providers = []JobsConfigs{JobsConfigs{QueueName: "queues:email", Driver: "redis", Handle: registryHandler}}
+	workers = []WorkersConfigs{}
+	defer func() { providers = []JobsConfigs{} }()
+
+	registry, err := DefaultRegistry()
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	if len(registry.Jobs()) != 1 {
+		t.Errorf("Expected the jobs of the providers registered")
+	}
+}
+
+func TestRegistryRegisterHandlerWithResult(t *testing.T) {
+	registry := NewRegistry()
+
+	handler := func(data interface{}, connections map[string]interface{}) (interface{}, error) {
+		return nil, nil
+	}
+
+	contextHandler := func(ctx context.Context, data interface{}, connections map[string]interface{}) (interface{}, error) {
+		return nil, nil
+	}
+
+	if err := registry.Register("queues:download", handler); err != nil {
+		t.Errorf("Expected handler with result registered but got %v", err)
+	}
+
+	if err := registry.Register("queues:upload", contextHandler); err != nil {
+		t.Errorf("Expected handler with context and result registered but got %v", err)
+	}
+}
@@ -0,0 +1,22 @@
+package providers
+
+import "time"
+
+//DefaultResultTTL is the time the results are kept when no TTL is configured
+const DefaultResultTTL = 24 * time.Hour
+
+//ResultConfigs stores the result returned by the handlers, or the error of the last attempt,
+//under the job ID for the TTL so the callers can wait for it
+type ResultConfigs struct {
+	Store bool
+	TTL   time.Duration
+}
+
+//TTLDuration return the time the results are kept
+func (configs ResultConfigs) TTLDuration() time.Duration {
+	if configs.TTL <= 0 {
+		return DefaultResultTTL
+	}
+
+	return configs.TTL
+}
@@ -0,0 +1,41 @@
+package providers
+
+//Actions for the jobs with a type without handler
+const (
+	FallbackFail    = "fail"
+	FallbackRequeue = "requeue"
+	FallbackDiscard = "discard"
+)
+
+//RoutesConfigs routes the jobs of a queue to the handlers by a field of the payload,
+//e.g. "job" or "displayName", nested fields are separated by dots, e.g. "data.commandName".
+//The jobs of an unknown type fail without retries, are requeued to the FallbackQueue or
+//are discarded, by the Fallback action
+type RoutesConfigs struct {
+	Field         string
+	Handlers      map[string]interface{}
+	Fallback      string
+	FallbackQueue string
+}
+
+//Routed return if the jobs are routed by type
+func (routes RoutesConfigs) Routed() bool {
+	return routes.Field != "" && len(routes.Handlers) > 0
+}
+
+//JobType return the type of the job in the payload
+func (routes RoutesConfigs) JobType(payload map[string]interface{}) string {
+	if !routes.Routed() {
+		return ""
+	}
+
+	value := FieldValue(payload, routes.Field)
+	jobType, _ := value.(string)
+	return jobType
+}
+
+//Handler return the handler of the job type
+func (routes RoutesConfigs) Handler(jobType string) (interface{}, bool) {
+	handler, ok := routes.Handlers[jobType]
+	return handler, ok
+}
@@ -0,0 +1,36 @@
+package providers
+
+import "testing"
+
+func TestRoutesJobType(t *testing.T) {
+	routes := RoutesConfigs{Field: "data.commandName", Handlers: map[string]interface{}{"SendEmail": nil}}
+
+	payload := map[string]interface{}{"data": map[string]interface{}{"commandName": "SendEmail"}}
+	if jobType := routes.JobType(payload); jobType != "SendEmail" {
+		t.Errorf("Expected job type SendEmail but got %v", jobType)
+	}
+
+	if jobType := routes.JobType(map[string]interface{}{"data": "SendEmail"}); jobType != "" {
+		t.Errorf("Expected empty job type but got %v", jobType)
+	}
+}
+
+func TestRoutesNotRouted(t *testing.T) {
+	routes := RoutesConfigs{Field: "job"}
+
+	if routes.Routed() || routes.JobType(map[string]interface{}{"job": "SendEmail"}) != "" {
+		t.Errorf("Expected jobs not routed without handlers")
+	}
+}
+
+func TestRoutesHandler(t *testing.T) {
+	routes := RoutesConfigs{Field: "job", Handlers: map[string]interface{}{"SendEmail": "handler"}}
+
+	if handler, ok := routes.Handler("SendEmail"); !ok || handler != "handler" {
+		t.Errorf("Expected handler of SendEmail but got %v", handler)
+	}
+
+	if _, ok := routes.Handler("Unknown"); ok {
+		t.Errorf("Expected no handler for unknown job type")
+	}
+}
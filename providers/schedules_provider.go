@@ -0,0 +1,31 @@
+package providers
+
+//Policies for the runs of a schedule missed while no worker was running
+const (
+	MissedSkip    = "skip"
+	MissedCatchUp = "catch_up"
+)
+
+//ScheduleConfigs configurations of a recurring job dispatched to a queue.
+//Spec is a cron expression of five fields, e.g. "*/5 * * * *", six fields starting with the seconds,
+//e.g. "0 */5 * * * *", or a descriptor, e.g. "@hourly",
+//evaluated in the Location time zone, UTC by default.
+//The missed runs are skipped by default or dispatched with the catch up policy
+type ScheduleConfigs struct {
+	Name      string
+	Spec      string
+	Location  string
+	QueueName string
+	Payload   map[string]interface{}
+	Missed    string
+}
+
+var schedules = []ScheduleConfigs{
+	//Add your recurring jobs here, e.g.
+	//ScheduleConfigs{Name: "daily-report", Spec: "0 0 9 * * *", Location: "America/Sao_Paulo", QueueName: "queues:sample"},
+}
+
+//GetAllSchedules Return all recurring jobs
+func GetAllSchedules() []ScheduleConfigs {
+	return schedules
+}
@@ -0,0 +1,31 @@
+package providers
+
+import "go-queue/events"
+
+//Subscriber configuration of a listener of the jobs events
+type Subscriber struct {
+	Event    string
+	Listener events.Listener
+	Async    bool
+}
+
+var subscribers = []Subscriber{
+	//Add your events subscribers here
+}
+
+//GetAllSubscribers Return all events subscribers
+func GetAllSubscribers() []Subscriber {
+	return subscribers
+}
+
+//SubscribeAll register the subscribers in the events bus
+func SubscribeAll(bus *events.Bus, subscribers []Subscriber) {
+	for _, subscriber := range subscribers {
+		if subscriber.Async {
+			bus.SubscribeAsync(subscriber.Event, subscriber.Listener)
+			continue
+		}
+
+		bus.Subscribe(subscriber.Event, subscriber.Listener)
+	}
+}
@@ -0,0 +1,34 @@
+package providers
+
+import (
+	"go-queue/events"
+	"testing"
+)
+
+func TestGetAllSubscribers(t *testing.T) {
+	subscribers = []Subscriber{}
+
+	returned := GetAllSubscribers()
+
+	if len(returned) != 0 {
+		t.Errorf("Expected an empty list of subscribers but got %v", len(returned))
+	}
+}
+
+func TestSubscribeAll(t *testing.T) {
+	bus := events.NewBus()
+
+	syncCalls := 0
+	asyncCalls := 0
+	SubscribeAll(bus, []Subscriber{
+		Subscriber{Event: events.JobFailedEvent, Listener: func(events.Event) { syncCalls++ }},
+		Subscriber{Event: events.JobFailedEvent, Listener: func(events.Event) { asyncCalls++ }, Async: true},
+	})
+
+	bus.Emit(events.JobFailed{})
+	bus.Wait()
+
+	if syncCalls != 1 || asyncCalls != 1 {
+		t.Errorf("Expected both subscribers called once but got %v and %v", syncCalls, asyncCalls)
+	}
+}
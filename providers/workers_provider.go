@@ -0,0 +1,79 @@
+package providers
+
+import "fmt"
+
+//WorkersConfigs configurations of a worker listening many queues, ordered from the highest priority.
+//The jobs of each queue are configured in the jobs providers.
+//Without weights the higher priority queues are always drained first, with weights each queue
+//is tried first in proportion to its weight so the lower priority queues are not starved
+type WorkersConfigs struct {
+	Name    string
+	Driver  string
+	Queues  []string
+	Weights []int
+}
+
+var workers = []WorkersConfigs{
+	//Add your worker configuration here, e.g.
+	//WorkersConfigs{Name: "default", Driver: "redis", Queues: []string{"queues:high", "queues:default", "queues:low"}},
+}
+
+//GetAllWorkers Return all workers
+func GetAllWorkers() []WorkersConfigs {
+	return workers
+}
+
+//WorkerJobs return the jobs of the queues of the worker in priority order
+func WorkerJobs(worker WorkersConfigs, jobs []JobsConfigs) ([]JobsConfigs, error) {
+	if len(worker.Queues) == 0 {
+		return nil, fmt.Errorf("worker %v has no queues", worker.Name)
+	}
+
+	if len(worker.Weights) > 0 && len(worker.Weights) != len(worker.Queues) {
+		return nil, fmt.Errorf("worker %v has %v weights for %v queues", worker.Name, len(worker.Weights), len(worker.Queues))
+	}
+
+	for _, weight := range worker.Weights {
+		if weight < 1 {
+			return nil, fmt.Errorf("worker %v has a weight lower than 1", worker.Name)
+		}
+	}
+
+	var workerJobs []JobsConfigs
+	for _, queueName := range worker.Queues {
+		job, ok := findJob(queueName, jobs)
+		if !ok {
+			return nil, fmt.Errorf("worker %v listens queue %v without job configured", worker.Name, queueName)
+		}
+
+		if job.Driver != worker.Driver {
+			return nil, fmt.Errorf("worker %v uses driver %v but queue %v uses %v", worker.Name, worker.Driver, queueName, job.Driver)
+		}
+
+		workerJobs = append(workerJobs, job)
+	}
+
+	return workerJobs, nil
+}
+
+//WorkersQueues return the queues listened by the workers
+func WorkersQueues(workers []WorkersConfigs) map[string]bool {
+	queues := make(map[string]bool)
+	for _, worker := range workers {
+		for _, queueName := range worker.Queues {
+			queues[queueName] = true
+		}
+	}
+
+	return queues
+}
+
+func findJob(queueName string, jobs []JobsConfigs) (JobsConfigs, bool) {
+	for _, job := range jobs {
+		if job.QueueName == queueName {
+			return job, true
+		}
+	}
+
+	return JobsConfigs{}, false
+}
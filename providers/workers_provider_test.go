@@ -0,0 +1,56 @@
+package providers
+
+import "testing"
+
+var workerTestJobs = []JobsConfigs{
+	JobsConfigs{QueueName: "queues:low", Driver: "redis"},
+	JobsConfigs{QueueName: "queues:high", Driver: "redis"},
+	JobsConfigs{QueueName: "queues:mongo", Driver: "mongo"},
+}
+
+func TestGetAllWorkers(t *testing.T) {
+	workers = []WorkersConfigs{}
+
+	if len(GetAllWorkers()) != 0 {
+		t.Errorf("Expected an empty list of workers")
+	}
+}
+
+func TestWorkerJobsInPriorityOrder(t *testing.T) {
+	worker := WorkersConfigs{Name: "test", Driver: "redis", Queues: []string{"queues:high", "queues:low"}}
+
+	jobs, err := WorkerJobs(worker, workerTestJobs)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	if len(jobs) != 2 || jobs[0].QueueName != "queues:high" || jobs[1].QueueName != "queues:low" {
+		t.Errorf("Expected jobs ordered by priority but got %v", jobs)
+	}
+}
+
+func TestWorkerJobsReturnError(t *testing.T) {
+	workers := []WorkersConfigs{
+		WorkersConfigs{Name: "empty", Driver: "redis"},
+		WorkersConfigs{Name: "unknown", Driver: "redis", Queues: []string{"queues:unknown"}},
+		WorkersConfigs{Name: "driver", Driver: "redis", Queues: []string{"queues:mongo"}},
+		WorkersConfigs{Name: "weights", Driver: "redis", Queues: []string{"queues:high", "queues:low"}, Weights: []int{1}},
+		WorkersConfigs{Name: "zero", Driver: "redis", Queues: []string{"queues:high"}, Weights: []int{0}},
+	}
+
+	for _, worker := range workers {
+		if _, err := WorkerJobs(worker, workerTestJobs); err == nil {
+			t.Errorf("Expected an error for worker %v", worker.Name)
+		}
+	}
+}
+
+func TestWorkersQueues(t *testing.T) {
+	queues := WorkersQueues([]WorkersConfigs{
+		WorkersConfigs{Queues: []string{"queues:high", "queues:low"}},
+	})
+
+	if len(queues) != 2 || !queues["queues:high"] || !queues["queues:low"] {
+		t.Errorf("Expected queues of the workers but got %v", queues)
+	}
+}
@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"fmt"
+	"go-queue/providers"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const keyPrefix = "ratelimit:"
+
+//tokenBucketScript refills the bucket by the time passed and takes a token,
+//return if it was allowed and the milliseconds until the next token
+const tokenBucketScript = `
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1]) or capacity
+local ts = tonumber(state[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate)
+end
+redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(capacity / rate))
+return {allowed, wait}`
+
+//slidingWindowScript counts the executions of the last window,
+//return if it was allowed and the milliseconds until the oldest execution leaves the window
+const slidingWindowScript = `
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+if redis.call("ZCARD", KEYS[1]) < limit then
+	redis.call("ZADD", KEYS[1], now, ARGV[4])
+	redis.call("PEXPIRE", KEYS[1], window)
+	return {1, 0}
+end
+local oldest = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+return {0, tonumber(oldest[2]) + window - now}`
+
+//RedisInterface is the redis client used by the rate limiters
+type RedisInterface interface {
+	Eval(script string, keys []string, args ...interface{}) *redis.Cmd
+}
+
+//Limiter enforces the rate limits across the workers sharing the redis
+type Limiter struct {
+	Redis RedisInterface
+}
+
+//Key return the rate limit key of the queue and the key derived from the payload
+func Key(queueName string, key string) string {
+	if key == "" {
+		return keyPrefix + queueName
+	}
+
+	return keyPrefix + queueName + ":" + key
+}
+
+//Allow takes an execution of the key, return if it was allowed and, when throttled, the time until it is allowed
+func (l Limiter) Allow(key string, configs providers.RateLimitConfigs, member string) (bool, time.Duration, error) {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	window := int64(configs.Per / time.Millisecond)
+
+	var cmd *redis.Cmd
+	switch configs.Algorithm {
+	case providers.RateLimitSlidingWindow:
+		cmd = l.Redis.Eval(slidingWindowScript, []string{key}, now, window, configs.Limit, member)
+	case providers.RateLimitTokenBucket, "":
+		rate := float64(configs.Limit) / float64(window)
+		cmd = l.Redis.Eval(tokenBucketScript, []string{key}, configs.Limit, rate, now)
+	default:
+		return false, 0, fmt.Errorf("unknown rate limit algorithm %v", configs.Algorithm)
+	}
+
+	result, err := cmd.Result()
+	if err != nil {
+		return false, 0, err
+	}
+
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 2 {
+		return false, 0, fmt.Errorf("unexpected rate limit result %v", result)
+	}
+
+	allowed, _ := values[0].(int64)
+	wait, _ := values[1].(int64)
+
+	return allowed == 1, time.Duration(wait) * time.Millisecond, nil
+}
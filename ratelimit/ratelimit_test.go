@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"errors"
+	"go-queue/providers"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+type redisClientMock struct {
+	script string
+	args   []interface{}
+	result interface{}
+}
+
+func (r *redisClientMock) Eval(script string, keys []string, args ...interface{}) *redis.Cmd {
+	r.script = script
+	r.args = args
+	if err, ok := r.result.(error); ok {
+		return redis.NewCmdResult(nil, err)
+	}
+
+	return redis.NewCmdResult(r.result, nil)
+}
+
+func TestAllowTokenBucket(t *testing.T) {
+	redisClient := &redisClientMock{result: []interface{}{int64(1), int64(0)}}
+	limiter := Limiter{Redis: redisClient}
+
+	allowed, wait, err := limiter.Allow(Key("queues:test", ""), providers.RateLimitConfigs{Limit: 100, Per: time.Minute}, "job")
+	if err != nil || !allowed || wait != 0 {
+		t.Errorf("Expected job allowed but got %v %v %v", allowed, wait, err)
+	}
+
+	if redisClient.script != tokenBucketScript || redisClient.args[0] != 100 || redisClient.args[1] != float64(100)/60000 {
+		t.Errorf("Expected token bucket with 100 tokens per minute but got %v", redisClient.args)
+	}
+}
+
+func TestAllowSlidingWindowThrottled(t *testing.T) {
+	redisClient := &redisClientMock{result: []interface{}{int64(0), int64(1500)}}
+	limiter := Limiter{Redis: redisClient}
+
+	configs := providers.RateLimitConfigs{Algorithm: providers.RateLimitSlidingWindow, Limit: 10, Per: time.Second}
+	allowed, wait, err := limiter.Allow(Key("queues:test", "account:1"), configs, "job")
+	if err != nil || allowed || wait != 1500*time.Millisecond {
+		t.Errorf("Expected job throttled for 1.5s but got %v %v %v", allowed, wait, err)
+	}
+
+	if redisClient.script != slidingWindowScript || redisClient.args[1] != int64(1000) || redisClient.args[3] != "job" {
+		t.Errorf("Expected sliding window of a second but got %v", redisClient.args)
+	}
+}
+
+func TestAllowReturnError(t *testing.T) {
+	configs := providers.RateLimitConfigs{Limit: 1, Per: time.Second}
+
+	if _, _, err := (Limiter{Redis: &redisClientMock{result: errors.New("Eval")}}).Allow("key", configs, "job"); err == nil {
+		t.Errorf("Expected redis error")
+	}
+
+	if _, _, err := (Limiter{Redis: &redisClientMock{result: int64(1)}}).Allow("key", configs, "job"); err == nil {
+		t.Errorf("Expected error for unexpected result")
+	}
+
+	configs.Algorithm = "leaky_bucket"
+	if _, _, err := (Limiter{Redis: &redisClientMock{}}).Allow("key", configs, "job"); err == nil {
+		t.Errorf("Expected error for unknown algorithm")
+	}
+}
+
+func TestKey(t *testing.T) {
+	if key := Key("queues:test", ""); key != "ratelimit:queues:test" {
+		t.Errorf("Expected key of the queue but got %v", key)
+	}
+
+	if key := Key("queues:test", "account:1"); key != "ratelimit:queues:test:account:1" {
+		t.Errorf("Expected key of the payload but got %v", key)
+	}
+}
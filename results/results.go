@@ -0,0 +1,148 @@
+package results
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+//Statuses of the jobs finished
+const (
+	StatusProcessed = "processed"
+	StatusFailed    = "failed"
+)
+
+//DefaultPollInterval is the time between the reads of the result while waiting it
+const DefaultPollInterval = 100 * time.Millisecond
+
+const keyPrefix = "result:"
+
+//ErrNotFound is returned while the job did not finish or when its result expired
+var ErrNotFound = errors.New("result not found")
+
+//Result is the output of a finished job, the value returned by the handler or the error of the last attempt
+type Result struct {
+	JobID      string          `json:"job_id"`
+	Status     string          `json:"status"`
+	Value      json.RawMessage `json:"value,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	FinishedAt time.Time       `json:"finished_at"`
+}
+
+//Decode decodes the value returned by the handler into target
+func (r Result) Decode(target interface{}) error {
+	if len(r.Value) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(r.Value, target)
+}
+
+//New return the result of a job with the value returned by the handler and its error
+func New(jobID string, value interface{}, jobError error) (Result, error) {
+	result := Result{JobID: jobID, Status: StatusProcessed, FinishedAt: time.Now()}
+	if jobError != nil {
+		result.Status = StatusFailed
+		result.Error = jobError.Error()
+		return result, nil
+	}
+
+	if value == nil {
+		return result, nil
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return result, err
+	}
+
+	result.Value = encoded
+	return result, nil
+}
+
+//Store keeps the results of the jobs by job ID
+type Store interface {
+	Save(result Result, ttl time.Duration) error
+	Get(jobID string) (Result, error)
+}
+
+//RedisInterface is the redis client used to store the results
+type RedisInterface interface {
+	Set(key string, value interface{}, expiration time.Duration) *redis.StatusCmd
+	Get(key string) *redis.StringCmd
+}
+
+//RedisStore keeps the results in redis until they expire
+type RedisStore struct {
+	Redis RedisInterface
+}
+
+//Key return the key of the result of the job
+func Key(jobID string) string {
+	return keyPrefix + jobID
+}
+
+//Save stores the result for the ttl
+func (s *RedisStore) Save(result Result, ttl time.Duration) error {
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	return s.Redis.Set(Key(result.JobID), string(encoded), ttl).Err()
+}
+
+//Get return the result of the job, ErrNotFound when there is no result
+func (s *RedisStore) Get(jobID string) (Result, error) {
+	var result Result
+
+	encoded, err := s.Redis.Get(Key(jobID)).Result()
+	if err == redis.Nil {
+		return result, ErrNotFound
+	}
+
+	if err != nil {
+		return result, err
+	}
+
+	err = json.Unmarshal([]byte(encoded), &result)
+	return result, err
+}
+
+//Client reads the results of the jobs dispatched
+type Client struct {
+	Store        Store
+	PollInterval time.Duration
+}
+
+//Get return the result of the job, ErrNotFound while the job did not finish
+func (c *Client) Get(jobID string) (Result, error) {
+	return c.Store.Get(jobID)
+}
+
+//Wait blocks until the job finishes or the context is done, returning the error of the context
+func (c *Client) Wait(ctx context.Context, jobID string) (Result, error) {
+	interval := c.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		result, err := c.Store.Get(jobID)
+		if err != ErrNotFound {
+			return result, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package results
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+//------------------------- MOCK FUNCTIONS ---------------------
+type redisClientMock struct {
+	values map[string]string
+	ttl    time.Duration
+}
+
+func (r *redisClientMock) Set(key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	r.values[key] = value.(string)
+	r.ttl = expiration
+	return redis.NewStatusResult("OK", nil)
+}
+
+func (r *redisClientMock) Get(key string) *redis.StringCmd {
+	value, ok := r.values[key]
+	if !ok {
+		return redis.NewStringResult("", redis.Nil)
+	}
+
+	return redis.NewStringResult(value, nil)
+}
+
+//storeMock finishes the job after some reads
+type storeMock struct {
+	reads      int
+	finishedAt int
+}
+
+func (s *storeMock) Save(result Result, ttl time.Duration) error {
+	return nil
+}
+
+func (s *storeMock) Get(jobID string) (Result, error) {
+	s.reads++
+	if s.finishedAt == 0 || s.reads < s.finishedAt {
+		return Result{}, ErrNotFound
+	}
+
+	return Result{JobID: jobID, Status: StatusProcessed}, nil
+}
+
+//------------------------------ TESTS ---------------------------------
+func TestRedisStoreSaveAndGet(t *testing.T) {
+	redisMock := &redisClientMock{values: make(map[string]string)}
+	store := &RedisStore{Redis: redisMock}
+
+	result, err := New("job", map[string]interface{}{"total": 10}, nil)
+	if err != nil {
+		t.Fatalf("Expected result but got %v", err)
+	}
+
+	if err := store.Save(result, time.Hour); err != nil || redisMock.ttl != time.Hour {
+		t.Fatalf("Expected result saved for an hour but got %v %v", err, redisMock.ttl)
+	}
+
+	stored, err := store.Get("job")
+	if err != nil || stored.Status != StatusProcessed {
+		t.Fatalf("Expected result stored but got %v %v", stored, err)
+	}
+
+	var value struct {
+		Total int `json:"total"`
+	}
+	if err := stored.Decode(&value); err != nil || value.Total != 10 {
+		t.Errorf("Expected value of the handler decoded but got %v %v", value, err)
+	}
+
+	if _, err := store.Get("unknown"); err != ErrNotFound {
+		t.Errorf("Expected result not found but got %v", err)
+	}
+}
+
+func TestNewFailedResult(t *testing.T) {
+	result, _ := New("job", "ignored", errors.New("timeout"))
+	if result.Status != StatusFailed || result.Error != "timeout" || len(result.Value) != 0 {
+		t.Errorf("Expected failed result but got %v", result)
+	}
+
+	if _, err := New("job", func() {}, nil); err == nil {
+		t.Errorf("Expected error for value not serializable")
+	}
+}
+
+func TestClientWaitUntilFinished(t *testing.T) {
+	store := &storeMock{finishedAt: 3}
+	client := Client{Store: store, PollInterval: time.Millisecond}
+
+	result, err := client.Wait(context.Background(), "job")
+	if err != nil || result.JobID != "job" || store.reads != 3 {
+		t.Errorf("Expected result after 3 reads but got %v %v %v", result, err, store.reads)
+	}
+}
+
+func TestClientWaitReturnContextError(t *testing.T) {
+	client := Client{Store: &storeMock{}, PollInterval: time.Millisecond}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := client.Wait(ctx, "job"); err != context.DeadlineExceeded {
+		t.Errorf("Expected deadline exceeded but got %v", err)
+	}
+}
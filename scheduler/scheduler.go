@@ -0,0 +1,282 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"go-queue/dispatcher"
+	"go-queue/logger"
+	"go-queue/providers"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/robfig/cron"
+)
+
+//Defaults of the scheduler configs not setted
+const (
+	DefaultInterval    = time.Second
+	DefaultMissedAfter = time.Minute
+	DefaultMaxCatchUp  = 10
+	DefaultLockTTL     = 24 * time.Hour
+)
+
+const keyPrefix = "scheduler:"
+
+//RedisInterface is the redis client used to lock the runs and keep the last and next run times
+type RedisInterface interface {
+	Get(key string) *redis.StringCmd
+	Set(key string, value interface{}, expiration time.Duration) *redis.StatusCmd
+	SetNX(key string, value interface{}, expiration time.Duration) *redis.BoolCmd
+	Del(keys ...string) *redis.IntCmd
+}
+
+//Status is the last and next run of a schedule
+type Status struct {
+	Name      string    `json:"name"`
+	QueueName string    `json:"queue"`
+	LastRun   time.Time `json:"last_run"`
+	NextRun   time.Time `json:"next_run"`
+}
+
+type entry struct {
+	configs  providers.ScheduleConfigs
+	schedule cron.Schedule
+	location *time.Location
+}
+
+//Scheduler dispatches the recurring jobs on each tick of their cron expression.
+//Each run is locked in redis, so only one of the schedulers sharing the redis dispatches it
+type Scheduler struct {
+	Redis       RedisInterface
+	Dispatcher  *dispatcher.Dispatcher
+	Logger      logger.Logger
+	Interval    time.Duration
+	MissedAfter time.Duration
+	MaxCatchUp  int
+	LockTTL     time.Duration
+
+	entries []entry
+	mutex   sync.Mutex
+	since   map[string]time.Time
+}
+
+//New return a scheduler of the schedules, failing on invalid cron expressions or time zones
+func New(schedules []providers.ScheduleConfigs, redisClient RedisInterface, jobsDispatcher *dispatcher.Dispatcher) (*Scheduler, error) {
+	scheduler := &Scheduler{
+		Redis:      redisClient,
+		Dispatcher: jobsDispatcher,
+		since:      make(map[string]time.Time),
+	}
+
+	for _, configs := range schedules {
+		schedule, err := parseSpec(configs.Spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid spec of schedule %v: %v", configs.Name, err)
+		}
+
+		location, err := time.LoadLocation(configs.Location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid location of schedule %v: %v", configs.Name, err)
+		}
+
+		scheduler.entries = append(scheduler.entries, entry{configs: configs, schedule: schedule, location: location})
+	}
+
+	return scheduler, nil
+}
+
+//parseSpec parses the cron expression, the standard crontab expressions of five fields are evaluated
+//by minute and the expressions of six fields start with the seconds
+func parseSpec(spec string) (cron.Schedule, error) {
+	if fields := strings.Fields(spec); len(fields) == 5 {
+		return cron.ParseStandard(spec)
+	}
+
+	return cron.Parse(spec)
+}
+
+//Run dispatches the due runs on each interval until stop is closed
+func (s *Scheduler) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(s.interval())
+	defer ticker.Stop()
+
+	s.Tick(time.Now())
+	for {
+		select {
+		case now := <-ticker.C:
+			s.Tick(now)
+		case <-stop:
+			return
+		}
+	}
+}
+
+//Tick dispatches the runs of the schedules due until now
+func (s *Scheduler) Tick(now time.Time) {
+	for _, e := range s.entries {
+		err := s.runDue(e, now)
+		if err != nil {
+			s.scheduleLogger(e).Errorf("Error to run schedule: %v", err)
+		}
+	}
+}
+
+//Statuses return the last and next run of the schedules
+func (s *Scheduler) Statuses() ([]Status, error) {
+	var statuses []Status
+	for _, e := range s.entries {
+		status := Status{Name: e.configs.Name, QueueName: e.configs.QueueName}
+
+		var err error
+		if status.LastRun, err = s.readTime(lastRunKey(e)); err != nil {
+			return nil, err
+		}
+
+		if status.NextRun, err = s.readTime(nextRunKey(e)); err != nil {
+			return nil, err
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+func (s *Scheduler) runDue(e entry, now time.Time) error {
+	from, err := s.lastRun(e, now)
+	if err != nil {
+		return err
+	}
+
+	cutoff := now.Add(-s.missedAfter())
+	if e.configs.Missed != providers.MissedCatchUp && from.Before(cutoff) {
+		if e.schedule.Next(from.In(e.location)).Before(cutoff) {
+			s.scheduleLogger(e).Warnf("Skipping runs missed since %v", from)
+		}
+
+		from = cutoff
+	}
+
+	var lastTick time.Time
+	tick := e.schedule.Next(from.In(e.location))
+	for runs := 0; !tick.IsZero() && !tick.After(now) && runs < s.maxCatchUp(); runs++ {
+		err := s.dispatch(e, tick)
+		if err != nil {
+			return err
+		}
+
+		lastTick = tick
+		tick = e.schedule.Next(tick)
+	}
+
+	if !lastTick.IsZero() {
+		if err := s.Redis.Set(lastRunKey(e), lastTick.Format(time.RFC3339Nano), 0).Err(); err != nil {
+			return err
+		}
+	}
+
+	return s.Redis.Set(nextRunKey(e), tick.Format(time.RFC3339Nano), 0).Err()
+}
+
+//dispatch pushes the run of the tick when this scheduler got its lock
+func (s *Scheduler) dispatch(e entry, tick time.Time) error {
+	lock := fmt.Sprintf("%v%v:lock:%v", keyPrefix, e.configs.Name, tick.Unix())
+
+	acquired, err := s.Redis.SetNX(lock, time.Now().Format(time.RFC3339Nano), s.lockTTL()).Result()
+	if err != nil || !acquired {
+		return err
+	}
+
+	payload := make(map[string]interface{})
+	for key, value := range e.configs.Payload {
+		payload[key] = value
+	}
+	payload["scheduled_at"] = tick.Format(time.RFC3339)
+
+	id, err := s.Dispatcher.Dispatch(context.Background(), e.configs.QueueName, payload)
+	if err != nil {
+		s.Redis.Del(lock)
+		return err
+	}
+
+	s.scheduleLogger(e).WithFields(logger.Fields{"job_id": id, "scheduled_at": tick}).Infof("Schedule dispatched")
+	return nil
+}
+
+//lastRun return the last run recorded in redis or, for a schedule never run, the time the scheduler first saw it
+func (s *Scheduler) lastRun(e entry, now time.Time) (time.Time, error) {
+	lastRun, err := s.readTime(lastRunKey(e))
+	if err != nil || !lastRun.IsZero() {
+		return lastRun, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	since, ok := s.since[e.configs.Name]
+	if !ok {
+		since = now
+		s.since[e.configs.Name] = since
+	}
+
+	return since, nil
+}
+
+func (s *Scheduler) readTime(key string) (time.Time, error) {
+	value, err := s.Redis.Get(key).Result()
+	if err == redis.Nil {
+		return time.Time{}, nil
+	}
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+func (s *Scheduler) scheduleLogger(e entry) logger.Logger {
+	return logger.OrDefault(s.Logger).WithFields(logger.Fields{"schedule": e.configs.Name, "queue": e.configs.QueueName})
+}
+
+func (s *Scheduler) interval() time.Duration {
+	if s.Interval <= 0 {
+		return DefaultInterval
+	}
+
+	return s.Interval
+}
+
+func (s *Scheduler) missedAfter() time.Duration {
+	if s.MissedAfter <= 0 {
+		return DefaultMissedAfter
+	}
+
+	return s.MissedAfter
+}
+
+func (s *Scheduler) maxCatchUp() int {
+	if s.MaxCatchUp <= 0 {
+		return DefaultMaxCatchUp
+	}
+
+	return s.MaxCatchUp
+}
+
+func (s *Scheduler) lockTTL() time.Duration {
+	if s.LockTTL <= 0 {
+		return DefaultLockTTL
+	}
+
+	return s.LockTTL
+}
+
+func lastRunKey(e entry) string {
+	return keyPrefix + e.configs.Name + ":last_run"
+}
+
+func nextRunKey(e entry) string {
+	return keyPrefix + e.configs.Name + ":next_run"
+}
@@ -0,0 +1,217 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"go-queue/dispatcher"
+	"go-queue/providers"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+//------------------------- REDIS MOCK ------------------------
+type redisClientMock struct {
+	values map[string]string
+	pushed []string
+}
+
+func newRedisClientMock() *redisClientMock {
+	return &redisClientMock{values: make(map[string]string)}
+}
+
+func (r *redisClientMock) Get(key string) *redis.StringCmd {
+	value, ok := r.values[key]
+	if !ok {
+		return redis.NewStringResult("", redis.Nil)
+	}
+
+	return redis.NewStringResult(value, nil)
+}
+
+func (r *redisClientMock) Set(key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	r.values[key] = value.(string)
+	return redis.NewStatusResult("OK", nil)
+}
+
+func (r *redisClientMock) SetNX(key string, value interface{}, expiration time.Duration) *redis.BoolCmd {
+	if _, ok := r.values[key]; ok {
+		return redis.NewBoolResult(false, nil)
+	}
+
+	r.values[key] = value.(string)
+	return redis.NewBoolResult(true, nil)
+}
+
+func (r *redisClientMock) Del(keys ...string) *redis.IntCmd {
+	for _, key := range keys {
+		delete(r.values, key)
+	}
+
+	return redis.NewIntResult(int64(len(keys)), nil)
+}
+
+func (r *redisClientMock) RPush(key string, values ...interface{}) *redis.IntCmd {
+	if key == "queues:error" {
+		return redis.NewIntResult(0, errors.New("RPush"))
+	}
+
+	r.pushed = append(r.pushed, values[0].(string))
+	return redis.NewIntResult(1, nil)
+}
+
+func newScheduler(t *testing.T, redisClient *redisClientMock, schedules ...providers.ScheduleConfigs) *Scheduler {
+	scheduler, err := New(schedules, redisClient, &dispatcher.Dispatcher{Client: redisClient})
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	return scheduler
+}
+
+var startTime = time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+
+//------------------------------ TESTS ---------------------------------
+func TestTickDispatchDueRuns(t *testing.T) {
+	redisClient := newRedisClientMock()
+	scheduler := newScheduler(t, redisClient, providers.ScheduleConfigs{
+		Name: "every-second", Spec: "* * * * * *", QueueName: "queues:test", Payload: map[string]interface{}{"report": "daily"},
+	})
+
+	scheduler.Tick(startTime)
+	if len(redisClient.pushed) != 0 {
+		t.Fatalf("Expected no run dispatched on the first tick but got %v", len(redisClient.pushed))
+	}
+
+	scheduler.Tick(startTime.Add(3 * time.Second))
+	if len(redisClient.pushed) != 3 {
+		t.Fatalf("Expected 3 runs dispatched but got %v", len(redisClient.pushed))
+	}
+
+	payload := make(map[string]interface{})
+	json.Unmarshal([]byte(redisClient.pushed[0]), &payload)
+	if payload["report"] != "daily" || payload["scheduled_at"] != "2026-01-01T10:00:01Z" {
+		t.Errorf("Expected payload of the schedule with the run time but got %v", payload)
+	}
+
+	statuses, err := scheduler.Statuses()
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	if !statuses[0].LastRun.Equal(startTime.Add(3*time.Second)) || !statuses[0].NextRun.Equal(startTime.Add(4*time.Second)) {
+		t.Errorf("Expected last and next run recorded but got %v", statuses[0])
+	}
+}
+
+func TestTickSkipRunLockedByAnotherScheduler(t *testing.T) {
+	redisClient := newRedisClientMock()
+	scheduler := newScheduler(t, redisClient, providers.ScheduleConfigs{Name: "every-second", Spec: "* * * * * *", QueueName: "queues:test"})
+
+	scheduler.Tick(startTime)
+	redisClient.SetNX("scheduler:every-second:lock:"+formatUnix(startTime.Add(time.Second)), "other", 0)
+	scheduler.Tick(startTime.Add(2 * time.Second))
+
+	if len(redisClient.pushed) != 1 {
+		t.Errorf("Expected only the run not locked dispatched but got %v", len(redisClient.pushed))
+	}
+}
+
+func TestTickSkipMissedRuns(t *testing.T) {
+	redisClient := newRedisClientMock()
+	redisClient.Set("scheduler:every-minute:last_run", startTime.Add(-time.Hour).Format(time.RFC3339Nano), 0)
+	scheduler := newScheduler(t, redisClient, providers.ScheduleConfigs{Name: "every-minute", Spec: "0 * * * * *", QueueName: "queues:test"})
+
+	scheduler.Tick(startTime)
+
+	if len(redisClient.pushed) != 1 {
+		t.Errorf("Expected only the run on time dispatched but got %v", len(redisClient.pushed))
+	}
+}
+
+func TestTickCatchUpMissedRuns(t *testing.T) {
+	redisClient := newRedisClientMock()
+	redisClient.Set("scheduler:every-minute:last_run", startTime.Add(-time.Hour).Format(time.RFC3339Nano), 0)
+	scheduler := newScheduler(t, redisClient, providers.ScheduleConfigs{
+		Name: "every-minute", Spec: "0 * * * * *", QueueName: "queues:test", Missed: providers.MissedCatchUp,
+	})
+	scheduler.MaxCatchUp = 5
+
+	scheduler.Tick(startTime)
+
+	if len(redisClient.pushed) != 5 {
+		t.Errorf("Expected 5 missed runs dispatched but got %v", len(redisClient.pushed))
+	}
+
+	if lastRun := redisClient.values["scheduler:every-minute:last_run"]; lastRun != "2026-01-01T09:05:00Z" {
+		t.Errorf("Expected last run of the oldest runs caught up but got %v", lastRun)
+	}
+}
+
+func TestTickUseTimeZone(t *testing.T) {
+	redisClient := newRedisClientMock()
+	scheduler := newScheduler(t, redisClient, providers.ScheduleConfigs{
+		Name: "morning", Spec: "0 0 9 * * *", Location: "America/Sao_Paulo", QueueName: "queues:test",
+	})
+
+	scheduler.Tick(startTime)
+
+	statuses, _ := scheduler.Statuses()
+	if expected := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC); !statuses[0].NextRun.Equal(expected) {
+		t.Errorf("Expected next run at %v but got %v", expected, statuses[0].NextRun)
+	}
+}
+
+func TestTickReleaseLockWhenDispatchFails(t *testing.T) {
+	redisClient := newRedisClientMock()
+	scheduler := newScheduler(t, redisClient, providers.ScheduleConfigs{Name: "error", Spec: "* * * * * *", QueueName: "queues:error"})
+
+	scheduler.Tick(startTime)
+	scheduler.Tick(startTime.Add(time.Second))
+
+	if _, ok := redisClient.values["scheduler:error:lock:"+formatUnix(startTime.Add(time.Second))]; ok {
+		t.Errorf("Expected lock released when dispatch fails")
+	}
+
+	if _, ok := redisClient.values["scheduler:error:last_run"]; ok {
+		t.Errorf("Expected last run not recorded when dispatch fails")
+	}
+}
+
+func TestParseSpecWithAndWithoutSeconds(t *testing.T) {
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	specs := map[string]time.Time{
+		"*/5 * * * *":   time.Date(2020, 1, 1, 10, 5, 0, 0, time.UTC),
+		"*/5 * * * * *": time.Date(2020, 1, 1, 10, 0, 5, 0, time.UTC),
+		"@hourly":       time.Date(2020, 1, 1, 11, 0, 0, 0, time.UTC),
+	}
+
+	for spec, expected := range specs {
+		schedule, err := parseSpec(spec)
+		if err != nil {
+			t.Fatalf("Expected spec %v parsed but got %v", spec, err)
+		}
+
+		if next := schedule.Next(start); !next.Equal(expected) {
+			t.Errorf("Expected next run of %v at %v but got %v", spec, expected, next)
+		}
+	}
+}
+
+func TestNewReturnError(t *testing.T) {
+	redisClient := newRedisClientMock()
+
+	if _, err := New([]providers.ScheduleConfigs{providers.ScheduleConfigs{Name: "invalid", Spec: "* *"}}, redisClient, nil); err == nil {
+		t.Errorf("Expected an error for invalid spec")
+	}
+
+	if _, err := New([]providers.ScheduleConfigs{providers.ScheduleConfigs{Name: "invalid", Spec: "@hourly", Location: "Mars/Olympus"}}, redisClient, nil); err == nil {
+		t.Errorf("Expected an error for invalid location")
+	}
+}
+
+func formatUnix(tick time.Time) string {
+	return strconv.FormatInt(tick.Unix(), 10)
+}
@@ -0,0 +1,382 @@
+package security
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-queue/envelopes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+//Headers of the sealed envelopes
+const (
+	KeyIDHeader          = "encryption-key-id"
+	SignatureHeader      = "signature"
+	SignatureKeyIDHeader = "signature-key-id"
+)
+
+//Field is the envelope field with the encrypted payload encoded in base64
+const Field = "encrypted"
+
+//MinSigningKeySize is the min size in bytes of the signing keys
+const MinSigningKeySize = 16
+
+var (
+	//ErrUnsigned is the error of the jobs without signature when the signing is enabled
+	ErrUnsigned = errors.New("job is not signed")
+	//ErrInvalidSignature is the error of the jobs tampered or signed with an unknown key
+	ErrInvalidSignature = errors.New("job signature is invalid")
+)
+
+//Keyring is a set of keys by ID. The envelopes are sealed with the active key and the envelopes
+//sealed with the other keys stay readable, so the keys are rotated adding a new active key
+type Keyring struct {
+	Keys   map[string][]byte
+	Active string
+}
+
+//Enabled return if the keyring has keys
+func (k Keyring) Enabled() bool {
+	return len(k.Keys) > 0
+}
+
+func (k Keyring) key(id string) ([]byte, error) {
+	key, ok := k.Keys[id]
+	if !ok {
+		return nil, fmt.Errorf("key %q is unknown", id)
+	}
+
+	return key, nil
+}
+
+//Sealer encrypts and signs the envelopes pushed to the queues, and verifies and decrypts the envelopes
+//popped. The ID, the attempts and the headers stay out of the encrypted payload and the attempts
+//are not signed, so the workers and the tools count the attempts without the keys
+type Sealer struct {
+	Encryption Keyring
+	Signing    Keyring
+}
+
+//Validate checks the active keys and the sizes of the keys
+func (s *Sealer) Validate() error {
+	if s.Encryption.Enabled() {
+		if _, err := s.Encryption.key(s.Encryption.Active); err != nil {
+			return fmt.Errorf("active encryption %v", err)
+		}
+
+		for id, key := range s.Encryption.Keys {
+			if _, err := aes.NewCipher(key); err != nil {
+				return fmt.Errorf("encryption key %q: %v", id, err)
+			}
+		}
+	}
+
+	if s.Signing.Enabled() {
+		if _, err := s.Signing.key(s.Signing.Active); err != nil {
+			return fmt.Errorf("active signing %v", err)
+		}
+
+		for id, key := range s.Signing.Keys {
+			if len(key) < MinSigningKeySize {
+				return fmt.Errorf("signing key %q is shorter than %v bytes", id, MinSigningKeySize)
+			}
+		}
+	}
+
+	return nil
+}
+
+//Seal encrypts the envelope with the active encryption key and signs it with the active signing key,
+//when the keys are configured. A nil sealer return the envelope as it is
+func (s *Sealer) Seal(data string) (string, error) {
+	if s == nil || (!s.Encryption.Enabled() && !s.Signing.Enabled()) {
+		return data, nil
+	}
+
+	envelope, err := envelopes.Decode(data)
+	if err != nil {
+		return "", err
+	}
+
+	if s.Encryption.Enabled() {
+		if _, encrypted := envelope[Field]; !encrypted {
+			envelope, err = s.encrypt(envelope, data)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if s.Signing.Enabled() {
+		signature, err := s.sign(envelope, s.Signing.Active)
+		if err != nil {
+			return "", err
+		}
+
+		headers := envelopes.Headers(envelope)
+		headers[SignatureHeader] = signature
+		headers[SignatureKeyIDHeader] = s.Signing.Active
+		envelope["headers"] = headers
+	}
+
+	encoded, err := json.Marshal(envelope)
+	return string(encoded), err
+}
+
+//Open verifies the signature of the envelope, required when the signing keys are configured,
+//and decrypts it. The envelopes not encrypted are returned as they are
+func (s *Sealer) Open(data string) (string, error) {
+	if s == nil {
+		s = &Sealer{}
+	}
+
+	if !s.Signing.Enabled() && !strings.Contains(data, KeyIDHeader) {
+		return data, nil
+	}
+
+	envelope, err := envelopes.Decode(data)
+	if err != nil {
+		return "", err
+	}
+
+	headers := envelopes.Headers(envelope)
+	if s.Signing.Enabled() {
+		if err := s.verify(envelope, headers); err != nil {
+			return "", err
+		}
+	}
+
+	keyID, encrypted := headers[KeyIDHeader].(string)
+	if !encrypted {
+		return data, nil
+	}
+
+	return s.decrypt(envelope, keyID)
+}
+
+func (s *Sealer) encrypt(envelope map[string]interface{}, data string) (map[string]interface{}, error) {
+	key, err := s.Encryption.key(s.Encryption.Active)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(data), []byte(s.Encryption.Active))
+
+	headers := envelopes.Headers(envelope)
+	headers[KeyIDHeader] = s.Encryption.Active
+
+	return map[string]interface{}{
+		"id":       envelope["id"],
+		"attempts": envelope["attempts"],
+		"headers":  headers,
+		Field:      base64.StdEncoding.EncodeToString(sealed),
+	}, nil
+}
+
+func (s *Sealer) decrypt(wrapped map[string]interface{}, keyID string) (string, error) {
+	if !s.Encryption.Enabled() {
+		return "", errors.New("job is encrypted and there are no encryption keys")
+	}
+
+	key, err := s.Encryption.key(keyID)
+	if err != nil {
+		return "", fmt.Errorf("encryption %v", err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	encoded, _ := wrapped[Field].(string)
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < gcm.NonceSize() {
+		return "", errors.New("encrypted payload is malformed")
+	}
+
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	decrypted, err := gcm.Open(nil, nonce, ciphertext, []byte(keyID))
+	if err != nil {
+		return "", fmt.Errorf("encrypted payload can not be decrypted: %v", err)
+	}
+
+	envelope, err := envelopes.Decode(string(decrypted))
+	if err != nil {
+		return "", err
+	}
+
+	if attempts, ok := wrapped["attempts"]; ok && fmt.Sprint(attempts) != fmt.Sprint(envelope["attempts"]) {
+		envelope["attempts"] = attempts
+		decrypted, err = json.Marshal(envelope)
+	}
+
+	return string(decrypted), err
+}
+
+func (s *Sealer) verify(envelope map[string]interface{}, headers map[string]interface{}) error {
+	signature, ok := headers[SignatureHeader].(string)
+	if !ok || signature == "" {
+		return ErrUnsigned
+	}
+
+	keyID, _ := headers[SignatureKeyIDHeader].(string)
+	if _, err := s.Signing.key(keyID); err != nil {
+		return fmt.Errorf("%v: signing %v", ErrInvalidSignature, err)
+	}
+
+	expected, err := s.sign(envelope, keyID)
+	if err != nil {
+		return err
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+//sign return the HMAC-SHA256 of the envelope without the attempts and the signature headers
+func (s *Sealer) sign(envelope map[string]interface{}, keyID string) (string, error) {
+	key, err := s.Signing.key(keyID)
+	if err != nil {
+		return "", err
+	}
+
+	signed := make(map[string]interface{})
+	for field, value := range envelope {
+		signed[field] = value
+	}
+	delete(signed, "attempts")
+
+	headers := envelopes.Headers(envelope)
+	delete(headers, SignatureHeader)
+	delete(headers, SignatureKeyIDHeader)
+	delete(signed, "headers")
+	if len(headers) > 0 {
+		signed["headers"] = headers
+	}
+
+	canonical, err := json.Marshal(signed)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(canonical)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+//SealerFromEnv return the sealer with the keys of the env, nil without keys. The keys are read from
+//ENCRYPTION_KEYS and SIGNING_KEYS, "id:base64key" separated by commas, or from the files of
+//ENCRYPTION_KEYS_DIR and SIGNING_KEYS_DIR, named by the key ID with the base64 key. The active keys are
+//ENCRYPTION_KEY_ID and SIGNING_KEY_ID, optional with one key
+func SealerFromEnv(env map[string]string) (*Sealer, error) {
+	encryption, err := KeyringFromEnv(env, "ENCRYPTION")
+	if err != nil {
+		return nil, err
+	}
+
+	signing, err := KeyringFromEnv(env, "SIGNING")
+	if err != nil {
+		return nil, err
+	}
+
+	if !encryption.Enabled() && !signing.Enabled() {
+		return nil, nil
+	}
+
+	sealer := &Sealer{Encryption: encryption, Signing: signing}
+	return sealer, sealer.Validate()
+}
+
+//KeyringFromEnv return the keyring of the env variables with the prefix
+func KeyringFromEnv(env map[string]string, prefix string) (Keyring, error) {
+	keyring := Keyring{Keys: make(map[string][]byte), Active: env[prefix+"_KEY_ID"]}
+
+	for _, pair := range strings.Split(env[prefix+"_KEYS"], ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 {
+			return keyring, fmt.Errorf("%v_KEYS must be id:base64key pairs", prefix)
+		}
+
+		if err := keyring.add(parts[0], parts[1]); err != nil {
+			return keyring, err
+		}
+	}
+
+	if dir := env[prefix+"_KEYS_DIR"]; dir != "" {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return keyring, err
+		}
+
+		for _, file := range files {
+			if file.IsDir() {
+				continue
+			}
+
+			content, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+			if err != nil {
+				return keyring, err
+			}
+
+			id := strings.TrimSuffix(file.Name(), filepath.Ext(file.Name()))
+			if err := keyring.add(id, string(content)); err != nil {
+				return keyring, err
+			}
+		}
+	}
+
+	if keyring.Active == "" && len(keyring.Keys) == 1 {
+		for id := range keyring.Keys {
+			keyring.Active = id
+		}
+	}
+
+	return keyring, nil
+}
+
+func (k Keyring) add(id string, encoded string) error {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return fmt.Errorf("key %q is not base64: %v", id, err)
+	}
+
+	k.Keys[strings.TrimSpace(id)] = key
+	return nil
+}
@@ -0,0 +1,156 @@
+package security
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var envelope = `{"attempts":0,"headers":{"traceparent":"00-trace"},"id":"job","name":"Ana","amount":12345678901}`
+
+func newSealer() *Sealer {
+	return &Sealer{
+		Encryption: Keyring{Keys: map[string][]byte{"2020": []byte(strings.Repeat("a", 32)), "2021": []byte(strings.Repeat("b", 32))}, Active: "2021"},
+		Signing:    Keyring{Keys: map[string][]byte{"main": []byte(strings.Repeat("s", 32))}, Active: "main"},
+	}
+}
+
+func decodeHeaders(data string) map[string]interface{} {
+	decoded := make(map[string]interface{})
+	json.Unmarshal([]byte(data), &decoded)
+	headers, _ := decoded["headers"].(map[string]interface{})
+	return headers
+}
+
+//------------------------------ TESTS ---------------------------------
+func TestSealAndOpen(t *testing.T) {
+	sealer := newSealer()
+
+	sealed, err := sealer.Seal(envelope)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	headers := decodeHeaders(sealed)
+	if strings.Contains(sealed, "Ana") || headers[KeyIDHeader] != "2021" || headers[SignatureKeyIDHeader] != "main" || headers["traceparent"] != "00-trace" {
+		t.Errorf("Expected envelope encrypted with the active key and signed but got %v", sealed)
+	}
+
+	opened, err := sealer.Open(sealed)
+	if err != nil || opened != envelope {
+		t.Errorf("Expected envelope opened but got %v %v", opened, err)
+	}
+}
+
+func TestOpenWithRotatedKeys(t *testing.T) {
+	old := newSealer()
+	old.Encryption.Active = "2020"
+	sealed, _ := old.Seal(envelope)
+
+	if opened, err := newSealer().Open(sealed); err != nil || opened != envelope {
+		t.Errorf("Expected envelope of the previous key opened but got %v %v", opened, err)
+	}
+
+	removed := newSealer()
+	delete(removed.Encryption.Keys, "2020")
+	if _, err := removed.Open(sealed); err == nil {
+		t.Errorf("Expected an error for the key removed")
+	}
+}
+
+func TestOpenKeepAttemptsOutOfSignature(t *testing.T) {
+	sealer := newSealer()
+	sealed, _ := sealer.Seal(envelope)
+	retried := strings.Replace(sealed, `"attempts":0`, `"attempts":2`, 1)
+
+	opened, err := sealer.Open(retried)
+	if err != nil || !strings.Contains(opened, `"attempts":2`) {
+		t.Errorf("Expected attempts updated without the keys but got %v %v", opened, err)
+	}
+}
+
+func TestOpenRejectUnsignedAndTampered(t *testing.T) {
+	sealer := &Sealer{Signing: newSealer().Signing}
+	signed, _ := sealer.Seal(envelope)
+
+	if opened, err := sealer.Open(signed); err != nil || !strings.Contains(opened, "Ana") {
+		t.Fatalf("Expected signed envelope opened but got %v %v", opened, err)
+	}
+
+	if _, err := sealer.Open(envelope); err != ErrUnsigned {
+		t.Errorf("Expected unsigned error but got %v", err)
+	}
+
+	tampered := strings.Replace(signed, "Ana", "Eve", 1)
+	if _, err := sealer.Open(tampered); err != ErrInvalidSignature {
+		t.Errorf("Expected invalid signature error but got %v", err)
+	}
+
+	other := &Sealer{Signing: Keyring{Keys: map[string][]byte{"other": []byte(strings.Repeat("o", 32))}, Active: "other"}}
+	if _, err := other.Open(signed); err == nil || !strings.HasPrefix(err.Error(), ErrInvalidSignature.Error()) {
+		t.Errorf("Expected invalid signature error for unknown key but got %v", err)
+	}
+}
+
+func TestOpenEncryptedWithoutKeys(t *testing.T) {
+	sealed, _ := (&Sealer{Encryption: newSealer().Encryption}).Seal(envelope)
+
+	var sealer *Sealer
+	if _, err := sealer.Open(sealed); err == nil {
+		t.Errorf("Expected an error for encrypted envelope without keys")
+	}
+
+	if opened, err := sealer.Open(envelope); err != nil || opened != envelope {
+		t.Errorf("Expected envelope not sealed returned as it is but got %v %v", opened, err)
+	}
+}
+
+func TestSealerFromEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ioutil.WriteFile(filepath.Join(dir, "main.key"), []byte(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("s", 32)))+"\n"), 0600)
+
+	env := map[string]string{
+		"ENCRYPTION_KEYS":   "2020:" + base64.StdEncoding.EncodeToString([]byte(strings.Repeat("a", 32))) + ",2021:" + base64.StdEncoding.EncodeToString([]byte(strings.Repeat("b", 16))),
+		"ENCRYPTION_KEY_ID": "2021",
+		"SIGNING_KEYS_DIR":  dir,
+	}
+
+	sealer, err := SealerFromEnv(env)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	if len(sealer.Encryption.Keys) != 2 || sealer.Encryption.Active != "2021" || sealer.Signing.Active != "main" {
+		t.Errorf("Expected keys read from the env and the files but got %v", sealer)
+	}
+
+	if sealer, err := SealerFromEnv(map[string]string{}); sealer != nil || err != nil {
+		t.Errorf("Expected no sealer without keys but got %v %v", sealer, err)
+	}
+}
+
+func TestSealerFromEnvReturnError(t *testing.T) {
+	invalid := []map[string]string{
+		{"ENCRYPTION_KEYS": "2020"},
+		{"ENCRYPTION_KEYS": "2020:not base64"},
+		{"ENCRYPTION_KEYS": "2020:" + base64.StdEncoding.EncodeToString([]byte("short"))},
+		{"ENCRYPTION_KEYS": "2020:" + base64.StdEncoding.EncodeToString([]byte(strings.Repeat("a", 32))), "ENCRYPTION_KEY_ID": "2021"},
+		{"SIGNING_KEYS": "main:" + base64.StdEncoding.EncodeToString([]byte("short"))},
+		{"SIGNING_KEYS_DIR": "/not/found"},
+	}
+
+	for _, env := range invalid {
+		if _, err := SealerFromEnv(env); err == nil {
+			t.Errorf("Expected an error for %v", env)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package tracing
+
+import (
+	"context"
+	"go-queue/dispatcher"
+	"go-queue/events"
+	"go-queue/providers"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "go-queue"
+
+//Tracing creates the jobs spans and propagates the trace context in the jobs envelopes
+type Tracing struct {
+	Tracer     trace.Tracer
+	Propagator propagation.TextMapPropagator
+}
+
+//New return a Tracing with the tracer of the provider and the W3C trace context propagator
+func New(provider trace.TracerProvider) *Tracing {
+	return &Tracing{
+		Tracer:     provider.Tracer(tracerName),
+		Propagator: propagation.TraceContext{},
+	}
+}
+
+//Inject is a dispatcher hook that writes the trace context of ctx in the envelope headers
+func (t *Tracing) Inject(ctx context.Context, envelope map[string]interface{}) error {
+	carrier := propagation.MapCarrier(dispatcher.Headers(envelope))
+	t.Propagator.Inject(ctx, carrier)
+
+	if len(carrier) > 0 {
+		envelope["headers"] = map[string]string(carrier)
+	}
+
+	return nil
+}
+
+//Middleware starts the consumer span of the job linked to the producer span, in its own trace.
+//The span is passed to the next handlers in the job context and ended by the events of Subscribe
+func (t *Tracing) Middleware(next providers.HandlerFunc) providers.HandlerFunc {
+	return func(job *providers.JobContext) error {
+		carrier := propagation.MapCarrier(dispatcher.Headers(job.Payload))
+		producerCtx := t.Propagator.Extract(job.Context, carrier)
+
+		options := []trace.SpanStartOption{
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(
+				attribute.String("messaging.system", "redis"),
+				attribute.String("messaging.destination.name", job.QueueName),
+				attribute.Float64("messaging.attempt", job.Attempt),
+			),
+		}
+
+		if producer := trace.SpanContextFromContext(producerCtx); producer.IsValid() {
+			options = append(options, trace.WithLinks(trace.Link{SpanContext: producer}))
+		}
+
+		if id, ok := job.Payload["id"].(string); ok {
+			options = append(options, trace.WithAttributes(attribute.String("messaging.message.id", id)))
+		}
+
+		if job.JobType != "" {
+			options = append(options, trace.WithAttributes(attribute.String("messaging.job.type", job.JobType)))
+		}
+
+		ctx, span := t.Tracer.Start(job.Context, "process "+job.QueueName, options...)
+		job.Context = ctx
+
+		err := next(job)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+
+		return err
+	}
+}
+
+//Subscribe records the end of the jobs in their spans
+func (t *Tracing) Subscribe(bus *events.Bus) {
+	bus.Subscribe(events.AllEvents, t.HandleEvent)
+}
+
+//HandleEvent records the event in the span of the job, ending it when the job is done
+func (t *Tracing) HandleEvent(event events.Event) {
+	switch e := event.(type) {
+	case events.JobProcessed:
+		endSpan(e.Context, "job.processed", e.Attempt)
+	case events.JobRetrying:
+		endSpan(e.Context, "job.retrying", e.Attempt)
+	case events.JobFailed:
+		endSpan(e.Context, "job.failed", e.Attempt)
+	case events.JobCancelled:
+		endSpan(e.Context, "job.cancelled", e.Attempt)
+	}
+}
+
+func endSpan(ctx context.Context, name string, attempt float64) {
+	if ctx == nil {
+		return
+	}
+
+	span := trace.SpanFromContext(ctx)
+	span.AddEvent(name, trace.WithAttributes(attribute.Float64("messaging.attempt", attempt)))
+	span.End()
+}
@@ -0,0 +1,196 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-queue/dispatcher"
+	"go-queue/events"
+	"go-queue/providers"
+	"testing"
+
+	"github.com/go-redis/redis"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+//------------------------- REDIS MOCK ------------------------
+type redisClientMock struct {
+	pushed string
+}
+
+func (r *redisClientMock) RPush(key string, values ...interface{}) *redis.IntCmd {
+	r.pushed = values[0].(string)
+	return redis.NewIntResult(1, nil)
+}
+
+//------------------------- HELPERS ---------------------------
+func dispatchTraced(t *testing.T, tracing *Tracing) (map[string]interface{}, trace.SpanContext) {
+	ctx, producer := tracing.Tracer.Start(context.Background(), "api request")
+	defer producer.End()
+
+	redisMock := &redisClientMock{}
+	d := dispatcher.Dispatcher{Client: redisMock, Hooks: []dispatcher.EnvelopeHook{tracing.Inject}}
+	if _, err := d.Dispatch(ctx, "queues:test", map[string]interface{}{}); err != nil {
+		t.Fatalf("Error to dispatch job %v", err)
+	}
+
+	payload := make(map[string]interface{})
+	json.Unmarshal([]byte(redisMock.pushed), &payload)
+
+	return payload, producer.SpanContext()
+}
+
+func findSpan(spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	for _, span := range spans {
+		if span.Name() == name {
+			return span
+		}
+	}
+
+	return nil
+}
+
+//------------------------------ TESTS ---------------------------------
+func TestConsumerSpanIsLinkedToProducer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracing := New(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	bus := events.NewBus()
+	tracing.Subscribe(bus)
+
+	payload, producer := dispatchTraced(t, tracing)
+
+	var handlerSpan trace.SpanContext
+	next := func(job *providers.JobContext) error {
+		handlerSpan = trace.SpanContextFromContext(job.Context)
+		return nil
+	}
+
+	job := &providers.JobContext{Context: context.Background(), QueueName: "queues:test", Payload: payload, Attempt: 1}
+	if err := tracing.Middleware(next)(job); err != nil {
+		t.Fatalf("Expected error is nil but got %v", err)
+	}
+
+	bus.Emit(events.JobProcessed{Job: events.Job{Context: job.Context, Attempt: 1}})
+
+	consumer := findSpan(recorder.Ended(), "process queues:test")
+	if consumer == nil {
+		t.Fatalf("Expected consumer span ended")
+	}
+
+	if consumer.Parent().IsValid() || consumer.SpanContext().TraceID() == producer.TraceID() {
+		t.Errorf("Expected consumer span in its own trace but got parent %v", consumer.Parent())
+	}
+
+	if len(consumer.Links()) != 1 || consumer.Links()[0].SpanContext.SpanID() != producer.SpanID() {
+		t.Errorf("Expected consumer span linked to the producer span but got %v", consumer.Links())
+	}
+
+	if handlerSpan.SpanID() != consumer.SpanContext().SpanID() {
+		t.Errorf("Expected handler to receive the consumer span context")
+	}
+
+	if consumer.SpanKind() != trace.SpanKindConsumer {
+		t.Errorf("Expected consumer span kind but got %v", consumer.SpanKind())
+	}
+
+	if len(consumer.Events()) != 1 || consumer.Events()[0].Name != "job.processed" {
+		t.Errorf("Expected job.processed event but got %v", consumer.Events())
+	}
+}
+
+func TestConsumerSpanRecordRetryAndFailure(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracing := New(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	bus := events.NewBus()
+	tracing.Subscribe(bus)
+
+	next := func(job *providers.JobContext) error {
+		return errors.New("Test")
+	}
+
+	first := &providers.JobContext{Context: context.Background(), QueueName: "queues:test", Payload: map[string]interface{}{}, Attempt: 1}
+	tracing.Middleware(next)(first)
+	bus.Emit(events.JobRetrying{Job: events.Job{Context: first.Context, Attempt: 1}})
+
+	second := &providers.JobContext{Context: context.Background(), QueueName: "queues:test", Payload: map[string]interface{}{}, Attempt: 2}
+	tracing.Middleware(next)(second)
+	bus.Emit(events.JobFailed{Job: events.Job{Context: second.Context, Attempt: 2}})
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans ended but got %v", len(spans))
+	}
+
+	expected := []string{"job.retrying", "job.failed"}
+	for i, span := range spans {
+		if span.Status().Code != codes.Error {
+			t.Errorf("Expected span with error status but got %v", span.Status())
+		}
+
+		names := []string{}
+		for _, event := range span.Events() {
+			names = append(names, event.Name)
+		}
+
+		if len(names) != 2 || names[0] != "exception" || names[1] != expected[i] {
+			t.Errorf("Expected exception and %v events but got %v", expected[i], names)
+		}
+	}
+}
+
+func TestConsumerSpanEndedWhenCancelled(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracing := New(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	bus := events.NewBus()
+	tracing.Subscribe(bus)
+
+	next := func(job *providers.JobContext) error {
+		return context.Canceled
+	}
+
+	job := &providers.JobContext{Context: context.Background(), QueueName: "queues:test", Payload: map[string]interface{}{}, Attempt: 1}
+	tracing.Middleware(next)(job)
+	bus.Emit(events.JobCancelled{Job: events.Job{Context: job.Context, Attempt: 1}, Running: true})
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span ended but got %v", len(spans))
+	}
+
+	spanEvents := spans[0].Events()
+	if len(spanEvents) == 0 || spanEvents[len(spanEvents)-1].Name != "job.cancelled" {
+		t.Errorf("Expected job.cancelled event but got %v", spanEvents)
+	}
+}
+
+func TestInjectWithoutSpanDoNotAddHeaders(t *testing.T) {
+	tracing := New(trace.NewNoopTracerProvider())
+	envelope := map[string]interface{}{}
+
+	tracing.Inject(context.Background(), envelope)
+
+	if _, ok := envelope["headers"]; ok {
+		t.Errorf("Expected no headers without an active span but got %v", envelope)
+	}
+}
+
+func TestMiddlewareWithNoopProvider(t *testing.T) {
+	tracing := New(trace.NewNoopTracerProvider())
+
+	called := false
+	next := func(job *providers.JobContext) error {
+		called = true
+		return nil
+	}
+
+	job := &providers.JobContext{Context: context.Background(), Payload: map[string]interface{}{}}
+	tracing.Middleware(next)(job)
+	tracing.HandleEvent(events.JobProcessed{Job: events.Job{Context: job.Context}})
+
+	if !called {
+		t.Errorf("Expected next handler to be called")
+	}
+}
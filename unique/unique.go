@@ -0,0 +1,212 @@
+package unique
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-queue/dispatcher"
+	"go-queue/events"
+	"go-queue/logger"
+	"go-queue/providers"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+//Moments the lock of a unique job is released
+const (
+	UntilProcessing = "processing"
+	UntilProcessed  = "processed"
+)
+
+//Actions for a job dispatched while a duplicate holds the lock
+const (
+	OnDuplicateReject   = "reject"
+	OnDuplicateCollapse = "collapse"
+)
+
+//DefaultTTL is the lock duration when no TTL is configured
+const DefaultTTL = time.Hour
+
+const keyPrefix = "unique:"
+
+//lockAttempts is the times the lock is tried when it expires before its holder is read
+const lockAttempts = 3
+
+//releaseScript deletes the lock only when it is still held by the job
+const releaseScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end return 0`
+
+//ErrDuplicate is returned when a duplicate job is rejected
+var ErrDuplicate = errors.New("duplicate job")
+
+//Configs of the uniqueness of a job. The key is derived from the payload fields, nested fields
+//separated by dots, or from the whole payload without fields. By default the lock is held until
+//the job is processed and the duplicates are rejected
+type Configs struct {
+	Fields      []string
+	TTL         time.Duration
+	Until       string
+	OnDuplicate string
+}
+
+//RedisInterface is the redis client used to lock the unique jobs
+type RedisInterface interface {
+	SetNX(key string, value interface{}, expiration time.Duration) *redis.BoolCmd
+	Get(key string) *redis.StringCmd
+	Eval(script string, keys []string, args ...interface{}) *redis.Cmd
+}
+
+//Dispatcher push the jobs locking them by the unique key
+type Dispatcher struct {
+	Dispatcher *dispatcher.Dispatcher
+	Redis      RedisInterface
+	Logger     logger.Logger
+}
+
+//Dispatch push the payload when no duplicate holds the lock of its key and return the job ID.
+//A duplicate is rejected with ErrDuplicate or collapsed returning the ID of the job holding the lock
+func (d *Dispatcher) Dispatch(ctx context.Context, queueName string, payload map[string]interface{}, configs Configs) (string, error) {
+	envelope := dispatcher.NewEnvelope(payload)
+	id := envelope["id"].(string)
+	key := Key(queueName, payload, configs.Fields)
+
+	holder, err := lock(d.Redis, key, id, ttl(configs))
+	if err != nil {
+		return "", err
+	}
+
+	if holder != "" {
+		logger.OrDefault(d.Logger).WithFields(logger.Fields{"queue": queueName, "job_id": holder}).Infof("Duplicate job not dispatched")
+		if configs.OnDuplicate == OnDuplicateCollapse {
+			return holder, nil
+		}
+
+		return "", ErrDuplicate
+	}
+
+	until := configs.Until
+	if until == "" {
+		until = UntilProcessed
+	}
+	envelope["unique"] = map[string]interface{}{"key": key, "until": until}
+
+	id, err = d.Dispatcher.Dispatch(ctx, queueName, envelope)
+	if err != nil {
+		release(d.Redis, key, envelope["id"].(string))
+		return "", err
+	}
+
+	return id, nil
+}
+
+//Key return the lock key of the payload in the queue
+func Key(queueName string, payload map[string]interface{}, fields []string) string {
+	var values interface{}
+	if len(fields) == 0 {
+		content := make(map[string]interface{})
+		for field, value := range payload {
+			switch field {
+			case "id", "attempts", "headers", "unique":
+			default:
+				content[field] = value
+			}
+		}
+		values = content
+	} else {
+		var fieldsValues []interface{}
+		for _, field := range fields {
+			fieldsValues = append(fieldsValues, providers.FieldValue(payload, field))
+		}
+		values = fieldsValues
+	}
+
+	marshaledValues, _ := json.Marshal(values)
+	digest := sha1.Sum(marshaledValues)
+
+	return keyPrefix + queueName + ":" + hex.EncodeToString(digest[:])
+}
+
+//Releaser releases the locks of the unique jobs on the events of the jobs
+type Releaser struct {
+	Redis  RedisInterface
+	Logger logger.Logger
+}
+
+//Subscribe releases the locks with the events of the bus
+func (r *Releaser) Subscribe(bus *events.Bus) {
+	bus.Subscribe(events.AllEvents, r.HandleEvent)
+}
+
+//HandleEvent releases the lock of the job when it starts processing or when it is processed,
+//by the mode of the job, and when it fails into failed_jobs or it is cancelled
+func (r *Releaser) HandleEvent(event events.Event) {
+	switch e := event.(type) {
+	case events.JobProcessing:
+		r.release(e.Job, UntilProcessing)
+	case events.JobProcessed:
+		r.release(e.Job, UntilProcessed)
+	case events.JobFailed:
+		r.release(e.Job, "")
+	case events.JobUnrouted:
+		r.release(e.Job, "")
+	case events.JobCancelled:
+		r.release(e.Job, "")
+	}
+}
+
+func (r *Releaser) release(job events.Job, until string) {
+	lock, ok := job.Payload["unique"].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	key, _ := lock["key"].(string)
+	if until != "" && lock["until"] != until {
+		return
+	}
+
+	err := release(r.Redis, key, job.JobID)
+	if err != nil {
+		logger.OrDefault(r.Logger).WithFields(logger.Fields{"queue": job.QueueName, "job_id": job.JobID}).Errorf("Error to release unique lock: %v", err)
+	}
+}
+
+//lock acquires the lock of the key for the job and return the ID of the job holding it, empty when it is
+//acquired. The lock is tried again when it expires between the SETNX and the GET, up to lockAttempts times
+func lock(redisClient RedisInterface, key string, id string, ttl time.Duration) (string, error) {
+	for attempt := 1; ; attempt++ {
+		acquired, err := redisClient.SetNX(key, id, ttl).Result()
+		if err != nil || acquired {
+			return "", err
+		}
+
+		holder, err := redisClient.Get(key).Result()
+		if err != redis.Nil {
+			return holder, err
+		}
+
+		if attempt == lockAttempts {
+			return "", fmt.Errorf("unique lock %v not acquired after %v attempts", key, lockAttempts)
+		}
+	}
+}
+
+func release(redisClient RedisInterface, key string, id string) error {
+	err := redisClient.Eval(releaseScript, []string{key}, id).Err()
+	if err == redis.Nil {
+		return nil
+	}
+
+	return err
+}
+
+func ttl(configs Configs) time.Duration {
+	if configs.TTL <= 0 {
+		return DefaultTTL
+	}
+
+	return configs.TTL
+}
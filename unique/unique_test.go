@@ -0,0 +1,222 @@
+package unique
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-queue/dispatcher"
+	"go-queue/events"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+//------------------------- REDIS MOCK ------------------------
+type redisClientMock struct {
+	values map[string]string
+	pushed []string
+}
+
+func newRedisClientMock() *redisClientMock {
+	return &redisClientMock{values: make(map[string]string)}
+}
+
+func (r *redisClientMock) SetNX(key string, value interface{}, expiration time.Duration) *redis.BoolCmd {
+	if _, ok := r.values[key]; ok {
+		return redis.NewBoolResult(false, nil)
+	}
+
+	r.values[key] = value.(string)
+	return redis.NewBoolResult(true, nil)
+}
+
+func (r *redisClientMock) Get(key string) *redis.StringCmd {
+	value, ok := r.values[key]
+	if !ok {
+		return redis.NewStringResult("", redis.Nil)
+	}
+
+	return redis.NewStringResult(value, nil)
+}
+
+func (r *redisClientMock) Eval(script string, keys []string, args ...interface{}) *redis.Cmd {
+	if r.values[keys[0]] == args[0].(string) {
+		delete(r.values, keys[0])
+		return redis.NewCmdResult(int64(1), nil)
+	}
+
+	return redis.NewCmdResult(int64(0), nil)
+}
+
+func (r *redisClientMock) RPush(key string, values ...interface{}) *redis.IntCmd {
+	if key == "queues:error" {
+		return redis.NewIntResult(0, errors.New("RPush"))
+	}
+
+	r.pushed = append(r.pushed, values[0].(string))
+	return redis.NewIntResult(1, nil)
+}
+
+//expiringRedisMock holds the lock on SETNX and expires it before the GET the expirations times
+type expiringRedisMock struct {
+	*redisClientMock
+	expirations int
+	setNX       int
+}
+
+func (r *expiringRedisMock) SetNX(key string, value interface{}, expiration time.Duration) *redis.BoolCmd {
+	r.setNX++
+	if r.expirations > 0 {
+		r.expirations--
+		return redis.NewBoolResult(false, nil)
+	}
+
+	return r.redisClientMock.SetNX(key, value, expiration)
+}
+
+func newDispatcher(redisClient *redisClientMock) *Dispatcher {
+	return &Dispatcher{Dispatcher: &dispatcher.Dispatcher{Client: redisClient}, Redis: redisClient}
+}
+
+func reindexPayload() map[string]interface{} {
+	return map[string]interface{}{"job": "reindex", "data": map[string]interface{}{"user": float64(42)}}
+}
+
+//------------------------------ TESTS ---------------------------------
+func TestDispatchRejectDuplicate(t *testing.T) {
+	redisClient := newRedisClientMock()
+	uniqueDispatcher := newDispatcher(redisClient)
+	configs := Configs{Fields: []string{"job", "data.user"}}
+
+	id, err := uniqueDispatcher.Dispatch(context.Background(), "queues:test", reindexPayload(), configs)
+	if err != nil || id == "" {
+		t.Fatalf("Expected job dispatched but got %v", err)
+	}
+
+	_, err = uniqueDispatcher.Dispatch(context.Background(), "queues:test", reindexPayload(), configs)
+	if err != ErrDuplicate {
+		t.Errorf("Expected duplicate rejected but got %v", err)
+	}
+
+	if len(redisClient.pushed) != 1 {
+		t.Errorf("Expected 1 job pushed but got %v", len(redisClient.pushed))
+	}
+
+	envelope := make(map[string]interface{})
+	json.Unmarshal([]byte(redisClient.pushed[0]), &envelope)
+	lock := envelope["unique"].(map[string]interface{})
+	if lock["key"] != Key("queues:test", reindexPayload(), configs.Fields) || lock["until"] != UntilProcessed {
+		t.Errorf("Expected unique lock in the envelope but got %v", lock)
+	}
+}
+
+func TestDispatchCollapseDuplicate(t *testing.T) {
+	redisClient := newRedisClientMock()
+	uniqueDispatcher := newDispatcher(redisClient)
+	configs := Configs{OnDuplicate: OnDuplicateCollapse}
+
+	id, _ := uniqueDispatcher.Dispatch(context.Background(), "queues:test", reindexPayload(), configs)
+	duplicateID, err := uniqueDispatcher.Dispatch(context.Background(), "queues:test", reindexPayload(), configs)
+
+	if err != nil || duplicateID != id {
+		t.Errorf("Expected duplicate collapsed into job %v but got %v %v", id, duplicateID, err)
+	}
+}
+
+func TestDispatchReleaseLockWhenPushFails(t *testing.T) {
+	redisClient := newRedisClientMock()
+	uniqueDispatcher := newDispatcher(redisClient)
+
+	_, err := uniqueDispatcher.Dispatch(context.Background(), "queues:error", reindexPayload(), Configs{})
+	if err == nil {
+		t.Fatalf("Expected an error but got nil")
+	}
+
+	if len(redisClient.values) != 0 {
+		t.Errorf("Expected lock released but got %v", redisClient.values)
+	}
+}
+
+func TestDispatchRetryLockExpiredBeforeRead(t *testing.T) {
+	redisClient := &expiringRedisMock{redisClientMock: newRedisClientMock(), expirations: 1}
+	uniqueDispatcher := &Dispatcher{Dispatcher: &dispatcher.Dispatcher{Client: redisClient}, Redis: redisClient}
+
+	id, err := uniqueDispatcher.Dispatch(context.Background(), "queues:test", reindexPayload(), Configs{})
+	if err != nil || id == "" || redisClient.setNX != 2 || len(redisClient.pushed) != 1 {
+		t.Errorf("Expected job dispatched on the second attempt but got %v %v after %v attempts", id, err, redisClient.setNX)
+	}
+
+	redisClient = &expiringRedisMock{redisClientMock: newRedisClientMock(), expirations: 10}
+	uniqueDispatcher = &Dispatcher{Dispatcher: &dispatcher.Dispatcher{Client: redisClient}, Redis: redisClient}
+
+	_, err = uniqueDispatcher.Dispatch(context.Background(), "queues:test", reindexPayload(), Configs{})
+	if err == nil || redisClient.setNX != lockAttempts || len(redisClient.pushed) != 0 {
+		t.Errorf("Expected error after %v attempts but got %v after %v attempts", lockAttempts, err, redisClient.setNX)
+	}
+}
+
+func TestKeyIgnoreEnvelopeFields(t *testing.T) {
+	payload := reindexPayload()
+	envelope := dispatcher.NewEnvelope(payload)
+	envelope["attempts"] = float64(2)
+
+	if Key("queues:test", payload, nil) != Key("queues:test", envelope, nil) {
+		t.Errorf("Expected same key for the payload and its envelope")
+	}
+
+	if Key("queues:test", payload, nil) == Key("queues:other", payload, nil) {
+		t.Errorf("Expected keys by queue")
+	}
+}
+
+func TestReleaserReleaseByMode(t *testing.T) {
+	redisClient := newRedisClientMock()
+	releaser := &Releaser{Redis: redisClient}
+	bus := events.NewBus()
+	releaser.Subscribe(bus)
+
+	job := func(key string, until string) events.Job {
+		redisClient.values[key] = "job-" + key
+		return events.Job{JobID: "job-" + key, Payload: map[string]interface{}{"unique": map[string]interface{}{"key": key, "until": until}}}
+	}
+
+	processing := job("processing", UntilProcessing)
+	processed := job("processed", UntilProcessed)
+	failed := job("failed", UntilProcessed)
+
+	bus.Emit(events.JobProcessing{Job: processing})
+	bus.Emit(events.JobProcessing{Job: processed})
+	if _, ok := redisClient.values["processing"]; ok {
+		t.Errorf("Expected lock released when the job starts processing")
+	}
+
+	if _, ok := redisClient.values["processed"]; !ok {
+		t.Errorf("Expected lock held while the job is processing")
+	}
+
+	bus.Emit(events.JobRetrying{Job: failed})
+	if _, ok := redisClient.values["failed"]; !ok {
+		t.Errorf("Expected lock held while the job is retried")
+	}
+
+	bus.Emit(events.JobProcessed{Job: processed})
+	bus.Emit(events.JobFailed{Job: failed})
+	if len(redisClient.values) != 0 {
+		t.Errorf("Expected locks released but got %v", redisClient.values)
+	}
+}
+
+func TestReleaserKeepLockOfAnotherJob(t *testing.T) {
+	redisClient := newRedisClientMock()
+	redisClient.values["key"] = "newer"
+	releaser := &Releaser{Redis: redisClient}
+
+	releaser.HandleEvent(events.JobFailed{Job: events.Job{JobID: "older", Payload: map[string]interface{}{
+		"unique": map[string]interface{}{"key": "key", "until": UntilProcessed},
+	}}})
+
+	if redisClient.values["key"] != "newer" {
+		t.Errorf("Expected lock of the newer job kept")
+	}
+}
@@ -0,0 +1,301 @@
+package worker
+
+import (
+	"fmt"
+	"go-queue/breaker"
+	"go-queue/claimcheck"
+	"go-queue/dispatcher"
+	"go-queue/events"
+	"go-queue/health"
+	listener "go-queue/listeners"
+	"go-queue/logger"
+	connectionsmanager "go-queue/managers/connectionsManager"
+	"go-queue/managers/jobsManager"
+	"go-queue/managers/listenersManager"
+	"go-queue/metrics"
+	"go-queue/providers"
+	"go-queue/results"
+	"go-queue/scheduler"
+	"go-queue/security"
+	"go-queue/tracing"
+	"go-queue/unique"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/go-redis/redis"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+//Config configurations of the worker
+type Config struct {
+	Env            map[string]string
+	Log            logger.Config
+	MetricsAddr    string
+	HealthAddr     string
+	BlockTimeout   time.Duration
+	Supervisor     listenersManager.SupervisorConfigs
+	TracerProvider trace.TracerProvider
+	Results        results.Store
+	Sealer         *security.Sealer
+	ClaimCheck     *claimcheck.Offloader
+}
+
+//ConfigFromEnv read the config from the env file, the connections are read from the same env
+func ConfigFromEnv(env map[string]string) (Config, error) {
+	config := Config{
+		Env:          env,
+		Log:          logger.ConfigFromEnv(env),
+		MetricsAddr:  env["METRICS_ADDR"],
+		HealthAddr:   env["HEALTH_ADDR"],
+		BlockTimeout: listener.DefaultBlockTimeout,
+	}
+
+	sealer, err := security.SealerFromEnv(env)
+	if err != nil {
+		return config, err
+	}
+	config.Sealer = sealer
+
+	if env["BLOCK_TIMEOUT"] != "" {
+		timeout, err := time.ParseDuration(env["BLOCK_TIMEOUT"])
+		if err != nil {
+			return config, err
+		}
+
+		config.BlockTimeout = timeout
+	}
+
+	return config, nil
+}
+
+//Worker runs the listeners of the jobs of the registry
+type Worker struct {
+	Registry *providers.Registry
+	Config   Config
+
+	loggers     *logger.Factory
+	connManager *connectionsmanager.Manager
+	events      *events.Bus
+	status      *listenersManager.StatusRegistry
+	listeners   *listenersManager.ListenerManager
+	scheduler   *scheduler.Scheduler
+}
+
+//New return a worker with the connections of the config, validating the registry against them
+func New(registry *providers.Registry, config Config) (*Worker, error) {
+	loggers, err := logger.NewFactory(config.Log)
+	if err != nil {
+		return nil, err
+	}
+
+	connManager := &connectionsmanager.Manager{Env: config.Env, Logger: loggers.Component("connections")}
+	connManager.SetDatabaseClients()
+
+	var connections []string
+	for name := range connManager.DBClients {
+		connections = append(connections, name)
+	}
+
+	err = registry.Validate(connections)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.ClaimCheck == nil {
+		mongoClient, _ := connManager.DBClients["mongo"].(*mongo.Client)
+		config.ClaimCheck, err = claimcheck.OffloaderFromEnv(config.Env, mongoClient)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	w := &Worker{
+		Registry:    registry,
+		Config:      config,
+		loggers:     loggers,
+		connManager: connManager,
+		events:      events.NewBus(),
+		status:      listenersManager.NewStatusRegistry(),
+	}
+
+	providers.SubscribeAll(w.events, registry.Subscribers())
+	w.status.Subscribe(w.events)
+
+	tracerProvider := config.TracerProvider
+	if tracerProvider == nil {
+		tracerProvider = otel.GetTracerProvider()
+	}
+
+	jobsTracing := tracing.New(tracerProvider)
+	jobsTracing.Subscribe(w.events)
+	middlewares := append([]providers.Middleware{jobsTracing.Middleware}, registry.Middlewares()...)
+
+	w.listeners = &listenersManager.ListenerManager{
+		Providers:   registry.Jobs(),
+		Workers:     registry.Workers(),
+		ConnManager: connManager,
+		JobsManager: &jobsManager.Manager{
+			Middlewares: middlewares,
+			Events:      w.events,
+			Logger:      loggers.Component("jobs"),
+			Breakers:    &breaker.Breakers{Logger: loggers.Component("breaker")},
+			Results:     config.Results,
+			Sealer:      config.Sealer,
+			ClaimCheck:  config.ClaimCheck,
+		},
+		Logger:     loggers.Component("listeners_manager"),
+		Status:     w.status,
+		Supervisor: config.Supervisor,
+	}
+	w.listeners.Listeners = listener.Listener{
+		Logger:       loggers.Component("listener"),
+		BlockTimeout: config.BlockTimeout,
+		Stop:         w.listeners.Done(),
+	}
+
+	if redisClient, ok := connManager.DBClients["redis"].(*redis.Client); ok {
+		releaser := &unique.Releaser{Redis: redisClient, Logger: loggers.Component("jobs")}
+		releaser.Subscribe(w.events)
+
+		if config.Results == nil {
+			w.listeners.JobsManager.SetResults(&results.RedisStore{Redis: redisClient})
+		}
+	}
+
+	if schedules := registry.Schedules(); len(schedules) > 0 {
+		redisClient, ok := connManager.DBClients["redis"].(*redis.Client)
+		if !ok {
+			return nil, fmt.Errorf("schedules without redis connection configured")
+		}
+
+		jobsDispatcher := &dispatcher.Dispatcher{
+			Client:     redisClient,
+			Hooks:      []dispatcher.EnvelopeHook{jobsTracing.Inject},
+			Logger:     loggers.Component("scheduler"),
+			Sealer:     config.Sealer,
+			ClaimCheck: config.ClaimCheck,
+		}
+
+		w.scheduler, err = scheduler.New(schedules, redisClient, jobsDispatcher)
+		if err != nil {
+			return nil, err
+		}
+		w.scheduler.Logger = loggers.Component("scheduler")
+	}
+
+	return w, nil
+}
+
+//Scheduler return the scheduler of the recurring jobs, nil without schedules registered
+func (w *Worker) Scheduler() *scheduler.Scheduler {
+	return w.scheduler
+}
+
+//Events return the events bus of the jobs
+func (w *Worker) Events() *events.Bus {
+	return w.events
+}
+
+//Run starts the metrics and health servers when configured, the scheduler and runs the listeners
+//until they stop or the process receives SIGINT or SIGTERM
+func (w *Worker) Run() {
+	if w.Config.MetricsAddr != "" {
+		w.startMetricsServer()
+	}
+
+	if w.Config.HealthAddr != "" {
+		w.startHealthServer()
+	}
+
+	if w.scheduler != nil {
+		go w.scheduler.Run(w.listeners.Done())
+	}
+
+	w.stopOnSignal()
+	w.listeners.RunListeners()
+}
+
+//Stop stops the listeners after the jobs running finish
+func (w *Worker) Stop() {
+	w.listeners.Stop()
+}
+
+func (w *Worker) stopOnSignal() {
+	log := w.loggers.Component("listeners_manager")
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case sig := <-signals:
+			log.Infof("Received %v, waiting the running jobs to finish", sig)
+			w.Stop()
+		case <-w.listeners.Done():
+		}
+
+		signal.Stop(signals)
+	}()
+}
+
+func (w *Worker) startMetricsServer() {
+	log := w.loggers.Component("metrics")
+
+	var connections []string
+	for name := range w.connManager.DBClients {
+		connections = append(connections, name)
+	}
+
+	redisClient, _ := w.connManager.DBClients["redis"].(metrics.RedisInterface)
+	collector := metrics.NewCollector(w.redisQueues(), connections, redisClient, w.connManager)
+	collector.Listeners = w.status
+	collector.Breakers = w.listeners.JobsManager.GetBreakers()
+	collector.DeadLetterQueues = w.deadLetterQueues()
+	collector.Logger = log
+	collector.Subscribe(w.events)
+
+	go func() {
+		err := metrics.Serve(w.Config.MetricsAddr, collector)
+		log.Errorf("Metrics server stopped: %v", err)
+	}()
+}
+
+func (w *Worker) startHealthServer() {
+	log := w.loggers.Component("health")
+	server := health.Server{
+		ConnManager: w.connManager,
+		Connections: w.Registry.RequiredConnections(),
+		Queues:      w.redisQueues(),
+		Status:      w.status,
+	}
+
+	go func() {
+		err := server.Serve(w.Config.HealthAddr)
+		log.Errorf("Health server stopped: %v", err)
+	}()
+}
+
+func (w *Worker) redisQueues() []string {
+	var queues []string
+	for _, job := range w.Registry.Jobs() {
+		if job.Driver == "redis" {
+			queues = append(queues, job.QueueName)
+		}
+	}
+
+	return queues
+}
+
+func (w *Worker) deadLetterQueues() map[string]string {
+	deadLetterQueues := make(map[string]string)
+	for _, job := range w.Registry.Jobs() {
+		if job.Driver == "redis" && job.DeadLetter.Enabled {
+			deadLetterQueues[job.QueueName] = job.DeadLetter.QueueName(job.QueueName)
+		}
+	}
+
+	return deadLetterQueues
+}
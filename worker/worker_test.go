@@ -0,0 +1,71 @@
+package worker
+
+import (
+	"go-queue/providers"
+	"testing"
+	"time"
+)
+
+func handlerTest(data interface{}, connections map[string]interface{}) error {
+	return nil
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	config, err := ConfigFromEnv(map[string]string{"BLOCK_TIMEOUT": "2s", "METRICS_ADDR": ":9100", "LOG_LEVEL": "debug"})
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	if config.BlockTimeout != 2*time.Second || config.MetricsAddr != ":9100" || config.Log.Level != "debug" {
+		t.Errorf("Expected config read from env but got %v", config)
+	}
+
+	if _, err := ConfigFromEnv(map[string]string{"BLOCK_TIMEOUT": "2"}); err == nil {
+		t.Errorf("Expected an error for invalid block timeout")
+	}
+
+	if _, err := ConfigFromEnv(map[string]string{"SIGNING_KEYS": "main:c2hvcnQ="}); err == nil {
+		t.Errorf("Expected an error for invalid signing key")
+	}
+}
+
+func TestNewReturnErrorOnMissingConnection(t *testing.T) {
+	registry := providers.NewRegistry()
+	registry.Register("queues:email", handlerTest)
+
+	_, err := New(registry, Config{Env: map[string]string{}})
+	if err == nil {
+		t.Errorf("Expected an error for queue without redis connection")
+	}
+}
+
+func TestRunReturnWithoutJobs(t *testing.T) {
+	queueWorker, err := New(providers.NewRegistry(), Config{Env: map[string]string{}})
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		queueWorker.Run()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("Expected worker without jobs to stop")
+	}
+
+	queueWorker.Stop()
+}
+
+func TestNewReturnErrorOnScheduleWithoutRedis(t *testing.T) {
+	registry := providers.NewRegistry()
+	registry.RegisterSchedule(providers.ScheduleConfigs{Name: "report", Spec: "@hourly", QueueName: "queues:report"})
+
+	_, err := New(registry, Config{Env: map[string]string{}})
+	if err == nil {
+		t.Errorf("Expected an error for schedule without redis connection")
+	}
+}